@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/migalabs/streameth/pkg/app"
 	"github.com/migalabs/streameth/pkg/config"
+	"github.com/migalabs/streameth/pkg/tracing"
 	"github.com/migalabs/streameth/pkg/utils"
 	"github.com/sirupsen/logrus"
 	cli "github.com/urfave/cli/v2"
@@ -32,7 +34,7 @@ var AnalyzerCommand = &cli.Command{
 		},
 		&cli.StringFlag{
 			Name:  "bn-endpoints",
-			Usage: "beacon node endpoints (client/label/endpoint,client/label/endpoint)",
+			Usage: "beacon node endpoints (client/label/endpoint,client/label/endpoint), leave client empty to detect it",
 		},
 		&cli.StringFlag{
 			Name:  "db-endpoint",
@@ -45,7 +47,7 @@ var AnalyzerCommand = &cli.Command{
 		},
 		&cli.StringFlag{
 			Name:        "metrics",
-			Usage:       "proposals,attestations",
+			Usage:       "proposals,attestations,reorgs,finality,heads and the event topics: block,block_gossip,blob_sidecar,finalized_checkpoint,voluntary_exit,bls_to_execution_change,contribution_and_proof,payload_attributes,attester_slashing,proposer_slashing",
 			DefaultText: config.DefaultMetrics,
 		},
 		&cli.StringFlag{
@@ -55,8 +57,186 @@ var AnalyzerCommand = &cli.Command{
 		},
 		&cli.StringFlag{
 			Name:        "prometheus-port",
-			Usage:       "Port where to listen for metrics",
+			Usage:       "Port where to listen for metrics (0 to not serve them)",
 			DefaultText: fmt.Sprintf("%d", config.DefaultPrometheusPort),
+		},
+		&cli.StringFlag{
+			Name:        "metrics-address",
+			Usage:       "Address where to listen for metrics",
+			DefaultText: config.DefaultMetricsAddress,
+		},
+		&cli.StringFlag{
+			Name:        "metrics-path",
+			Usage:       "Path of the metrics endpoint",
+			DefaultText: config.DefaultMetricsPath,
+		},
+		&cli.StringFlag{
+			Name:  "pushgateway-url",
+			Usage: "Prometheus Pushgateway where to push the metrics (disabled if empty)",
+		},
+		&cli.StringFlag{
+			Name:        "pushgateway-job",
+			Usage:       "Job of the metrics pushed to the Pushgateway",
+			DefaultText: config.DefaultPushgatewayJob,
+		},
+		&cli.StringFlag{
+			Name:  "otlp-metrics-endpoint",
+			Usage: "OTLP/HTTP endpoint where to push the metrics: http://localhost:4318/v1/metrics (disabled if empty)",
+		},
+		&cli.StringFlag{
+			Name:        "metrics-push-interval",
+			Usage:       "Time between metric pushes",
+			DefaultText: config.DefaultMetricsPush,
+		},
+		&cli.StringFlag{
+			Name:        "admin-port",
+			Usage:       "Port where to listen for the admin endpoints to add and remove nodes (0 disables it)",
+			DefaultText: fmt.Sprintf("%d", config.DefaultAdminPort),
+		},
+		&cli.StringFlag{
+			Name:        "admin-address",
+			Usage:       "Address where to listen for the admin endpoints, they are not authenticated",
+			DefaultText: config.DefaultAdminAddress,
+		},
+		&cli.StringFlag{
+			Name:        "history-dir",
+			Usage:       "Folder where to snapshot the attestation history of each node every epoch (empty disables it)",
+			DefaultText: config.DefaultHistoryDir,
+		},
+		&cli.StringFlag{
+			Name:        "history-workers",
+			Usage:       "Number of nodes building their history at the same time",
+			DefaultText: fmt.Sprintf("%d", config.DefaultHistoryWorkers),
+		},
+		&cli.StringFlag{
+			Name:        "proposal-offsets",
+			Usage:       "Times relative to the slot start at which proposals are requested (-1s,0s,2s,4s)",
+			DefaultText: config.DefaultProposalOffsets,
+		},
+		&cli.StringFlag{
+			Name:        "tracing",
+			Usage:       "OpenTelemetry span exporter: none,otlp,file",
+			DefaultText: config.DefaultTracing,
+		},
+		&cli.StringFlag{
+			Name:        "tracing-endpoint",
+			Usage:       "OTLP/HTTP collector where to send the spans",
+			DefaultText: config.DefaultTracingEndpoint,
+		},
+		&cli.StringFlag{
+			Name:        "tracing-file",
+			Usage:       "File where to write the spans with the file exporter",
+			DefaultText: config.DefaultTracingFile,
+		},
+		&cli.StringFlag{
+			Name:        "tracing-sampling",
+			Usage:       "Ratio of traces to sample (0-1)",
+			DefaultText: fmt.Sprintf("%.1f", config.DefaultTracingSampling),
+		},
+		&cli.StringFlag{
+			Name:        "partition-slots",
+			Usage:       "Slots per partition of the metrics tables, a multiple of 32, only applies to tables created from scratch (0 disables partitioning)",
+			DefaultText: fmt.Sprintf("%d", config.DefaultPartitionSlots),
+		},
+		&cli.StringFlag{
+			Name:        "retention-slots",
+			Usage:       "Slots of metrics to keep in the database (0 keeps everything)",
+			DefaultText: fmt.Sprintf("%d", config.DefaultRetentionSlots),
+		},
+		&cli.StringFlag{
+			Name:        "retention-mode",
+			Usage:       "What to do with partitions out of the retention window: drop,detach",
+			DefaultText: config.DefaultRetentionMode,
+		},
+		&cli.StringFlag{
+			Name:        "retention-downsample",
+			Usage:       "Keep per epoch aggregates of the metrics removed by the retention",
+			DefaultText: fmt.Sprintf("%t", config.DefaultDownsample),
+		},
+		&cli.StringFlag{
+			Name:        "db-overflow",
+			Usage:       "What to do when the write queue of a record type is full, as type=policy pairs (types: attestation,head,reorg,score,node_status,event,watchlist,default; policies: block,drop-oldest,sample,spill)",
+			DefaultText: config.DefaultDBOverflow,
+		},
+		&cli.StringFlag{
+			Name:        "db-queue-size",
+			Usage:       "Records each write queue can hold before applying its overflow policy",
+			DefaultText: fmt.Sprintf("%d", config.DefaultDBQueueSize),
+		},
+		&cli.StringFlag{
+			Name:        "db-spill-dir",
+			Usage:       "Folder where to spill the records of the queues with the spill policy",
+			DefaultText: config.DefaultDBSpillDir,
+		},
+		&cli.StringFlag{
+			Name:        "db-deadletter-file",
+			Usage:       "File where to keep the records rejected by the database, to replay them with db replay-deadletter",
+			DefaultText: config.DefaultDBDeadLetter,
+		},
+		&cli.StringFlag{
+			Name:  "report-dir",
+			Usage: "Folder where to write a daily client report (disabled if empty)",
+		},
+		&cli.StringFlag{
+			Name:        "report-epochs",
+			Usage:       "Epochs covered by the daily report",
+			DefaultText: fmt.Sprintf("%d", config.DefaultReportEpochs),
+		},
+		&cli.StringFlag{
+			Name:        "report-format",
+			Usage:       "Format of the daily report: markdown,html,json",
+			DefaultText: config.DefaultReportFormat,
+		},
+		&cli.StringFlag{
+			Name:        "finality-lag-epochs",
+			Usage:       "Alert when the finalized epoch of a node is more than this many epochs behind the current one (finality metric)",
+			DefaultText: fmt.Sprintf("%d", config.DefaultFinalityLag),
+		},
+		&cli.StringFlag{
+			Name:        "head-divergence-slots",
+			Usage:       "Slots a node can follow a different head than most nodes before it is considered on its own fork (heads metric)",
+			DefaultText: fmt.Sprintf("%d", config.DefaultHeadDivergence),
+		},
+		&cli.StringFlag{
+			Name:  "watchlist",
+			Usage: "Validator indices or 0x pubkeys to monitor, separated by commas, or @file with one per line",
+		},
+		&cli.StringFlag{
+			Name:        "pool-snapshot",
+			Usage:       "Fetch the attestation pool of each node with every proposal, to compute the packing efficiency",
+			DefaultText: fmt.Sprintf("%t", config.DefaultPoolSnapshot),
+		},
+		&cli.StringFlag{
+			Name:        "proposal-diff",
+			Usage:       "Compare the proposals of every pair of nodes at each slot: none,summary,detailed",
+			DefaultText: config.DefaultProposalDiff,
+		},
+		&cli.StringFlag{
+			Name:  "record-dir",
+			Usage: "Folder where to record the events and responses consumed from each node, to replay them (disabled if empty)",
+		},
+		&cli.StringFlag{
+			Name:  "ntp-servers",
+			Usage: "NTP servers (host or host:port, separated by commas) to measure the offset of the local clock against (disabled if empty)",
+		},
+		&cli.StringFlag{
+			Name:        "ntp-interval",
+			Usage:       "Time between clock offset measurements",
+			DefaultText: config.DefaultNtpInterval,
+		},
+		&cli.StringFlag{
+			Name:        "clock-correction",
+			Usage:       "Correct the recorded timestamps with the measured clock offset (needs ntp-servers)",
+			DefaultText: fmt.Sprintf("%t", config.DefaultClockCorrection),
+		},
+		&cli.StringFlag{
+			Name:        "attestation-delay-from",
+			Usage:       "What the attestation arrival delay of the histograms is measured from: slot-start or deadline (1/3 of the slot)",
+			DefaultText: config.DefaultArrivalFrom,
+		},
+		&cli.StringFlag{
+			Name:  "config-file",
+			Usage: "json file with the configuration (keys as the flags), bn-endpoints are reloaded from it on SIGHUP",
 		}},
 }
 
@@ -65,10 +245,26 @@ var QueryTimeout = 90 * time.Second
 func LaunchLiveMetrics(c *cli.Context) error {
 
 	conf := config.NewStreamethConfig()
+	if c.IsSet("config-file") {
+		err := conf.ReadFile(c.String("config-file"))
+		if err != nil {
+			return err
+		}
+	}
 	conf.Apply(c)
 
 	logrus.SetLevel(utils.ParseLogLevel(conf.LogLevel))
 
+	closeTracing, err := tracing.Init(c.Context, conf.Tracing, conf.TracingEndpoint, conf.TracingFile, conf.TracingSampling)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := closeTracing(context.Background()); err != nil {
+			log.Errorf("could not flush traces: %s", err)
+		}
+	}()
+
 	service, err := app.NewAppService(c.Context, *conf)
 	if err != nil {
 		log.Fatalf("could not start app: %s", err.Error())
@@ -76,8 +272,14 @@ func LaunchLiveMetrics(c *cli.Context) error {
 
 	procDoneC := make(chan struct{})
 	sigtermC := make(chan os.Signal, 1)
+	sighupC := make(chan os.Signal, 1)
 
 	signal.Notify(sigtermC, syscall.SIGINT, syscall.SIGTERM, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(sighupC, syscall.SIGHUP)
+	if conf.ConfigFile != "" && !c.IsSet("bn-endpoints") {
+		service.TrackConfigEndpoints(conf.BnEndpoints)
+	}
+	go reloadOnSighup(sighupC, conf.ConfigFile, service)
 
 	go func() {
 		service.Run()
@@ -92,8 +294,32 @@ func LaunchLiveMetrics(c *cli.Context) error {
 	case <-procDoneC:
 		log.Info("Process successfully finish!")
 	}
+	signal.Stop(sighupC)
 	close(sigtermC)
 	close(procDoneC)
+	close(sighupC)
 
 	return nil
 }
+
+// On SIGHUP the bn-endpoints are read again from the config file,
+// new nodes are added and the ones removed from the file are stopped, without disturbing the rest.
+// Nodes given with --bn-endpoints or added through the admin endpoint are kept
+func reloadOnSighup(sighupC chan os.Signal, configFile string, service *app.AppService) {
+	for range sighupC {
+		if configFile == "" {
+			log.Warnf("SIGHUP received but no config file was given, nothing to reload")
+			continue
+		}
+		log.Infof("SIGHUP received, reloading beacon nodes from %s", configFile)
+		conf := config.NewStreamethConfig()
+		err := conf.ReadFile(configFile)
+		if err != nil {
+			log.Errorf("could not reload config: %s", err)
+			continue
+		}
+		if err := service.ReloadEndpoints(conf.BnEndpoints); err != nil {
+			log.Errorf("not reloading the beacon nodes: %s", err)
+		}
+	}
+}
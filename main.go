@@ -36,6 +36,10 @@ func main() {
 		EnableBashCompletion: true,
 		Commands: []*cli.Command{
 			cmd.AnalyzerCommand,
+			cmd.BackfillCommand,
+			cmd.DBCommand,
+			cmd.ReportCommand,
+			cmd.ReplayCommand,
 		},
 	}
 	// generate the block analyzer
@@ -59,12 +59,14 @@ func (e *EpochStructs) RequestNewBeaconCommittee(slot uint64) error {
 func (e *EpochStructs) GetBeaconCommittee(slot uint64, index uint64) []phase0.ValidatorIndex {
 	log := log.WithField("routine", "epoch-structs")
 	e.mu.Lock()
+	defer e.mu.Unlock() // committees are requested and read from several event handlers
 	// if the epoch requested is newer than the data we have
 	if slot/32 > e.CurrentEpoch {
 		log.Debugf("Requesting new beacon committee for %d", slot/32)
-		e.RequestNewBeaconCommittee(slot)
+		if err := e.RequestNewBeaconCommittee(slot); err != nil {
+			log.Errorf("%s", err)
+		}
 	}
-	e.mu.Unlock()
 
 	committeeList := e.PreviousBeaconCommittees
 	if slot/32 == e.CurrentEpoch {
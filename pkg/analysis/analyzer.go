@@ -3,15 +3,22 @@ package analysis
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/attestantio/go-eth2-client/api"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/migalabs/streameth/pkg/analysis/additional_structs"
 	"github.com/migalabs/streameth/pkg/client_api"
+	"github.com/migalabs/streameth/pkg/clock"
 	"github.com/migalabs/streameth/pkg/postgresql"
+	"github.com/migalabs/streameth/pkg/recording"
+	"github.com/migalabs/streameth/pkg/tracing"
 	"github.com/migalabs/streameth/pkg/utils"
 	"github.com/prysmaticlabs/go-bitfield"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -20,19 +27,57 @@ var (
 		"module", moduleName)
 )
 
+// reasons to not score a proposal, stored in the score metrics
+const (
+	SkipReasonSyncing       = "is_syncing"
+	SkipReasonElOffline     = "el_offline"
+	SkipReasonBehindHead    = "behind_head"
+	SkipReasonProposalError = "proposal_error"
+	SkipReasonAnalysisError = "analysis_error"
+)
+
+// Persister queues the records to be written, the database service
+type Persister interface {
+	Persist(ctx context.Context, task postgresql.WriteTask)
+}
+
 // TODO: make attributes private where possible
 type ClientLiveData struct {
 	ctx              context.Context
+	cancel           context.CancelFunc                                         // stops the requests and subscriptions of this analyzer only
 	Eth2Provider     client_api.APIClient                                       // connection to the beacon node
 	AttHistory       map[phase0.Slot]map[phase0.CommitteeIndex]bitfield.Bitlist // 32 slots of attestation per slot and committeeIndex
 	BlockRootHistory map[phase0.Slot]phase0.Root                                // 64 slots of roots
+	historyMu        sync.RWMutex                                               // head events update the history while proposals read it
+	historyDir       string                                                     // where to snapshot the history, empty to disable
+	historyRestored  bool                                                       // the history comes from a snapshot, not checked against the node yet
 	log              *logrus.Entry                                              // each analyzer has its own logger
 	ProcessNewHead   chan struct{}
-	DBClient         *postgresql.PostgresDBService
+	DBClient         Persister
 	EpochData        additional_structs.EpochStructs
 	CurrentHeadSlot  uint64
 	Monitoring       MonitoringMetrics
+	nodeStatusMu     sync.RWMutex
+	nodeStatus       client_api.NodeStatus // last status polled from the node
+	nodeVersion      string                // last version string reported by the node
+	finality         FinalityStatus        // last finality checkpoints reported by the node
+	headMu           sync.RWMutex
+	head             HeadStatus                   // last head event
+	headRoots        map[phase0.Slot]phase0.Root  // head roots reported per slot
+	chainRoots       map[phase0.Slot]*phase0.Root // block roots asked to the node for the current head, nil if skipped
+	chainRootsHead   phase0.Root
+	watchlist        *Watchlist                   // validators to monitor, nil if none
+	watchChain       *chainIndex                  // ancestors of the head blocks, to judge the watchlist votes
+	poolSnapshot     bool                         // fetch the attestation pool with each proposal
+	arrivalObserver  *ArrivalDelayObserver        // attestation arrival histograms, nil if disabled
+	source           RequestSource                // answers the block and proposal requests
+	offline          bool                         // replaying a recording, there is no node to ask
+	clock            clock.Clock                  // time of the events, simulated when replaying
+	clockOffset      func() (time.Duration, bool) // estimated offset of the local clock, nil if not measured
+	recorderMu       sync.Mutex
+	recorder         *recording.Recorder // records what is consumed from the node, nil if disabled
 	client           string
+	autoDetected     bool // client was not configured, it follows the node version
 	label            string
 	blocksDir        string
 }
@@ -44,96 +89,273 @@ func NewBlockAnalyzer(
 	cliEndpoint string,
 	timeout time.Duration,
 	dbClient *postgresql.PostgresDBService,
-	blocksBaseDir string) (*ClientLiveData, error) {
+	blocksBaseDir string,
+	historyDir string) (*ClientLiveData, error) {
+	ctx, cancel := context.WithCancel(ctx)
 	client, err := client_api.NewAPIClient(ctx, label, cliEndpoint, timeout)
 	if err != nil {
 		log.Errorf("could not create eth2 client: %s", err)
+		cancel()
 		return &ClientLiveData{}, err
 	}
 
+	// the node tells us which client it runs, use it to fill or check the configured one
+	autoDetected := false
+	nodeVersion, err := client.NodeVersion()
+	detectedClient, clientVersion := utils.ParseClientVersion(nodeVersion)
+	if err != nil {
+		log.Warnf("could not get node version of %s: %s", label, err)
+	}
+	if clientName == "" {
+		if detectedClient == "" {
+			cancel()
+			return &ClientLiveData{}, fmt.Errorf("client not configured and could not be detected from node version %q", nodeVersion)
+		}
+		log.Infof("detected client %s %s for %s", detectedClient, clientVersion, label)
+		clientName = detectedClient
+		autoDetected = true
+	} else if detectedClient != "" && detectedClient != clientName {
+		log.Warnf("configured client %s for %s does not match the node version: %s", clientName, label, nodeVersion)
+	}
+
 	if !utils.CheckValidClientName(clientName) {
 		log.Errorf("could not identify eth2 client, try one of: Prysm,Lighthouse,Teku,Nimbus,Lodestar,Grandine")
-		return &ClientLiveData{}, nil
+		cancel()
+		return &ClientLiveData{}, fmt.Errorf("invalid client name: %s", clientName)
 	}
 
 	analyzer := &ClientLiveData{
 		ctx:              ctx,
+		cancel:           cancel,
 		Eth2Provider:     *client,
+		source:           liveSource{client: client},
+		clock:            clock.Real{},
 		DBClient:         dbClient,
 		AttHistory:       make(map[phase0.Slot]map[phase0.CommitteeIndex]bitfield.Bitlist),
 		BlockRootHistory: make(map[phase0.Slot]phase0.Root),
+		historyDir:       historyDir,
 		log:              log.WithField("label", label).WithField("clientName", clientName),
 		EpochData:        additional_structs.NewEpochData(client.Api),
 		CurrentHeadSlot:  0,
 		ProcessNewHead:   make(chan struct{}),
 		Monitoring:       MonitoringMetrics{},
 		client:           clientName,
+		autoDetected:     autoDetected,
 		blocksDir:        fmt.Sprintf("%s/%s/%s/", blocksBaseDir, label, clientName),
-		label:            fmt.Sprintf("%s_%s", label, cliEndpoint),
+		label:            ComposeLabel(label, cliEndpoint),
 	}
 	analyzer.CheckBlocksFolder()
+	if nodeVersion != "" {
+		analyzer.recordNodeVersion(nodeVersion, clientVersion)
+	}
 
 	return analyzer, nil
 }
 
+// NewReplayAnalyzer creates an analyzer that is not connected to a node,
+// its requests are answered by the source and its clock is the given one
+func NewReplayAnalyzer(
+	ctx context.Context,
+	clientName string,
+	label string,
+	cliEndpoint string,
+	dbClient *postgresql.PostgresDBService,
+	blocksBaseDir string,
+	source RequestSource,
+	clk clock.Clock) *ClientLiveData {
+	ctx, cancel := context.WithCancel(ctx)
+
+	analyzer := &ClientLiveData{
+		ctx:              ctx,
+		cancel:           cancel,
+		source:           source,
+		offline:          true,
+		clock:            clk,
+		DBClient:         dbClient,
+		AttHistory:       make(map[phase0.Slot]map[phase0.CommitteeIndex]bitfield.Bitlist),
+		BlockRootHistory: make(map[phase0.Slot]phase0.Root),
+		log:              log.WithField("label", label).WithField("clientName", clientName),
+		ProcessNewHead:   make(chan struct{}),
+		Monitoring:       MonitoringMetrics{},
+		client:           clientName,
+		blocksDir:        fmt.Sprintf("%s/%s/%s/", blocksBaseDir, label, clientName),
+		label:            ComposeLabel(label, cliEndpoint),
+	}
+	analyzer.CheckBlocksFolder()
+	return analyzer
+}
+
 // Asks for a block proposal to the client and stores score in the database
-func (b *ClientLiveData) ProposeNewBlock(slot phase0.Slot) {
-	log := b.log.WithField("task", "generate-block")
+// The offset is the time relative to the slot start at which the proposal is requested
+// The proposal is returned if it could be scored, nil otherwise
+func (b *ClientLiveData) ProposeNewBlock(ctx context.Context, slot phase0.Slot, offset time.Duration) *api.VersionedProposal {
+	log := b.log.WithField("task", "generate-block").WithField("offset", offset.String())
 	log.Debugf("processing new block: %d\n", slot)
 
-	for i := range b.AttHistory { // TODO: 32 must be a constant
-		if i+32 < slot { // attestations can only reference 32 slots back
-			delete(b.AttHistory, i) // remove old entries from the map
-		}
-	}
+	ctx, span := tracing.Tracer().Start(ctx, "ProposeNewBlock", trace.WithAttributes(
+		tracing.SlotKey.Int64(int64(slot)),
+		tracing.OffsetKey.String(offset.String()),
+		tracing.LabelKey.String(b.label),
+		tracing.ClientKey.String(b.GetClient())))
+	defer span.End()
+	// the requests are bound to the analyzer, so removing it aborts them, the span keeps them in the slot trace
+	ctx = trace.ContextWithSpan(b.ctx, span)
 
-	for i := range b.BlockRootHistory { // TODO: 64 must be a constant
-		if i+64 < slot { // attestations can only reference 32 slots back
-			delete(b.BlockRootHistory, i) // remove old entries from the map
-		}
-	}
+	b.pruneHistory(slot)
 
 	metrics := postgresql.BlockMetricsModel{
-		Slot:  int(slot),
-		Label: b.label,
-		Score: -1,
+		Slot:       int(slot),
+		ClientName: b.GetClient(),
+		Label:      b.label,
+		OffsetMs:   int(offset.Milliseconds()),
 	}
 
-	if slot > (phase0.Slot(b.CurrentHeadSlot) + utils.SlotsPerEpoch) {
-		// beacon node is not synced
+	// do not ask sick nodes for blocks, but keep track of why
+	nodeStatus := b.GetNodeStatus()
+	switch {
+	case nodeStatus.IsSyncing:
+		metrics.SkipReason = SkipReasonSyncing
+	case nodeStatus.ElOffline:
+		metrics.SkipReason = SkipReasonElOffline
+	case slot > (phase0.Slot(b.CurrentHeadSlot) + utils.SlotsPerEpoch):
+		metrics.SkipReason = SkipReasonBehindHead
+	}
+	if metrics.SkipReason != "" {
 		b.Monitoring.ProposalStatus = 0
-		log.Errorf("node is not synced(proposal slot: %d, node head slot: %d), not proposing", slot, b.CurrentHeadSlot)
-		return
+		log.Errorf("node is not ready (%s, proposal slot: %d, node head slot: %d), not proposing", metrics.SkipReason, slot, b.CurrentHeadSlot)
+		span.SetAttributes(attribute.String("skip_reason", metrics.SkipReason))
+		b.DBClient.Persist(ctx, postgresql.BlockScoreTask(metrics))
+		return nil
 	}
 
-	block, blockTime, err := b.Eth2Provider.ProposeNewBlock(slot, b.client)
+	// the pool is requested at the same time as the block, to see what the node could include
+	var pool []*phase0.Attestation
+	var poolErr error
+	poolDone := make(chan struct{})
+	if b.poolSnapshot {
+		go func() {
+			defer close(poolDone)
+			poolCtx, poolSpan := tracing.Tracer().Start(ctx, "AttestationPool")
+			defer poolSpan.End()
+			pool, poolErr = b.Eth2Provider.AttestationPool(poolCtx)
+		}()
+	} else {
+		close(poolDone)
+	}
+
+	block, blockTime, err := b.fetchProposal(ctx, slot, offset)
+	<-poolDone
 
 	if err != nil {
+		span.RecordError(err)
 		log.Errorf("error requesting block from %s: %s", b.label, err)
 		b.Monitoring.ProposalStatus = 0
+		metrics.SkipReason = SkipReasonProposalError
 
 	} else {
 
+		_, metricsSpan := tracing.Tracer().Start(ctx, "BlockMetrics")
 		newMetrics, err := b.BlockMetrics(block, blockTime)
+		metricsSpan.End()
 		if err != nil {
+			span.RecordError(err)
 			log.Errorf("error analyzing block from %s: %s", b.label, err)
 			b.Monitoring.ProposalStatus = 0
+			metrics.SkipReason = SkipReasonAnalysisError
 		} else {
 			b.Monitoring.ProposalStatus = 1
 			metrics = newMetrics
+			metrics.OffsetMs = int(offset.Milliseconds())
+			if b.poolSnapshot {
+				b.packingMetrics(&metrics, block, pool, poolErr)
+			}
 			log.Infof("Block Generation Time: %fs", blockTime.Seconds())
 			log.Infof("Metrics: %+v", metrics)
 		}
 
 	}
-	b.DBClient.PersisBlockScoreMetrics(metrics)
+	b.DBClient.Persist(ctx, postgresql.BlockScoreTask(metrics))
 
 	if block != nil {
-		b.PersistBlock(*block)
+		_, persistSpan := tracing.Tracer().Start(ctx, "PersistBlock")
+		b.PersistBlock(*block, offset)
+		persistSpan.End()
 	}
 
 	// We block the update attestations as new head could impact attestations of the proposed block
 	// b.ProcessNewHead <- struct{}{} // Allow the new head to update attestations
+
+	if metrics.SkipReason != "" {
+		return nil
+	}
+	return block
+}
+
+// packingMetrics adds the packing efficiency of the proposal to its metrics
+func (b *ClientLiveData) packingMetrics(metrics *postgresql.BlockMetricsModel, block *api.VersionedProposal, pool []*phase0.Attestation, poolErr error) {
+	if poolErr != nil {
+		b.log.Warnf("could not get attestation pool, no packing efficiency for slot %d: %s", metrics.Slot, poolErr)
+		return
+	}
+	input, err := BlockInputFromProposal(block)
+	if err != nil {
+		b.log.Warnf("could not read block proposal, no packing efficiency for slot %d: %s", metrics.Slot, err)
+		return
+	}
+	packing := b.Packing(input, metrics.NewVotes, pool)
+	metrics.PoolSnapshot = true
+	metrics.PoolSize = packing.PoolSize
+	metrics.AchievableVotes = packing.AchievableVotes
+	metrics.PackingEfficiency = packing.PackingEfficiency
+}
+
+// SetPoolSnapshot enables fetching the attestation pool with each proposal
+func (b *ClientLiveData) SetPoolSnapshot(enabled bool) {
+	b.poolSnapshot = enabled
+}
+
+// SetClock replaces the clock that timestamps the events and drives the retries
+func (b *ClientLiveData) SetClock(c clock.Clock) {
+	b.clock = c
+}
+
+// SetClockOffset sets where to read the estimated offset of the local clock, stored with the arrivals
+func (b *ClientLiveData) SetClockOffset(offset func() (time.Duration, bool)) {
+	b.clockOffset = offset
+}
+
+// clockOffsetParam is the estimated offset of the local clock in milliseconds, nil if there is no estimate
+func (b *ClientLiveData) clockOffsetParam() interface{} {
+	if b.clockOffset == nil {
+		return nil
+	}
+	offset, ok := b.clockOffset()
+	if !ok {
+		return nil
+	}
+	return float64(offset) / float64(time.Millisecond)
+}
+
+// now is the time by the clock of the analyzer, the system one if not set
+func (b *ClientLiveData) now() time.Time {
+	return clock.OrReal(b.clock).Now()
+}
+
+// ComposeLabel builds the label that identifies the analyzer of a beacon node
+func ComposeLabel(label string, cliEndpoint string) string {
+	return fmt.Sprintf("%s_%s", label, cliEndpoint)
+}
+
+// Context is alive as long as the analyzer is running
+func (b *ClientLiveData) Context() context.Context {
+	return b.ctx
+}
+
+// Close stops the analyzer, its subscriptions and pending requests
+func (b *ClientLiveData) Close() {
+	b.log.Infof("closing analyzer")
+	b.StopRecording()
+	b.cancel()
 }
 
 func (b *ClientLiveData) GetLabel() string {
@@ -141,6 +363,8 @@ func (b *ClientLiveData) GetLabel() string {
 }
 
 func (b *ClientLiveData) GetClient() string {
+	b.nodeStatusMu.RLock()
+	defer b.nodeStatusMu.RUnlock()
 	return b.client
 }
 
@@ -0,0 +1,68 @@
+package analysis
+
+import (
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/chain_stats"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// what the attestation arrival delay is measured from
+const (
+	ArrivalFromSlotStart = "slot-start"
+	ArrivalFromDeadline  = "deadline" // 1/3 of the slot, when the attesters are due to vote
+)
+
+// kinds of attestation by the number of votes they carry
+const (
+	AttestationAggregate    = "aggregate"
+	AttestationUnaggregated = "unaggregated"
+)
+
+// ArrivalDelayObserver observes the arrival delay of each attestation event
+type ArrivalDelayObserver struct {
+	ChainTime chain_stats.ChainTime
+	Reference string
+	Histogram prometheus.ObserverVec // by clientName, label and kind
+}
+
+// reference returns the time the arrival delay of an attestation of the slot is measured from
+func (o *ArrivalDelayObserver) reference(slot phase0.Slot) time.Time {
+	start := o.ChainTime.SlotTime(slot)
+	if o.Reference == ArrivalFromDeadline {
+		return start.Add(chain_stats.SLOT_DURATION * time.Second / 3)
+	}
+	return start
+}
+
+// ArrivalDelay returns how long after the reference of its slot the attestation arrived,
+// negative if it arrived before
+func (o *ArrivalDelayObserver) ArrivalDelay(attestation *phase0.Attestation, timestamp time.Time) time.Duration {
+	return timestamp.Sub(o.reference(attestation.Data.Slot))
+}
+
+// AttestationKind tells aggregates (more than one vote) from unaggregated attestations
+func AttestationKind(attestation *phase0.Attestation) string {
+	if attestation.AggregationBits.Count() > 1 {
+		return AttestationAggregate
+	}
+	return AttestationUnaggregated
+}
+
+// SetArrivalDelayObserver enables observing the arrival delay of the attestation events, nil disables it
+func (b *ClientLiveData) SetArrivalDelayObserver(observer *ArrivalDelayObserver) {
+	b.arrivalObserver = observer
+}
+
+func (b *ClientLiveData) observeArrival(attestation *phase0.Attestation, timestamp time.Time) {
+	if b.arrivalObserver == nil {
+		return
+	}
+	delay := b.arrivalObserver.ArrivalDelay(attestation, timestamp)
+	b.arrivalObserver.Histogram.With(prometheus.Labels{
+		"clientName": b.GetClient(),
+		"label":      b.label,
+		"kind":       AttestationKind(attestation),
+	}).Observe(delay.Seconds())
+}
@@ -0,0 +1,91 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	api_v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/chain_stats"
+	"github.com/migalabs/streameth/pkg/postgresql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArrivalDelay(t *testing.T) {
+	genesis := time.Unix(1606824023, 0)
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "test_arrival_delay_seconds",
+		Buckets: []float64{0, 1, 2, 4},
+	}, []string{"clientName", "label", "kind"})
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(histogram)
+
+	observer := &ArrivalDelayObserver{
+		ChainTime: chain_stats.ChainTime{GenesisTime: genesis},
+		Reference: ArrivalFromSlotStart,
+		Histogram: histogram,
+	}
+	single := testAttestation(10, 0, 3)
+	aggregate := testAttestation(10, 0, 1, 2, 3)
+	arrival := genesis.Add(10*12*time.Second + 3*time.Second)
+
+	if delay := observer.ArrivalDelay(single, arrival); delay != 3*time.Second {
+		t.Errorf("expected 3s from the slot start, got %s", delay)
+	}
+	observer.Reference = ArrivalFromDeadline
+	if delay := observer.ArrivalDelay(single, arrival); delay != -time.Second {
+		t.Errorf("expected -1s from the deadline, got %s", delay)
+	}
+	if AttestationKind(single) != AttestationUnaggregated || AttestationKind(aggregate) != AttestationAggregate {
+		t.Errorf("attestation kinds are not told apart")
+	}
+
+	b := &ClientLiveData{client: "lighthouse", label: "node"}
+	b.observeArrival(single, arrival) // disabled
+	b.SetArrivalDelayObserver(observer)
+	b.observeArrival(single, arrival)
+	b.observeArrival(aggregate, arrival)
+	b.observeArrival(aggregate, arrival.Add(time.Second))
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]uint64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "kind" {
+					counts[label.GetValue()] = metric.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	if counts[AttestationUnaggregated] != 1 || counts[AttestationAggregate] != 2 {
+		t.Errorf("unexpected observations by kind: %v", counts)
+	}
+}
+
+func TestArrivalClockOffset(t *testing.T) {
+	b, db, now := testEventAnalyzer()
+	attestation := testAttestation(10, 0, 3)
+	attestation.Data.Source = &phase0.Checkpoint{}
+	attestation.Data.Target = &phase0.Checkpoint{}
+	event := &api_v1.Event{Topic: "attestation", Data: attestation}
+
+	// no estimate yet
+	b.SetClockOffset(func() (time.Duration, bool) { return 0, false })
+	b.HandleAttestationEvent(event)
+	tasks := db.Pending(postgresql.RecordAttestation)
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, now, tasks[0].Params[3])
+	assert.Nil(t, tasks[0].Params[8])
+
+	b.SetClockOffset(func() (time.Duration, bool) { return -250 * time.Millisecond, true })
+	b.HandleAttestationEvent(event)
+	tasks = db.Pending(postgresql.RecordAttestation)
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, -250.0, tasks[0].Params[8])
+	assert.Len(t, tasks[0].Params, len(postgresql.AttCopyTarget.Columns))
+}
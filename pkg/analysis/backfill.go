@@ -0,0 +1,128 @@
+package analysis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/postgresql"
+	"github.com/migalabs/streameth/pkg/utils"
+)
+
+// Backfill walks the canonical blocks in [fromSlot, toSlot] from an archive node.
+// Each block is scored against the attestations included before it, as live proposals are,
+// and the history is updated with it before moving to the next slot
+func (b *ClientLiveData) Backfill(fromSlot phase0.Slot, toSlot phase0.Slot) error {
+	log := b.log.WithField("routine", "backfill")
+
+	if fromSlot > 0 {
+		// the blocks before the range can be referenced by the first ones
+		if err := b.fillHistory(fromSlot - 1); err != nil {
+			return fmt.Errorf("could not build the history before slot %d: %s", fromSlot, err)
+		}
+	}
+
+	start := time.Now()
+	for slot := fromSlot; slot <= toSlot; slot++ {
+		if err := b.ctx.Err(); err != nil {
+			return err
+		}
+		if err := b.backfillSlot(slot); err != nil {
+			return fmt.Errorf("could not backfill slot %d: %s", slot, err)
+		}
+		if uint64(slot)%utils.SlotsPerEpoch == utils.SlotsPerEpoch-1 || slot == toSlot {
+			done := slot - fromSlot + 1
+			log.Infof("backfilled up to slot %d (%d/%d slots, %.1f slots/s)",
+				slot, done, toSlot-fromSlot+1, float64(done)/time.Since(start).Seconds())
+		}
+	}
+	return nil
+}
+
+func (b *ClientLiveData) backfillSlot(slot phase0.Slot) error {
+	b.pruneHistory(slot)
+
+	block, missed, err := b.fetchBlock(slot)
+	if err != nil {
+		return err
+	}
+	if missed {
+		params := make([]interface{}, 0)
+		params = append(params, uint64(slot))
+		params = append(params, b.label)
+		b.DBClient.Persist(b.ctx, postgresql.WriteTask{
+			QueryString: postgresql.InsertNewMissedBlock,
+			Params:      params,
+			CopyTarget:  postgresql.MissedBlockCopyTarget,
+			Type:        postgresql.RecordHead,
+		})
+		return nil
+	}
+
+	input, err := BlockInputFromSignedBlock(block)
+	if err != nil {
+		return err
+	}
+	proposerIndex, err := block.ProposerIndex()
+	if err != nil {
+		return fmt.Errorf("could not get proposer index: %s", err)
+	}
+
+	metrics, attScores := b.ScoreBlock(input)
+	b.DBClient.Persist(b.ctx, postgresql.CanonicalScoreTask(metrics, uint64(proposerIndex)))
+
+	for i, item := range attScores {
+		params := make([]interface{}, 0)
+		params = append(params, b.label)
+		params = append(params, uint64(slot))
+		params = append(params, i)
+		params = append(params, uint64(item.Slot))
+		params = append(params, uint64(item.CommitteeIndex))
+		params = append(params, uint64(slot-item.Slot))
+		params = append(params, item.Votes)
+		params = append(params, item.NewVotes)
+		params = append(params, item.CorrectSource)
+		params = append(params, item.CorrectTarget)
+		params = append(params, item.CorrectHead)
+		b.DBClient.Persist(b.ctx, postgresql.WriteTask{
+			QueryString: postgresql.InsertNewAttInclusion,
+			Params:      params,
+			CopyTarget:  postgresql.AttInclusionCopyTarget,
+			Type:        postgresql.RecordAttestation,
+		})
+	}
+
+	payload, ok, err := utils.PayloadSummaryFromBlock(*block)
+	if err != nil {
+		b.log.WithField("routine", "backfill").Warnf("could not read the execution payload at slot %d: %s", slot, err)
+	}
+	if ok {
+		params := make([]interface{}, 0)
+		params = append(params, uint64(slot))
+		params = append(params, b.label)
+		params = append(params, payload.BlockNumber)
+		params = append(params, payload.BlockHash.String())
+		params = append(params, payload.TxCount)
+		params = append(params, payload.GasUsed)
+		params = append(params, payload.GasLimit)
+		params = append(params, payload.BaseFeeWei)
+		params = append(params, payload.Withdrawals)
+		params = append(params, payload.BlobCommitments)
+		b.DBClient.Persist(b.ctx, postgresql.WriteTask{
+			QueryString: postgresql.InsertNewPayloadMetrics,
+			Params:      params,
+		})
+	}
+
+	// the block is now part of the chain the next ones are judged against
+	root, err := block.Root()
+	if err != nil {
+		return fmt.Errorf("could not get block root: %s", err)
+	}
+	b.historyMu.Lock()
+	b.BlockRootHistory[slot] = root
+	b.historyMu.Unlock()
+	b.UpdateAttestations(*block)
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/api"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/holiman/uint256"
+	"github.com/migalabs/streameth/pkg/clock"
+	"github.com/migalabs/streameth/pkg/postgresql"
+	"github.com/prysmaticlabs/go-bitfield"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockSource serves the canonical blocks by slot, the slots without a block answer 404
+type blockSource struct {
+	blocks map[string]*spec.VersionedSignedBeaconBlock
+}
+
+func (s *blockSource) SignedBeaconBlock(ctx context.Context, blockID string) (*spec.VersionedSignedBeaconBlock, error) {
+	block, ok := s.blocks[blockID]
+	if !ok {
+		return nil, fmt.Errorf("GET failed with status 404: NOT_FOUND")
+	}
+	return block, nil
+}
+
+func (s *blockSource) Proposal(ctx context.Context, slot phase0.Slot, client string) (*api.VersionedProposal, time.Duration, error) {
+	return nil, 0, fmt.Errorf("no proposals when backfilling")
+}
+
+func testDenebBlock(slot phase0.Slot, parentRoot phase0.Root, attestations ...*phase0.Attestation) *spec.VersionedSignedBeaconBlock {
+	syncBits := bitfield.NewBitvector512()
+	syncBits.SetBitAt(0, true)
+	syncBits.SetBitAt(7, true)
+	return &spec.VersionedSignedBeaconBlock{
+		Version: spec.DataVersionDeneb,
+		Deneb: &deneb.SignedBeaconBlock{
+			Message: &deneb.BeaconBlock{
+				Slot:          slot,
+				ProposerIndex: 42,
+				ParentRoot:    parentRoot,
+				Body: &deneb.BeaconBlockBody{
+					ETH1Data:          &phase0.ETH1Data{BlockHash: make([]byte, 32)},
+					ProposerSlashings: []*phase0.ProposerSlashing{},
+					AttesterSlashings: []*phase0.AttesterSlashing{},
+					Attestations:      attestations,
+					Deposits:          []*phase0.Deposit{},
+					VoluntaryExits:    []*phase0.SignedVoluntaryExit{},
+					SyncAggregate:     &altair.SyncAggregate{SyncCommitteeBits: syncBits},
+					ExecutionPayload: &deneb.ExecutionPayload{
+						BlockNumber:   1000 + uint64(slot),
+						GasUsed:       15000000,
+						GasLimit:      30000000,
+						BaseFeePerGas: uint256.NewInt(7),
+						ExtraData:     []byte{},
+						Transactions:  []bellatrix.Transaction{{0x01}, {0x02}},
+						Withdrawals:   []*capella.Withdrawal{},
+					},
+					BLSToExecutionChanges: []*capella.SignedBLSToExecutionChange{},
+					BlobKZGCommitments:    []deneb.KZGCommitment{{0x01}},
+				},
+			},
+		},
+	}
+}
+
+func testBackfillAnalyzer(source RequestSource) (*ClientLiveData, *fakePersister) {
+	db := &fakePersister{}
+	b := &ClientLiveData{
+		ctx:              context.Background(),
+		log:              log.WithField("label", "archive"),
+		DBClient:         db,
+		source:           source,
+		clock:            clock.NewFake(time.Unix(1700000000, 0)),
+		client:           "lighthouse",
+		label:            "archive",
+		AttHistory:       make(map[phase0.Slot]map[phase0.CommitteeIndex]bitfield.Bitlist),
+		BlockRootHistory: make(map[phase0.Slot]phase0.Root),
+	}
+	return b, db
+}
+
+func TestBackfill(t *testing.T) {
+	checkpoint := &phase0.Checkpoint{Epoch: 3, Root: phase0.Root{0x03}}
+	vote := func(slot phase0.Slot, headRoot phase0.Root, bits ...uint64) *phase0.Attestation {
+		attestation := testAttestation(slot, 1, bits...)
+		attestation.Data.BeaconBlockRoot = headRoot
+		attestation.Data.Source = checkpoint
+		attestation.Data.Target = checkpoint
+		return attestation
+	}
+
+	// 100 <- 101 <- (102 skipped) <- 103
+	block100 := testDenebBlock(100, phase0.Root{0x99})
+	root100, err := block100.Root()
+	assert.Nil(t, err)
+	block101 := testDenebBlock(101, root100, vote(100, root100, 0, 1))
+	root101, err := block101.Root()
+	assert.Nil(t, err)
+	// the votes of 101 are not new anymore, 3 is
+	block103 := testDenebBlock(103, root101, vote(100, root100, 0, 1, 3), vote(102, root101, 2))
+	root103, err := block103.Root()
+	assert.Nil(t, err)
+
+	b, db := testBackfillAnalyzer(&blockSource{blocks: map[string]*spec.VersionedSignedBeaconBlock{
+		"100": block100, "101": block101, "103": block103,
+	}})
+	assert.Nil(t, b.Backfill(101, 103))
+
+	missed := db.Pending(postgresql.RecordHead)
+	if assert.Len(t, missed, 1) {
+		assert.Equal(t, postgresql.InsertNewMissedBlock, missed[0].QueryString)
+		assert.Equal(t, []interface{}{uint64(102), "archive"}, missed[0].Params)
+	}
+
+	scores := db.Pending(postgresql.RecordScore)
+	if assert.Len(t, scores, 2) {
+		for _, item := range scores {
+			assert.Equal(t, postgresql.InsertNewCanonicalScore, item.QueryString)
+			assert.Equal(t, "archive", item.Params[1])
+			assert.Equal(t, uint64(42), item.Params[2]) // proposer index
+			assert.Equal(t, 2, item.Params[7])          // sync bits
+		}
+		assert.Equal(t, 101, scores[0].Params[0])
+		assert.Equal(t, 2, scores[0].Params[6]) // correct head votes
+		assert.Equal(t, 2, scores[0].Params[9]) // new votes
+		assert.Equal(t, 103, scores[1].Params[0])
+		assert.Equal(t, 1, scores[1].Params[6]) // only the vote of 102 is timely for the head
+		assert.Equal(t, 2, scores[1].Params[9])
+	}
+
+	inclusions := db.Pending(postgresql.RecordAttestation)
+	if assert.Len(t, inclusions, 3) {
+		for _, item := range inclusions {
+			assert.Equal(t, postgresql.AttInclusionCopyTarget, item.CopyTarget)
+		}
+		// label, inclusion slot, position, attestation slot, committee, delay, votes, new votes, source, target, head
+		assert.Equal(t, []interface{}{"archive", uint64(101), 0, uint64(100), uint64(1), uint64(1), 2, 2, true, true, true}, inclusions[0].Params)
+		assert.Equal(t, []interface{}{"archive", uint64(103), 0, uint64(100), uint64(1), uint64(3), 3, 1, true, true, false}, inclusions[1].Params)
+		assert.Equal(t, []interface{}{"archive", uint64(103), 1, uint64(102), uint64(1), uint64(1), 1, 1, true, true, true}, inclusions[2].Params)
+	}
+
+	payloads := db.Pending("")
+	if assert.Len(t, payloads, 2) {
+		assert.Equal(t, postgresql.InsertNewPayloadMetrics, payloads[1].QueryString)
+		// slot, label, number, hash, txs, gas used, gas limit, base fee, withdrawals, blobs
+		assert.Equal(t, []interface{}{uint64(103), "archive", uint64(1103), phase0.Hash32{}.String(), 2, uint64(15000000), uint64(30000000), uint64(7), 0, 1}, payloads[1].Params)
+	}
+
+	assert.Equal(t, map[phase0.Slot]phase0.Root{100: root100, 101: root101, 103: root103}, b.BlockRootHistory)
+	assert.Equal(t, []int{0, 1, 3}, b.AttHistory[100][1].BitIndices())
+	assert.Equal(t, []int{2}, b.AttHistory[102][1].BitIndices())
+}
@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/attestantio/go-eth2-client/api"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/utils"
+)
+
+// BlockInput has the parts of a block that are scored,
+// so proposals and canonical blocks are judged the same way
+type BlockInput struct {
+	Version           spec.DataVersion // fork of the block
+	Slot              phase0.Slot
+	ParentRoot        phase0.Root
+	Attestations      []*phase0.Attestation
+	AttesterSlashings []*phase0.AttesterSlashing
+	ProposerSlashings []*phase0.ProposerSlashing
+	SyncBits          uint64 // participants in the sync aggregate, 0 before altair
+}
+
+// BlockInputFromProposal reads the input of a block proposal
+func BlockInputFromProposal(block *api.VersionedProposal) (BlockInput, error) {
+	slot, err := block.Slot()
+	if err != nil {
+		return BlockInput{}, fmt.Errorf("could not get block slot from block proposal: %s", err)
+	}
+	parentRoot, err := block.ParentRoot()
+	if err != nil {
+		return BlockInput{}, fmt.Errorf("could not get parent root from block proposal: %s", err)
+	}
+
+	blockBody := utils.BlockBodyFromProposal(*block)
+	return BlockInput{
+		Version:           block.Version,
+		Slot:              slot,
+		ParentRoot:        parentRoot,
+		Attestations:      blockBody.Attestations,
+		AttesterSlashings: blockBody.AttesterSlashings,
+		ProposerSlashings: blockBody.ProposerSlashings,
+		SyncBits:          blockBody.SyncAggregate.SyncCommitteeBits.Count(),
+	}, nil
+}
+
+// BlockInputFromSignedBlock reads the input of a block of the chain, of any fork
+func BlockInputFromSignedBlock(block *spec.VersionedSignedBeaconBlock) (BlockInput, error) {
+	slot, err := block.Slot()
+	if err != nil {
+		return BlockInput{}, fmt.Errorf("could not get block slot: %s", err)
+	}
+	parentRoot, err := block.ParentRoot()
+	if err != nil {
+		return BlockInput{}, fmt.Errorf("could not get parent root of block %d: %s", slot, err)
+	}
+	attestations, err := block.Attestations()
+	if err != nil {
+		return BlockInput{}, fmt.Errorf("could not get attestations of block %d: %s", slot, err)
+	}
+	attesterSlashings, err := block.AttesterSlashings()
+	if err != nil {
+		return BlockInput{}, fmt.Errorf("could not get attester slashings of block %d: %s", slot, err)
+	}
+	proposerSlashings, err := block.ProposerSlashings()
+	if err != nil {
+		return BlockInput{}, fmt.Errorf("could not get proposer slashings of block %d: %s", slot, err)
+	}
+
+	input := BlockInput{
+		Version:           block.Version,
+		Slot:              slot,
+		ParentRoot:        parentRoot,
+		Attestations:      attestations,
+		AttesterSlashings: attesterSlashings,
+		ProposerSlashings: proposerSlashings,
+	}
+	if block.Version != spec.DataVersionPhase0 {
+		syncAggregate, err := block.SyncAggregate()
+		if err != nil {
+			return BlockInput{}, fmt.Errorf("could not get sync aggregate of block %d: %s", slot, err)
+		}
+		input.SyncBits = syncAggregate.SyncCommitteeBits.Count()
+	}
+	return input, nil
+}
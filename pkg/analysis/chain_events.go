@@ -0,0 +1,208 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	api_v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/postgresql"
+	"github.com/migalabs/streameth/pkg/tracing"
+	"github.com/migalabs/streameth/pkg/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	AttesterSlashingType = "attester"
+	ProposerSlashingType = "proposer"
+)
+
+// Topics the Api can not decode, they are read from the raw event stream:
+// block_gossip is not known by the Api and bls_to_execution_change is decoded without its signature
+var RawEventTopics = map[string]bool{
+	utils.BlockGossipMetric: true,
+	utils.BLSChangeMetric:   true,
+}
+
+// the same as the block event, sent when the block is received from gossip instead of imported
+type blockGossipEvent struct {
+	Slot  string `json:"slot"`
+	Block string `json:"block"`
+}
+
+// HandleEvent stores the events of the topics in utils.EventMetrics decoded by the Api
+func (b *ClientLiveData) HandleEvent(event *api_v1.Event) {
+	timestamp := b.now()
+	log := b.log.WithField("routine", "event")
+
+	if event.Data == nil {
+		return
+	}
+
+	switch data := event.Data.(type) {
+	case *api_v1.BlockEvent:
+		b.persistEvent(event.Topic, data.Slot, postgresql.InsertNewBlockEvent,
+			b.label,
+			event.Topic,
+			uint64(data.Slot),
+			data.Block.String(),
+			data.ExecutionOptimistic,
+			timestamp)
+
+	case *api_v1.BlobSidecarEvent:
+		b.persistEvent(event.Topic, data.Slot, postgresql.InsertNewBlobSidecar,
+			b.label,
+			uint64(data.Slot),
+			data.BlockRoot.String(),
+			uint64(data.Index),
+			data.KZGCommitment.String(),
+			data.VersionedHash.String(),
+			timestamp)
+
+	case *api_v1.FinalizedCheckpointEvent:
+		b.persistEvent(event.Topic, phase0.Slot(uint64(data.Epoch)*utils.SlotsPerEpoch), postgresql.InsertNewFinalizedCheckpoint,
+			b.label,
+			uint64(data.Epoch),
+			data.Block.String(),
+			data.State.String(),
+			timestamp)
+
+	case *phase0.SignedVoluntaryExit:
+		b.persistEvent(event.Topic, phase0.Slot(uint64(data.Message.Epoch)*utils.SlotsPerEpoch), postgresql.InsertNewVoluntaryExit,
+			b.label,
+			uint64(data.Message.Epoch),
+			uint64(data.Message.ValidatorIndex),
+			timestamp)
+
+	case *altair.SignedContributionAndProof:
+		contribution := data.Message.Contribution
+		b.persistEvent(event.Topic, contribution.Slot, postgresql.InsertNewSyncContribution,
+			b.label,
+			uint64(contribution.Slot),
+			contribution.SubcommitteeIndex,
+			uint64(data.Message.AggregatorIndex),
+			contribution.BeaconBlockRoot.String(),
+			contribution.AggregationBits.Count(),
+			timestamp)
+
+	case *api_v1.PayloadAttributesEvent:
+		feeRecipient, payloadTimestamp, withdrawals := payloadAttributes(data.Data)
+		b.persistEvent(event.Topic, data.Data.ProposalSlot, postgresql.InsertNewPayloadAttributes,
+			b.label,
+			uint64(data.Data.ProposalSlot),
+			uint64(data.Data.ProposerIndex),
+			data.Data.ParentBlockRoot.String(),
+			data.Data.ParentBlockHash.String(),
+			data.Data.ParentBlockNumber,
+			feeRecipient,
+			payloadTimestamp,
+			withdrawals,
+			timestamp)
+
+	case *phase0.AttesterSlashing:
+		indices := intersection(data.Attestation1.AttestingIndices, data.Attestation2.AttestingIndices)
+		b.persistEvent(event.Topic, data.Attestation1.Data.Slot, postgresql.InsertNewSlashingEvent,
+			b.label,
+			AttesterSlashingType,
+			uint64(data.Attestation1.Data.Slot),
+			joinIndices(indices),
+			timestamp)
+
+	case *phase0.ProposerSlashing:
+		header := data.SignedHeader1.Message
+		b.persistEvent(event.Topic, header.Slot, postgresql.InsertNewSlashingEvent,
+			b.label,
+			ProposerSlashingType,
+			uint64(header.Slot),
+			joinIndices([]uint64{uint64(header.ProposerIndex)}),
+			timestamp)
+
+	default:
+		log.Warnf("unexpected event %s: %T", event.Topic, event.Data)
+	}
+}
+
+// HandleRawEvent stores the events of the topics in RawEventTopics
+func (b *ClientLiveData) HandleRawEvent(topic string, data []byte) {
+	timestamp := b.now()
+	log := b.log.WithField("routine", "event")
+
+	switch topic {
+	case utils.BlockGossipMetric:
+		event := blockGossipEvent{}
+		if err := json.Unmarshal(data, &event); err != nil {
+			log.Errorf("could not decode %s event: %s", topic, err)
+			return
+		}
+		slot, err := strconv.ParseUint(event.Slot, 10, 64)
+		if err != nil {
+			log.Errorf("invalid slot in %s event: %s", topic, err)
+			return
+		}
+		b.persistEvent(topic, phase0.Slot(slot), postgresql.InsertNewBlockEvent,
+			b.label,
+			topic,
+			slot,
+			event.Block,
+			false,
+			timestamp)
+
+	case utils.BLSChangeMetric:
+		event := capella.SignedBLSToExecutionChange{}
+		if err := json.Unmarshal(data, &event); err != nil {
+			log.Errorf("could not decode %s event: %s", topic, err)
+			return
+		}
+		b.persistEvent(topic, 0, postgresql.InsertNewBLSChange,
+			b.label,
+			uint64(event.Message.ValidatorIndex),
+			event.Message.FromBLSPubkey.String(),
+			fmt.Sprintf("%#x", event.Message.ToExecutionAddress),
+			timestamp)
+
+	default:
+		log.Warnf("unexpected raw event %s", topic)
+	}
+}
+
+func (b *ClientLiveData) persistEvent(topic string, slot phase0.Slot, query string, params ...interface{}) {
+	ctx, span := tracing.Tracer().Start(b.ctx, "HandleEvent", trace.WithAttributes(
+		tracing.SlotKey.Int64(int64(slot)),
+		tracing.LabelKey.String(b.label),
+		tracing.ClientKey.String(b.GetClient()),
+		attribute.String("topic", topic)))
+	defer span.End()
+
+	b.DBClient.Persist(ctx, postgresql.WriteTask{
+		QueryString: query,
+		Params:      params,
+		Type:        postgresql.RecordEvent,
+	})
+}
+
+// the payload attributes change with the fork, read the common fields
+func payloadAttributes(data *api_v1.PayloadAttributesData) (string, uint64, int) {
+	switch {
+	case data.V3 != nil:
+		return data.V3.SuggestedFeeRecipient.String(), data.V3.Timestamp, len(data.V3.Withdrawals)
+	case data.V2 != nil:
+		return data.V2.SuggestedFeeRecipient.String(), data.V2.Timestamp, len(data.V2.Withdrawals)
+	case data.V1 != nil:
+		return data.V1.SuggestedFeeRecipient.String(), data.V1.Timestamp, 0
+	default:
+		return "", 0, 0
+	}
+}
+
+func joinIndices(indices []uint64) string {
+	items := make([]string, len(indices))
+	for i, item := range indices {
+		items[i] = strconv.FormatUint(item, 10)
+	}
+	return strings.Join(items, ",")
+}
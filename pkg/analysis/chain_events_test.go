@@ -0,0 +1,213 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	api_v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/clock"
+	"github.com/migalabs/streameth/pkg/postgresql"
+	"github.com/prysmaticlabs/go-bitfield"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePersister keeps the records instead of writing them
+type fakePersister struct {
+	mu    sync.Mutex
+	tasks []postgresql.WriteTask
+}
+
+func (p *fakePersister) Persist(ctx context.Context, task postgresql.WriteTask) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tasks = append(p.tasks, task)
+}
+
+// Pending removes and returns the records of the record type
+func (p *fakePersister) Pending(recordType string) []postgresql.WriteTask {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	tasks, rest := make([]postgresql.WriteTask, 0), make([]postgresql.WriteTask, 0)
+	for _, task := range p.tasks {
+		if task.Type == recordType {
+			tasks = append(tasks, task)
+		} else {
+			rest = append(rest, task)
+		}
+	}
+	p.tasks = rest
+	return tasks
+}
+
+func testEventAnalyzer() (*ClientLiveData, *fakePersister, time.Time) {
+	now := time.Unix(1700000000, 0)
+	db := &fakePersister{}
+	b := &ClientLiveData{
+		ctx:      context.Background(),
+		log:      log.WithField("label", "node"),
+		DBClient: db,
+		clock:    clock.NewFake(now),
+		client:   "lighthouse",
+		label:    "node",
+	}
+	return b, db, now
+}
+
+// persistedEvent returns the params of the only event record queued
+func persistedEvent(t *testing.T, db *fakePersister) postgresql.WriteTask {
+	tasks := db.Pending(postgresql.RecordEvent)
+	if len(tasks) != 1 {
+		t.Fatalf("expected one event record, got %d", len(tasks))
+	}
+	return tasks[0]
+}
+
+func TestHandleEvent(t *testing.T) {
+	root := phase0.Root{0xaa}
+	syncBits := bitfield.NewBitvector128()
+	syncBits.SetBitAt(1, true)
+	syncBits.SetBitAt(5, true)
+
+	tests := []struct {
+		topic  string
+		data   interface{}
+		query  string
+		params func(now time.Time) []interface{}
+	}{
+		{
+			topic: "block",
+			data:  &api_v1.BlockEvent{Slot: 100, Block: root, ExecutionOptimistic: true},
+			query: postgresql.InsertNewBlockEvent,
+			params: func(now time.Time) []interface{} {
+				return []interface{}{"node", "block", uint64(100), root.String(), true, now}
+			},
+		},
+		{
+			topic: "blob_sidecar",
+			data: &api_v1.BlobSidecarEvent{
+				BlockRoot:     root,
+				Slot:          100,
+				Index:         2,
+				KZGCommitment: deneb.KZGCommitment{0x01},
+				VersionedHash: deneb.VersionedHash{0x02},
+			},
+			query: postgresql.InsertNewBlobSidecar,
+			params: func(now time.Time) []interface{} {
+				return []interface{}{"node", uint64(100), root.String(), uint64(2),
+					deneb.KZGCommitment{0x01}.String(), deneb.VersionedHash{0x02}.String(), now}
+			},
+		},
+		{
+			topic: "finalized_checkpoint",
+			data:  &api_v1.FinalizedCheckpointEvent{Epoch: 3, Block: root, State: phase0.Root{0xbb}},
+			query: postgresql.InsertNewFinalizedCheckpoint,
+			params: func(now time.Time) []interface{} {
+				return []interface{}{"node", uint64(3), root.String(), phase0.Root{0xbb}.String(), now}
+			},
+		},
+		{
+			topic: "voluntary_exit",
+			data:  &phase0.SignedVoluntaryExit{Message: &phase0.VoluntaryExit{Epoch: 4, ValidatorIndex: 12}},
+			query: postgresql.InsertNewVoluntaryExit,
+			params: func(now time.Time) []interface{} {
+				return []interface{}{"node", uint64(4), uint64(12), now}
+			},
+		},
+		{
+			topic: "contribution_and_proof",
+			data: &altair.SignedContributionAndProof{Message: &altair.ContributionAndProof{
+				AggregatorIndex: 7,
+				Contribution: &altair.SyncCommitteeContribution{
+					Slot:              101,
+					BeaconBlockRoot:   root,
+					SubcommitteeIndex: 3,
+					AggregationBits:   syncBits,
+				},
+			}},
+			query: postgresql.InsertNewSyncContribution,
+			params: func(now time.Time) []interface{} {
+				return []interface{}{"node", uint64(101), uint64(3), uint64(7), root.String(), uint64(2), now}
+			},
+		},
+		{
+			topic: "payload_attributes",
+			data: &api_v1.PayloadAttributesEvent{Data: &api_v1.PayloadAttributesData{
+				ProposerIndex:     9,
+				ProposalSlot:      102,
+				ParentBlockNumber: 500,
+				ParentBlockRoot:   root,
+				ParentBlockHash:   phase0.Hash32{0xcc},
+				V2: &api_v1.PayloadAttributesV2{
+					Timestamp:             1700000024,
+					SuggestedFeeRecipient: bellatrix.ExecutionAddress{0xdd},
+					Withdrawals:           []*capella.Withdrawal{{}, {}},
+				},
+			}},
+			query: postgresql.InsertNewPayloadAttributes,
+			params: func(now time.Time) []interface{} {
+				return []interface{}{"node", uint64(102), uint64(9), root.String(), phase0.Hash32{0xcc}.String(), uint64(500),
+					bellatrix.ExecutionAddress{0xdd}.String(), uint64(1700000024), 2, now}
+			},
+		},
+		{
+			topic: "attester_slashing",
+			data: &phase0.AttesterSlashing{
+				Attestation1: &phase0.IndexedAttestation{AttestingIndices: []uint64{1, 2, 3}, Data: &phase0.AttestationData{Slot: 90}},
+				Attestation2: &phase0.IndexedAttestation{AttestingIndices: []uint64{2, 3, 4}, Data: &phase0.AttestationData{Slot: 90}},
+			},
+			query: postgresql.InsertNewSlashingEvent,
+			params: func(now time.Time) []interface{} {
+				return []interface{}{"node", AttesterSlashingType, uint64(90), "2,3", now}
+			},
+		},
+		{
+			topic: "proposer_slashing",
+			data: &phase0.ProposerSlashing{
+				SignedHeader1: &phase0.SignedBeaconBlockHeader{Message: &phase0.BeaconBlockHeader{Slot: 95, ProposerIndex: 21}},
+				SignedHeader2: &phase0.SignedBeaconBlockHeader{Message: &phase0.BeaconBlockHeader{Slot: 95, ProposerIndex: 21}},
+			},
+			query: postgresql.InsertNewSlashingEvent,
+			params: func(now time.Time) []interface{} {
+				return []interface{}{"node", ProposerSlashingType, uint64(95), "21", now}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.topic, func(t *testing.T) {
+			b, db, now := testEventAnalyzer()
+			b.HandleEvent(&api_v1.Event{Topic: test.topic, Data: test.data})
+			task := persistedEvent(t, db)
+			assert.Equal(t, test.query, task.QueryString)
+			assert.Equal(t, test.params(now), task.Params)
+		})
+	}
+}
+
+func TestHandleRawEvent(t *testing.T) {
+	b, db, now := testEventAnalyzer()
+	b.HandleRawEvent("block_gossip", []byte(`{"slot":"10","block":"0x01"}`))
+	task := persistedEvent(t, db)
+	assert.Equal(t, postgresql.InsertNewBlockEvent, task.QueryString)
+	assert.Equal(t, []interface{}{"node", "block_gossip", uint64(10), "0x01", false, now}, task.Params)
+
+	pubkey := "0x" + strings.Repeat("ab", 48)
+	b.HandleRawEvent("bls_to_execution_change", []byte(fmt.Sprintf(`{"message":{"validator_index":"33","from_bls_pubkey":"%s","to_execution_address":"0x%s"},"signature":"0x%s"}`,
+		pubkey, strings.Repeat("ee", 20), strings.Repeat("00", 96))))
+	task = persistedEvent(t, db)
+	assert.Equal(t, postgresql.InsertNewBLSChange, task.QueryString)
+	assert.Equal(t, []interface{}{"node", uint64(33), pubkey, "0x" + strings.Repeat("ee", 20), now}, task.Params)
+
+	// events that can not be decoded are not stored
+	b.HandleRawEvent("block_gossip", []byte(`{"slot":"ten","block":"0x01"}`))
+	assert.Empty(t, db.Pending(postgresql.RecordEvent))
+}
@@ -3,18 +3,18 @@ package analysis
 import (
 	"encoding/hex"
 	"fmt"
-	"time"
 
 	api_v1 "github.com/attestantio/go-eth2-client/api/v1"
 
-	"github.com/attestantio/go-eth2-client/api"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/migalabs/streameth/pkg/postgresql"
+	"github.com/migalabs/streameth/pkg/tracing"
 	"github.com/migalabs/streameth/pkg/utils"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func (b *ClientLiveData) HandleHeadEvent(event *api_v1.Event) {
-	timestamp := time.Now()
+	timestamp := b.now()
 	log := b.log.WithField("routine", "head-event")
 
 	if event.Data == nil {
@@ -22,22 +22,29 @@ func (b *ClientLiveData) HandleHeadEvent(event *api_v1.Event) {
 	}
 
 	data := event.Data.(*api_v1.HeadEvent) // cast to head event
+	ctx, span := tracing.Tracer().Start(b.ctx, "HandleHeadEvent", trace.WithAttributes(
+		tracing.SlotKey.Int64(int64(data.Slot)),
+		tracing.LabelKey.String(b.label),
+		tracing.ClientKey.String(b.GetClient())))
+	defer span.End()
 	log.Infof("Received a new event: slot %d", data.Slot)
+	b.recordHead(data.Slot, data.Block, timestamp)
 	// <-b.ProcessNewHead // wait for the block proposal to be done
 	// we only receive the block hash, get the new block
-	newBlock, err := b.Eth2Provider.Api.SignedBeaconBlock(b.ctx, &api.SignedBeaconBlockOpts{
-		Block: fmt.Sprintf("%#x", data.Block),
-	})
+	newBlock, err := b.fetchSignedBlock(ctx, fmt.Sprintf("%#x", data.Block))
 
+	if err != nil {
+		log.Errorf("could not request new block: %s", err)
+		return
+	}
 	if newBlock == nil {
 		log.Errorf("the block is not available: %d", data.Slot)
 		return
 	}
-	if err != nil || newBlock == nil {
-		log.Errorf("could not request new block: %s", err)
-		return
+	b.UpdateAttestations(*newBlock) // now update the attestations with the new head block in the chain
+	if b.watchlist != nil {
+		b.trackWatchedInclusions(newBlock)
 	}
-	b.UpdateAttestations(*newBlock.Data) // now update the attestations with the new head block in the chain
 
 	// Track if there is any missing slot
 	if b.CurrentHeadSlot != 0 && // we are not at the beginning of the run
@@ -49,12 +56,23 @@ func (b *ClientLiveData) HandleHeadEvent(event *api_v1.Event) {
 			writeTask := postgresql.WriteTask{
 				QueryString: postgresql.InsertNewMissedBlock,
 				Params:      params,
+				CopyTarget:  postgresql.MissedBlockCopyTarget,
+				Type:        postgresql.RecordHead,
 			}
-			b.DBClient.WriteChan <- writeTask // store
+			b.DBClient.Persist(ctx, writeTask) // store
 		}
 	}
+	if b.CurrentHeadSlot != 0 &&
+		uint64(data.Slot)/utils.SlotsPerEpoch > b.CurrentHeadSlot/utils.SlotsPerEpoch {
+		// new epoch, keep a snapshot of the history in case of restart
+		go func() {
+			if err := b.SaveHistory(); err != nil {
+				log.Errorf("could not save history: %s", err)
+			}
+		}()
+	}
 	b.CurrentHeadSlot = uint64(data.Slot)
-	if b.CurrentHeadSlot%utils.SlotsPerEpoch == (utils.SlotsPerEpoch / 2) {
+	if b.CurrentHeadSlot%utils.SlotsPerEpoch == (utils.SlotsPerEpoch/2) && !b.offline {
 		// by halfway the epoch prepare proposers for next epoch
 		epoch := phase0.Epoch((b.CurrentHeadSlot)/utils.SlotsPerEpoch) + 1 // next epoch
 
@@ -64,17 +82,20 @@ func (b *ClientLiveData) HandleHeadEvent(event *api_v1.Event) {
 	params = append(params, int(data.Slot))
 	params = append(params, b.label)
 	params = append(params, timestamp)
+	params = append(params, b.clockOffsetParam())
 	writeTask := postgresql.WriteTask{
 		QueryString: postgresql.InsertNewBlock,
 		Params:      params,
+		CopyTarget:  postgresql.BlockCopyTarget,
+		Type:        postgresql.RecordHead,
 	}
-	b.DBClient.WriteChan <- writeTask // store
+	b.DBClient.Persist(ctx, writeTask) // store
 
 }
 
 // When the node receives a new attestation, this function is tiggered
 func (b *ClientLiveData) HandleAttestationEvent(event *api_v1.Event) {
-	timestamp := time.Now()
+	timestamp := b.now()
 
 	log := b.log.WithField("routine", "attestation-event")
 
@@ -83,6 +104,11 @@ func (b *ClientLiveData) HandleAttestationEvent(event *api_v1.Event) {
 	}
 
 	data := event.Data.(*phase0.Attestation) // cast
+	ctx, span := tracing.Tracer().Start(b.ctx, "HandleAttestationEvent", trace.WithAttributes(
+		tracing.SlotKey.Int64(int64(data.Data.Slot)),
+		tracing.LabelKey.String(b.label),
+		tracing.ClientKey.String(b.GetClient())))
+	defer span.End()
 	log.Debugf("Received a new event: slot %d, committee: %d", uint64(data.Data.Slot), uint64(data.Data.Index))
 	// With the beacon committee we can identify the attesting validators
 	// Will not track this for now
@@ -108,6 +134,7 @@ func (b *ClientLiveData) HandleAttestationEvent(event *api_v1.Event) {
 	baseParams = append(baseParams, hex.EncodeToString(data.Data.Source.Root[:]))
 	baseParams = append(baseParams, hex.EncodeToString(data.Data.Target.Root[:]))
 	baseParams = append(baseParams, hex.EncodeToString(data.Data.BeaconBlockRoot[:]))
+	baseParams = append(baseParams, b.clockOffsetParam())
 
 	// for each attesting validator, not use for now
 	// for _, item := range attestingVals {
@@ -122,16 +149,23 @@ func (b *ClientLiveData) HandleAttestationEvent(event *api_v1.Event) {
 	writeTask := postgresql.WriteTask{
 		QueryString: postgresql.InsertNewAtt,
 		Params:      baseParams,
+		CopyTarget:  postgresql.AttCopyTarget,
+		Type:        postgresql.RecordAttestation,
 	}
 
-	b.DBClient.WriteChan <- writeTask // send task to be written
+	b.DBClient.Persist(ctx, writeTask) // send task to be written
+	b.observeArrival(data, timestamp)
+
+	if b.watchlist != nil {
+		b.trackWatchedArrival(data, timestamp)
+	}
 
-	log.Tracef("Finished processing event in %f seconds", time.Since(timestamp).Seconds())
+	log.Tracef("Finished processing event in %f seconds", b.now().Sub(timestamp).Seconds())
 
 }
 
 func (b *ClientLiveData) HandleReOrgEvent(event *api_v1.Event) {
-	timestamp := time.Now()
+	timestamp := b.now()
 	log := b.log.WithField("routine", "reorg-event")
 
 	if event.Data == nil {
@@ -139,6 +173,11 @@ func (b *ClientLiveData) HandleReOrgEvent(event *api_v1.Event) {
 	}
 
 	data := event.Data.(*api_v1.ChainReorgEvent) // cast to head event
+	ctx, span := tracing.Tracer().Start(b.ctx, "HandleReOrgEvent", trace.WithAttributes(
+		tracing.SlotKey.Int64(int64(data.Slot)),
+		tracing.LabelKey.String(b.label),
+		tracing.ClientKey.String(b.GetClient())))
+	defer span.End()
 	log.Debugf("New Reorg Evenet")
 
 	baseParams := make([]interface{}, 0)
@@ -152,9 +191,11 @@ func (b *ClientLiveData) HandleReOrgEvent(event *api_v1.Event) {
 	writeTask := postgresql.WriteTask{
 		QueryString: postgresql.InsertNewReorg,
 		Params:      baseParams,
+		CopyTarget:  postgresql.ReorgCopyTarget,
+		Type:        postgresql.RecordReorg,
 	}
 
-	b.DBClient.WriteChan <- writeTask // send task to be written
+	b.DBClient.Persist(ctx, writeTask) // send task to be written
 
 }
 
@@ -0,0 +1,94 @@
+package analysis
+
+import (
+	"time"
+
+	"github.com/attestantio/go-eth2-client/api"
+	api_v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/postgresql"
+)
+
+// where the finality checkpoints were observed
+const (
+	FinalitySourcePoll  = "poll"
+	FinalitySourceEvent = "event"
+)
+
+// FinalityStatus has the checkpoints of the head state of the node
+type FinalityStatus struct {
+	JustifiedEpoch phase0.Epoch
+	JustifiedRoot  phase0.Root
+	FinalizedEpoch phase0.Epoch
+	FinalizedRoot  phase0.Root
+	Timestamp      time.Time // when they were first observed
+}
+
+// UpdateFinality polls the finality checkpoints of the node, and stores them if they changed
+func (b *ClientLiveData) UpdateFinality(source string) {
+	log := b.log.WithField("routine", "finality")
+	timestamp := b.now()
+
+	finality, err := b.Eth2Provider.Api.Finality(b.ctx, &api.FinalityOpts{
+		State: "head",
+	})
+	if err != nil {
+		log.Errorf("could not poll finality checkpoints: %s", err)
+		return
+	}
+	status := FinalityStatus{
+		JustifiedEpoch: finality.Data.Justified.Epoch,
+		JustifiedRoot:  finality.Data.Justified.Root,
+		FinalizedEpoch: finality.Data.Finalized.Epoch,
+		FinalizedRoot:  finality.Data.Finalized.Root,
+		Timestamp:      timestamp,
+	}
+
+	b.nodeStatusMu.Lock()
+	previous := b.finality
+	changed := previous.JustifiedEpoch != status.JustifiedEpoch ||
+		previous.JustifiedRoot != status.JustifiedRoot ||
+		previous.FinalizedEpoch != status.FinalizedEpoch ||
+		previous.FinalizedRoot != status.FinalizedRoot ||
+		previous.Timestamp.IsZero()
+	if changed {
+		b.finality = status
+	}
+	b.nodeStatusMu.Unlock()
+
+	if !changed {
+		return
+	}
+	log.Infof("justified epoch %d, finalized epoch %d (%s)", status.JustifiedEpoch, status.FinalizedEpoch, source)
+
+	params := make([]interface{}, 0)
+	params = append(params, b.label)
+	params = append(params, b.GetClient())
+	params = append(params, uint64(status.JustifiedEpoch))
+	params = append(params, status.JustifiedRoot.String())
+	params = append(params, uint64(status.FinalizedEpoch))
+	params = append(params, status.FinalizedRoot.String())
+	params = append(params, source)
+	params = append(params, timestamp)
+	writeTask := postgresql.WriteTask{
+		QueryString: postgresql.InsertNewFinality,
+		Params:      params,
+		Type:        postgresql.RecordNodeStatus,
+	}
+	b.DBClient.Persist(b.ctx, writeTask) // store
+}
+
+// HandleFinalizedCheckpointEvent reads the new checkpoints as soon as the node finalizes an epoch
+func (b *ClientLiveData) HandleFinalizedCheckpointEvent(event *api_v1.Event) {
+	if event.Data == nil {
+		return
+	}
+	b.UpdateFinality(FinalitySourceEvent)
+}
+
+// GetFinality returns the last finality checkpoints reported by the node
+func (b *ClientLiveData) GetFinality() FinalityStatus {
+	b.nodeStatusMu.RLock()
+	defer b.nodeStatusMu.RUnlock()
+	return b.finality
+}
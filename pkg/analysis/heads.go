@@ -0,0 +1,116 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/api"
+	api_v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+)
+
+const (
+	HeadHistoryLength = 64              // slots of head roots kept to compare chains
+	ChainRootTimeout  = 2 * time.Second // a slow node does not hold the comparison of the heads
+)
+
+// HeadStatus is the last head reported by the node
+type HeadStatus struct {
+	Slot      phase0.Slot
+	Root      phase0.Root
+	Timestamp time.Time
+}
+
+// HandleHeadRootEvent only records the new head, used when the proposals are not analyzed
+func (b *ClientLiveData) HandleHeadRootEvent(event *api_v1.Event) {
+	if event.Data == nil {
+		return
+	}
+	data := event.Data.(*api_v1.HeadEvent)
+	b.recordHead(data.Slot, data.Block, b.now())
+}
+
+// recordHead keeps the head roots reported by the node per slot
+func (b *ClientLiveData) recordHead(slot phase0.Slot, root phase0.Root, timestamp time.Time) {
+	b.headMu.Lock()
+	defer b.headMu.Unlock()
+
+	if b.headRoots == nil {
+		b.headRoots = make(map[phase0.Slot]phase0.Root)
+	}
+	for i := range b.headRoots {
+		// older than the history, or replaced by a reorg to an earlier slot
+		if i+HeadHistoryLength < slot || i > slot {
+			delete(b.headRoots, i)
+		}
+	}
+	b.headRoots[slot] = root
+	b.head = HeadStatus{
+		Slot:      slot,
+		Root:      root,
+		Timestamp: timestamp,
+	}
+}
+
+// GetHead returns the last head reported by the node
+func (b *ClientLiveData) GetHead() HeadStatus {
+	b.headMu.RLock()
+	defer b.headMu.RUnlock()
+	return b.head
+}
+
+// ChainRootAt returns the root of the block at the slot in the chain of the node head,
+// skipped is true if that chain has no block at the slot.
+// The roots reported in the head events are used first, the slots the node did not report
+// (skipped, or imported in a batch) are asked to the node, once per head
+func (b *ClientLiveData) ChainRootAt(slot phase0.Slot) (root phase0.Root, skipped bool, err error) {
+	b.headMu.Lock()
+	if root, ok := b.headRoots[slot]; ok {
+		b.headMu.Unlock()
+		return root, false, nil
+	}
+	if b.chainRootsHead != b.head.Root || b.chainRoots == nil {
+		// resolved against a previous head, that may be on another branch
+		b.chainRoots = make(map[phase0.Slot]*phase0.Root)
+		b.chainRootsHead = b.head.Root
+	}
+	resolved, ok := b.chainRoots[slot]
+	head := b.head.Root
+	b.headMu.Unlock()
+	if ok {
+		if resolved == nil {
+			return phase0.Root{}, true, nil
+		}
+		return *resolved, false, nil
+	}
+	if b.offline {
+		return phase0.Root{}, false, fmt.Errorf("there is no node to ask")
+	}
+
+	// the head events are not blocked by the request
+	ctx, cancel := context.WithTimeout(b.ctx, ChainRootTimeout)
+	defer cancel()
+	response, err := b.Eth2Provider.Api.BeaconBlockRoot(ctx, &api.BeaconBlockRootOpts{
+		Block: fmt.Sprintf("%d", slot),
+	})
+	var apiErr *api.Error
+	switch {
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+		resolved, skipped = nil, true
+	case err != nil:
+		return phase0.Root{}, false, fmt.Errorf("could not get block root at slot %d: %s", slot, err)
+	default:
+		resolved = response.Data
+		root = *response.Data
+	}
+
+	b.headMu.Lock()
+	if b.chainRootsHead == head {
+		b.chainRoots[slot] = resolved
+	}
+	b.headMu.Unlock()
+	return root, skipped, nil
+}
@@ -3,14 +3,22 @@ package analysis
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/attestantio/go-eth2-client/api"
 	"github.com/attestantio/go-eth2-client/spec"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
-	"github.com/migalabs/streameth/pkg/utils"
+	"github.com/migalabs/streameth/pkg/clock"
 	"github.com/prysmaticlabs/go-bitfield"
 )
 
+const (
+	AttHistoryLength       = 32 // attestations can only reference 32 slots back
+	BlockRootHistoryLength = 64
+	HistoryRetries         = 5
+	HistoryRetryBackoff    = time.Second
+)
+
 // This method receives a new head block and updates the attestation in the history
 // So, when a block is to be proposed, we can check the history to identify new votes
 func (b *ClientLiveData) UpdateAttestations(block spec.VersionedSignedBeaconBlock) {
@@ -21,14 +29,19 @@ func (b *ClientLiveData) UpdateAttestations(block spec.VersionedSignedBeaconBloc
 		log.Errorf("could not get block slot from block proposal: %s", err)
 	}
 
-	blockBody := utils.BlockBodyFromVersionedBlock(block)
+	// any fork, so history can be built from old blocks
+	attestations, err := block.Attestations()
 	if err != nil {
-		log.Errorf("could not get block body from block proposal: %s", err)
+		log.Errorf("could not get attestations from block %d: %s", slot, err)
+		return
 	}
 
 	log.Tracef("updating attestations using block: %d", slot)
 
-	for _, attestation := range blockBody.Attestations {
+	b.historyMu.Lock()
+	defer b.historyMu.Unlock()
+
+	for _, attestation := range attestations {
 		slot := attestation.Data.Slot
 
 		if _, exists := b.AttHistory[slot]; !exists {
@@ -55,63 +68,136 @@ func (b *ClientLiveData) UpdateAttestations(block spec.VersionedSignedBeaconBloc
 
 }
 
-// This function is only called at the beginning of the run, so we build an initial attestation history
+// This function is called at the beginning of the run, so we build an initial attestation history
 // to judge new block proposals
-func (b *ClientLiveData) BuildHistory() bool {
-	log := b.log.WithField("routine", "history-build")
-
+// Slots already in the history (restored from a snapshot) are not requested again
+func (b *ClientLiveData) BuildHistory() error {
 	headOpts := api.BeaconBlockHeaderOpts{
 		Block: "head",
 	}
 
-	currentHead, err := b.Eth2Provider.Api.BeaconBlockHeader(b.ctx, &headOpts)
-
+	var headSlot phase0.Slot
+	err := b.withRetries(func() error {
+		currentHead, err := b.Eth2Provider.Api.BeaconBlockHeader(b.ctx, &headOpts)
+		if err != nil {
+			return err
+		}
+		headSlot = currentHead.Data.Header.Message.Slot
+		return nil
+	})
 	if err != nil {
-		log.Panicf("could not retrieve current head: %s", err)
+		return fmt.Errorf("could not retrieve current head: %s", err)
 	}
-	headSlot := currentHead.Data.Header.Message.Slot
 
-	if _, ok := b.BlockRootHistory[headSlot]; ok {
-		// at this point we have already filled the historical records
-		return true
-	}
+	return b.fillHistory(headSlot)
+}
 
-	// at this point there are no historical records
+// fillHistory requests the blocks that can be referenced from the given slot and are not in the history yet
+func (b *ClientLiveData) fillHistory(headSlot phase0.Slot) error {
+	log := b.log.WithField("routine", "history-build")
 
-	for i := headSlot; i >= headSlot-64; i-- {
-		if _, ok := b.BlockRootHistory[i]; ok {
-			// at this point we have already filled the historical records
-			return false // but we had to fill something
+	b.pruneHistory(headSlot)
+	if b.historyRestored && !b.offline {
+		if err := b.checkRestoredHistory(b.canonicalBlockRoot); err != nil {
+			return err
 		}
+		b.historyRestored = false
+	}
 
-		log.Debugf("filling block history, slot: %d\n", i)
-		block, err := b.Eth2Provider.Api.SignedBeaconBlock(b.ctx, &api.SignedBeaconBlockOpts{
-			Block: fmt.Sprintf("%d", i),
-		})
+	filled := 0
+	for i := headSlot; i+BlockRootHistoryLength >= headSlot && i > 0; i-- {
+		b.historyMu.RLock()
+		_, ok := b.BlockRootHistory[i]
+		b.historyMu.RUnlock()
+		if ok {
+			// already in the history
+			continue
+		}
 
+		log.Debugf("filling block history, slot: %d\n", i)
+		block, missed, err := b.fetchBlock(i)
 		if err != nil {
-			if strings.Contains(err.Error(), "404") {
-				log.Debugf("Missed block!")
-				continue
-			} else {
-				log.Panicf("could not retrieve historical block at slot: %d: %s", i, err)
-			}
+			return fmt.Errorf("could not retrieve historical block at slot: %d: %s", i, err)
 		}
-		if block == nil {
-			log.Debugf("Unknown error with block %d\n", i)
+		if missed {
+			log.Debugf("Missed block!")
 			continue
 		}
-		root, err := block.Data.Root()
+		root, err := block.Root()
 		if err != nil {
-			log.Panicf("could not retrieve block root from block %d: %s", i, err)
+			return fmt.Errorf("could not retrieve block root from block %d: %s", i, err)
 		}
+		b.historyMu.Lock()
 		b.BlockRootHistory[i] = root
+		b.historyMu.Unlock()
 
-		if i+32 >= headSlot {
-			b.UpdateAttestations(*block.Data)
+		if i+AttHistoryLength >= headSlot {
+			b.UpdateAttestations(*block)
 		}
+		filled++
+	}
+	log.Infof("history built up to slot %d, %d blocks requested", headSlot, filled)
+
+	return nil
+}
+
+// fetchBlock requests the canonical block at the slot, with retries
+// missed is true if there is no block at the slot
+func (b *ClientLiveData) fetchBlock(slot phase0.Slot) (*spec.VersionedSignedBeaconBlock, bool, error) {
+	var block *spec.VersionedSignedBeaconBlock
+	missed := false
+	err := b.withRetries(func() error {
+		var err error
+		block, err = b.fetchSignedBlock(b.ctx, fmt.Sprintf("%d", slot))
+		if err != nil && strings.Contains(err.Error(), "404") {
+			missed = true
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if missed || block == nil {
+		return nil, true, nil
+	}
+	return block, false, nil
+}
 
+// Remove the entries that can not be referenced anymore by blocks at the given slot
+func (b *ClientLiveData) pruneHistory(slot phase0.Slot) {
+	b.historyMu.Lock()
+	defer b.historyMu.Unlock()
+
+	for i := range b.AttHistory {
+		if i+AttHistoryLength < slot { // attestations can only reference 32 slots back
+			delete(b.AttHistory, i) // remove old entries from the map
+		}
 	}
 
-	return false
+	for i := range b.BlockRootHistory {
+		if i+BlockRootHistoryLength < slot {
+			delete(b.BlockRootHistory, i) // remove old entries from the map
+		}
+	}
+}
+
+// Retry the request with an exponential backoff, until it succeeds or the analyzer is closed
+func (b *ClientLiveData) withRetries(request func() error) error {
+	var err error
+	backoff := HistoryRetryBackoff
+	for attempt := 0; attempt < HistoryRetries; attempt++ {
+		err = request()
+		if err == nil {
+			return nil
+		}
+		b.log.Warnf("request failed (attempt %d/%d), retrying in %s: %s", attempt+1, HistoryRetries, backoff, err)
+		select {
+		case <-b.ctx.Done():
+			return b.ctx.Err()
+		case <-clock.OrReal(b.clock).After(backoff):
+		}
+		backoff *= 2
+	}
+	return err
 }
@@ -0,0 +1,183 @@
+package analysis
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/api"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/prysmaticlabs/go-bitfield"
+)
+
+// HistorySnapshot is the on-disk representation of the attestation and block root history
+// so restarts only need to backfill the slots missed while the tool was down
+type HistorySnapshot struct {
+	Label        string                       `json:"label"`
+	Timestamp    time.Time                    `json:"timestamp"`
+	BlockRoots   map[uint64]string            `json:"block_roots"`  // slot -> root
+	Attestations map[uint64]map[uint64]string `json:"attestations"` // slot -> committee index -> aggregation bits
+}
+
+func (b *ClientLiveData) historyFile() string {
+	// labels contain the endpoint, keep the file name safe
+	fileName := strings.NewReplacer("/", "_", ":", "_").Replace(b.label)
+	return filepath.Join(b.historyDir, fmt.Sprintf("%s.json", fileName))
+}
+
+// SaveHistory writes the current history to disk
+func (b *ClientLiveData) SaveHistory() error {
+	if b.historyDir == "" {
+		return nil
+	}
+
+	snapshot := HistorySnapshot{
+		Label:        b.label,
+		Timestamp:    b.now(),
+		BlockRoots:   make(map[uint64]string),
+		Attestations: make(map[uint64]map[uint64]string),
+	}
+
+	b.historyMu.RLock()
+	for slot, root := range b.BlockRootHistory {
+		snapshot.BlockRoots[uint64(slot)] = hex.EncodeToString(root[:])
+	}
+	for slot, committees := range b.AttHistory {
+		snapshot.Attestations[uint64(slot)] = make(map[uint64]string)
+		for committeeIndex, bits := range committees {
+			snapshot.Attestations[uint64(slot)][uint64(committeeIndex)] = hex.EncodeToString(bits)
+		}
+	}
+	b.historyMu.RUnlock()
+
+	content, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("could not encode history snapshot: %s", err)
+	}
+
+	if err := os.MkdirAll(b.historyDir, os.ModePerm); err != nil {
+		return fmt.Errorf("could not create history dir: %s", err)
+	}
+
+	// write and rename, so a crash never leaves a half written snapshot
+	fullPath := b.historyFile()
+	if err := os.WriteFile(fullPath+".tmp", content, 0644); err != nil {
+		return fmt.Errorf("could not write history snapshot %s: %s", fullPath, err)
+	}
+	if err := os.Rename(fullPath+".tmp", fullPath); err != nil {
+		return fmt.Errorf("could not write history snapshot %s: %s", fullPath, err)
+	}
+	b.log.Debugf("history snapshot written to %s", fullPath)
+
+	return nil
+}
+
+// RestoreHistory loads the last snapshot from disk, if any
+// Old entries are pruned once the current head is known while building the history,
+// and the rest are checked against the chain of the node, as it could have reorged while the tool was down
+func (b *ClientLiveData) RestoreHistory() error {
+	if b.historyDir == "" {
+		return nil
+	}
+
+	fullPath := b.historyFile()
+	content, err := os.ReadFile(fullPath)
+	if errors.Is(err, os.ErrNotExist) {
+		b.log.Infof("no history snapshot found at %s", fullPath)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read history snapshot %s: %s", fullPath, err)
+	}
+
+	snapshot := HistorySnapshot{}
+	if err := json.Unmarshal(content, &snapshot); err != nil {
+		return fmt.Errorf("could not decode history snapshot %s: %s", fullPath, err)
+	}
+
+	b.historyMu.Lock()
+	defer b.historyMu.Unlock()
+
+	for slot, rootStr := range snapshot.BlockRoots {
+		rootBytes, err := hex.DecodeString(rootStr)
+		if err != nil {
+			return fmt.Errorf("could not decode root at slot %d: %s", slot, err)
+		}
+		root := phase0.Root{}
+		copy(root[:], rootBytes)
+		b.BlockRootHistory[phase0.Slot(slot)] = root
+	}
+	for slot, committees := range snapshot.Attestations {
+		b.AttHistory[phase0.Slot(slot)] = make(map[phase0.CommitteeIndex]bitfield.Bitlist)
+		for committeeIndex, bitsStr := range committees {
+			bits, err := hex.DecodeString(bitsStr)
+			if err != nil {
+				return fmt.Errorf("could not decode attestations at slot %d: %s", slot, err)
+			}
+			b.AttHistory[phase0.Slot(slot)][phase0.CommitteeIndex(committeeIndex)] = bitfield.Bitlist(bits)
+		}
+	}
+	b.historyRestored = len(snapshot.BlockRoots) > 0
+	b.log.Infof("restored history snapshot from %s (%s), %d block roots", fullPath, snapshot.Timestamp, len(snapshot.BlockRoots))
+
+	return nil
+}
+
+// checkRestoredHistory compares the restored block roots with the chain of the node. The attestations
+// can not be told apart per block, so on any difference the whole snapshot is discarded
+func (b *ClientLiveData) checkRestoredHistory(canonicalRoot func(slot phase0.Slot) (phase0.Root, bool, error)) error {
+	b.historyMu.RLock()
+	slots := make([]phase0.Slot, 0, len(b.BlockRootHistory))
+	for slot := range b.BlockRootHistory {
+		slots = append(slots, slot)
+	}
+	b.historyMu.RUnlock()
+	// the latest blocks are the ones a reorg replaces
+	sort.Slice(slots, func(i, j int) bool { return slots[i] > slots[j] })
+
+	for _, slot := range slots {
+		canonical, skipped, err := canonicalRoot(slot)
+		if err != nil {
+			return fmt.Errorf("could not check restored block root at slot %d: %s", slot, err)
+		}
+		b.historyMu.Lock()
+		root := b.BlockRootHistory[slot]
+		if skipped || canonical != root {
+			b.BlockRootHistory = make(map[phase0.Slot]phase0.Root)
+			b.AttHistory = make(map[phase0.Slot]map[phase0.CommitteeIndex]bitfield.Bitlist)
+			b.historyMu.Unlock()
+			b.log.Warnf("restored block %#x at slot %d is not in the chain of the node, discarding the history snapshot", root, slot)
+			return nil
+		}
+		b.historyMu.Unlock()
+	}
+	b.log.Infof("%d restored block roots checked against the node", len(slots))
+	return nil
+}
+
+// canonicalBlockRoot asks the node for the root of the block at the slot, skipped if there is none
+func (b *ClientLiveData) canonicalBlockRoot(slot phase0.Slot) (root phase0.Root, skipped bool, err error) {
+	err = b.withRetries(func() error {
+		response, err := b.Eth2Provider.Api.BeaconBlockRoot(b.ctx, &api.BeaconBlockRootOpts{
+			Block: fmt.Sprintf("%d", slot),
+		})
+		var apiErr *api.Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			skipped = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		root = *response.Data
+		return nil
+	})
+	return root, skipped, err
+}
@@ -0,0 +1,85 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/prysmaticlabs/go-bitfield"
+	"github.com/stretchr/testify/assert"
+)
+
+func testHistoryAnalyzer(dir string) *ClientLiveData {
+	return &ClientLiveData{
+		ctx:              context.Background(),
+		log:              log.WithField("label", "node"),
+		label:            "node_http://localhost:5052",
+		historyDir:       dir,
+		AttHistory:       make(map[phase0.Slot]map[phase0.CommitteeIndex]bitfield.Bitlist),
+		BlockRootHistory: make(map[phase0.Slot]phase0.Root),
+	}
+}
+
+func TestHistorySnapshotRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	saved := testHistoryAnalyzer(dir)
+	bits := bitfield.NewBitlist(8)
+	bits.SetBitAt(3, true)
+	saved.BlockRootHistory[100] = phase0.Root{1}
+	saved.BlockRootHistory[102] = phase0.Root{2}
+	saved.AttHistory[99] = map[phase0.CommitteeIndex]bitfield.Bitlist{0: bits, 4: bitfield.NewBitlist(16)}
+	assert.Nil(t, saved.SaveHistory())
+
+	restored := testHistoryAnalyzer(dir)
+	assert.Nil(t, restored.RestoreHistory())
+	assert.Equal(t, saved.BlockRootHistory, restored.BlockRootHistory)
+	assert.Equal(t, saved.AttHistory, restored.AttHistory)
+	assert.True(t, restored.historyRestored)
+
+	// no snapshot is not an error
+	empty := testHistoryAnalyzer(t.TempDir())
+	assert.Nil(t, empty.RestoreHistory())
+	assert.Empty(t, empty.BlockRootHistory)
+	assert.False(t, empty.historyRestored)
+}
+
+func TestCheckRestoredHistory(t *testing.T) {
+	canonical := map[phase0.Slot]phase0.Root{100: {1}, 102: {2}}
+	lookup := func(slot phase0.Slot) (phase0.Root, bool, error) {
+		root, ok := canonical[slot]
+		return root, !ok, nil
+	}
+	restore := func() *ClientLiveData {
+		b := testHistoryAnalyzer("")
+		b.BlockRootHistory[100] = phase0.Root{1}
+		b.BlockRootHistory[102] = phase0.Root{2}
+		b.AttHistory[99] = map[phase0.CommitteeIndex]bitfield.Bitlist{0: bitfield.NewBitlist(8)}
+		return b
+	}
+
+	b := restore()
+	assert.Nil(t, b.checkRestoredHistory(lookup))
+	assert.Len(t, b.BlockRootHistory, 2)
+	assert.Len(t, b.AttHistory, 1)
+
+	// block 102 was reorged out while the tool was down
+	canonical[102] = phase0.Root{3}
+	b = restore()
+	assert.Nil(t, b.checkRestoredHistory(lookup))
+	assert.Empty(t, b.BlockRootHistory)
+	assert.Empty(t, b.AttHistory)
+
+	// or replaced by a skipped slot
+	delete(canonical, 102)
+	b = restore()
+	assert.Nil(t, b.checkRestoredHistory(lookup))
+	assert.Empty(t, b.BlockRootHistory)
+
+	// the node could not be asked, the history is not trusted yet
+	b = restore()
+	assert.NotNil(t, b.checkRestoredHistory(func(slot phase0.Slot) (phase0.Root, bool, error) {
+		return phase0.Root{}, false, fmt.Errorf("connection refused")
+	}))
+	assert.Len(t, b.BlockRootHistory, 2)
+}
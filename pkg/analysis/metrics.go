@@ -20,9 +20,43 @@ const (
 	SYNC_REWARD_WEIGHT   = 2
 )
 
-// https://github.com/attestantio/vouch/blob/0c75ee8315dc4e5df85eb2aa09b4acc2b4436661/strategies/beaconblockproposal/best/score.go#L222
+// AttestationScore is the contribution of each attestation of a block
+type AttestationScore struct {
+	Slot           phase0.Slot
+	CommitteeIndex phase0.CommitteeIndex
+	Votes          int // bits set in the aggregate
+	NewVotes       int // votes not included before
+	CorrectSource  bool
+	CorrectTarget  bool
+	CorrectHead    bool
+}
+
 // This function receives a new block proposal and ouputs a block score and metrics about the block
 func (b *ClientLiveData) BlockMetrics(block *api.VersionedProposal, duration time.Duration) (postgresql.BlockMetricsModel, error) {
+	input, err := BlockInputFromProposal(block)
+	if err != nil {
+		return postgresql.BlockMetricsModel{}, err
+	}
+
+	metrics, attScores := b.ScoreBlock(input)
+	voteAges := VoteAges(input.Slot, attScores)
+	metrics.VoteAgeHistogram = voteAges.Histogram
+	metrics.VoteAgeMean = voteAges.Mean
+	metrics.VoteAgeMedian = voteAges.Median
+	metrics.VoteAgeP90 = voteAges.P90
+	metrics.VoteAgeMax = voteAges.Max
+	metrics.FreshVotes = voteAges.Fresh
+	metrics.StaleVotes = voteAges.Stale
+	metrics.WorthlessVotes = voteAges.Worthless
+	metrics.Duration = float64(duration.Seconds())
+	metrics.ExecutionValue = block.ExecutionValue.Uint64()
+	metrics.ConsensusValue = block.ConsensusValue.Uint64()
+	return metrics, nil
+}
+
+// https://github.com/attestantio/vouch/blob/0c75ee8315dc4e5df85eb2aa09b4acc2b4436661/strategies/beaconblockproposal/best/score.go#L222
+// ScoreBlock judges the block against the attestation history, which is not modified
+func (b *ClientLiveData) ScoreBlock(block BlockInput) (postgresql.BlockMetricsModel, []AttestationScore) {
 	// log := b.log.WithField("task", "bellatrix-block-score") // add extra log for function
 
 	totalNewVotes := 0
@@ -31,19 +65,14 @@ func (b *ClientLiveData) BlockMetrics(block *api.VersionedProposal, duration tim
 	totalCorrectSource := 0
 	totalCorrectTarget := 0
 	totalCorrectHead := 0
-	slot, err := block.Slot()
+	attScores := make([]AttestationScore, 0, len(block.Attestations))
+	redundancy := newRedundancyTracker()
 
-	if err != nil {
-		log.Errorf("could not get block slot from block proposal: %s", err)
-	}
-
-	blockBody := utils.BlockBodyFromProposal(*block)
-	if err != nil {
-		log.Errorf("could not get block body from block proposal: %s", err)
-	}
+	b.historyMu.RLock()
+	defer b.historyMu.RUnlock()
 
 	attested := make(map[phase0.Slot]map[phase0.CommitteeIndex]bitfield.Bitlist) // for current block
-	for _, attestation := range blockBody.Attestations {
+	for _, attestation := range block.Attestations {
 		newVotes := 0
 		slot := attestation.Data.Slot
 
@@ -72,19 +101,30 @@ func (b *ClientLiveData) BlockMetrics(block *api.VersionedProposal, duration tim
 			attested[slot][committeIndex].SetBitAt(uint64(idx), true) // register as attested in the current block
 			newVotes++
 		}
+		attScoreItem := AttestationScore{
+			Slot:           slot,
+			CommitteeIndex: committeIndex,
+			Votes:          len(attestingIndices),
+			NewVotes:       newVotes,
+		}
 		score := 0
-		if utils.IsCorrectSource(*attestation, *block) {
+		if utils.IsCorrectSource(*attestation, block.Slot) {
 			score += newVotes * TIMELY_SOURCE_WEIGHT
 			totalCorrectSource += newVotes
+			attScoreItem.CorrectSource = true
 		}
 		if utils.IsCorrectTarget(*attestation, b.BlockRootHistory) {
 			score += newVotes * TIMELY_TARGET_WEIGHT
 			totalCorrectTarget += newVotes
+			attScoreItem.CorrectTarget = true
 		}
-		if utils.IsCorrectHead(*attestation, *block) {
+		if utils.IsCorrectHead(*attestation, block.Slot, block.ParentRoot) {
 			score += newVotes * TIMELY_HEAD_WEIGHT
 			totalCorrectHead += newVotes
+			attScoreItem.CorrectHead = true
 		}
+		attScores = append(attScores, attScoreItem)
+		redundancy.add(attestation, newVotes)
 
 		totalNewVotes += newVotes
 		// denominator := (WEIGHT_DENOMINATOR - PROPOSER_WEIGHT) * WEIGHT_DENOMINATOR / PROPOSER_WEIGHT
@@ -92,32 +132,34 @@ func (b *ClientLiveData) BlockMetrics(block *api.VersionedProposal, duration tim
 
 	}
 
-	syncCommitteeScore := float64(blockBody.SyncAggregate.SyncCommitteeBits.Count()) * float64(SYNC_REWARD_WEIGHT) / float64(WEIGHT_DENOMINATOR)
+	syncCommitteeScore := float64(block.SyncBits) * float64(SYNC_REWARD_WEIGHT) / float64(WEIGHT_DENOMINATOR)
 
-	attesterSlashingScore, proposerSlashingScore := scoreSlashings(blockBody.AttesterSlashings, blockBody.ProposerSlashings)
+	attesterSlashingScore, proposerSlashingScore := scoreSlashings(block.AttesterSlashings, block.ProposerSlashings)
 
 	totalScore = attScore + syncCommitteeScore + attesterSlashingScore + proposerSlashingScore
+	redundancyStats := redundancy.finish()
 
 	return postgresql.BlockMetricsModel{
-		Slot:                  int(slot),
-		ClientName:            b.client,
+		Slot:                  int(block.Slot),
+		ClientName:            b.GetClient(),
 		Label:                 b.label,
 		CorrectSource:         totalCorrectSource,
 		CorrectTarget:         totalCorrectTarget,
 		CorrectHead:           totalCorrectHead,
 		Score:                 float64(totalScore),
-		Duration:              float64(duration.Seconds()),
 		NewVotes:              totalNewVotes,
-		AttNum:                len(blockBody.Attestations),
-		Sync1Bits:             int(blockBody.SyncAggregate.SyncCommitteeBits.Count()),
-		AttesterSlashings:     len(blockBody.AttesterSlashings),
-		ProposerSlashings:     len(blockBody.ProposerSlashings),
+		AttNum:                len(block.Attestations),
+		Sync1Bits:             int(block.SyncBits),
+		AttesterSlashings:     len(block.AttesterSlashings),
+		ProposerSlashings:     len(block.ProposerSlashings),
 		ProposerSlashingScore: proposerSlashingScore,
 		AttesterSlashingScore: attesterSlashingScore,
 		SyncScore:             syncCommitteeScore,
-		ExecutionValue:        block.ExecutionValue.Uint64(),
-		ConsensusValue:        block.ConsensusValue.Uint64(),
-	}, nil
+		ZeroNewVoteAtts:       redundancyStats.ZeroNewVotes,
+		SubsumedAtts:          redundancyStats.Subsumed,
+		MergeableAttPairs:     redundancyStats.MergeablePair,
+		WastedAttBytes:        redundancyStats.WastedBytes,
+	}, attScores
 }
 
 // https://github.com/attestantio/vouch/blob/0c75ee8315dc4e5df85eb2aa09b4acc2b4436661/strategies/beaconblockproposal/best/score.go#L312
@@ -0,0 +1,97 @@
+package analysis
+
+import (
+	"github.com/migalabs/streameth/pkg/client_api"
+	"github.com/migalabs/streameth/pkg/postgresql"
+	"github.com/migalabs/streameth/pkg/utils"
+)
+
+// UpdateNodeStatus polls the health of the beacon node and stores it
+func (b *ClientLiveData) UpdateNodeStatus() {
+	log := b.log.WithField("routine", "node-status")
+
+	status, err := b.Eth2Provider.NodeStatus()
+	if err != nil {
+		log.Errorf("could not poll node status: %s", err)
+		return
+	}
+	status.Timestamp = b.now() // the corrected clock, as every other record
+	log.Tracef("node status: %+v", status)
+
+	b.nodeStatusMu.Lock()
+	b.nodeStatus = status
+	versionChanged := status.Version != b.nodeVersion
+	b.nodeStatusMu.Unlock()
+
+	if versionChanged {
+		b.updateClientVersion(status.Version)
+	}
+
+	params := make([]interface{}, 0)
+	params = append(params, b.label)
+	params = append(params, b.GetClient())
+	params = append(params, status.Timestamp)
+	params = append(params, status.HeadSlot)
+	params = append(params, status.SyncDistance)
+	params = append(params, status.IsSyncing)
+	params = append(params, status.IsOptimistic)
+	params = append(params, status.ElOffline)
+	params = append(params, status.HealthCode)
+	params = append(params, status.PeersConnected)
+	params = append(params, status.Version)
+	writeTask := postgresql.WriteTask{
+		QueryString: postgresql.InsertNewNodeStatus,
+		Params:      params,
+		Type:        postgresql.RecordNodeStatus,
+	}
+	b.DBClient.Persist(b.ctx, writeTask) // store
+}
+
+// GetNodeStatus returns the last polled status of the beacon node
+func (b *ClientLiveData) GetNodeStatus() client_api.NodeStatus {
+	b.nodeStatusMu.RLock()
+	defer b.nodeStatusMu.RUnlock()
+	return b.nodeStatus
+}
+
+// A new version was reported by the node (upgrade or first poll)
+func (b *ClientLiveData) updateClientVersion(nodeVersion string) {
+	log := b.log.WithField("routine", "node-status")
+
+	detectedClient, clientVersion := utils.ParseClientVersion(nodeVersion)
+	client := b.GetClient()
+
+	if detectedClient != "" && detectedClient != client {
+		if b.autoDetected {
+			log.Warnf("node switched client from %s to %s", client, detectedClient)
+			b.nodeStatusMu.Lock()
+			b.client = detectedClient
+			b.nodeStatusMu.Unlock()
+		} else {
+			log.Warnf("configured client %s does not match the node version: %s", client, nodeVersion)
+		}
+	}
+	log.Infof("node version changed: %s", nodeVersion)
+
+	b.recordNodeVersion(nodeVersion, clientVersion)
+}
+
+// Store the version reported by the node, so score changes can be correlated with upgrades
+func (b *ClientLiveData) recordNodeVersion(nodeVersion string, clientVersion string) {
+	b.nodeStatusMu.Lock()
+	b.nodeVersion = nodeVersion
+	b.nodeStatusMu.Unlock()
+
+	params := make([]interface{}, 0)
+	params = append(params, b.label)
+	params = append(params, b.GetClient())
+	params = append(params, clientVersion)
+	params = append(params, nodeVersion)
+	params = append(params, b.now())
+	writeTask := postgresql.WriteTask{
+		QueryString: postgresql.InsertNewNodeVersion,
+		Params:      params,
+		Type:        postgresql.RecordNodeStatus,
+	}
+	b.DBClient.Persist(b.ctx, writeTask) // store
+}
@@ -0,0 +1,129 @@
+package analysis
+
+import (
+	"container/heap"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/prysmaticlabs/go-bitfield"
+)
+
+// MAX_ATTESTATIONS up to Deneb, the last fork the Api knows. Electra attestations aggregate several
+// committees, which the phase0 attestations of the Api can not represent, so they are not handled
+const MaxBlockAttestations = 128
+
+// PackingMetrics compares what the block included with what the node could have included
+type PackingMetrics struct {
+	PoolSize          int // attestations in the pool snapshot
+	AchievableVotes   int // new votes of the best packing found
+	PackingEfficiency float64
+}
+
+// AchievableVotes finds the most new votes a block at the given slot could include,
+// choosing at most MaxBlockAttestations of the candidates, judged against the attestation history
+// Maximum coverage is NP-hard, so the greedy solver is used: it picks the candidate adding the most
+// new votes each time, which is within (1-1/e) of the optimum
+func (b *ClientLiveData) AchievableVotes(slot phase0.Slot, candidates []*phase0.Attestation) int {
+	b.historyMu.RLock()
+	defer b.historyMu.RUnlock()
+
+	covered := make(map[phase0.Slot]map[phase0.CommitteeIndex]bitfield.Bitlist)
+	newVotes := func(attestation *phase0.Attestation) int {
+		votes := 0
+		for _, idx := range attestation.AggregationBits.BitIndices() {
+			if b.AttHistory[attestation.Data.Slot][attestation.Data.Index].BitAt(uint64(idx)) {
+				continue
+			}
+			if covered[attestation.Data.Slot][attestation.Data.Index].BitAt(uint64(idx)) {
+				continue
+			}
+			votes++
+		}
+		return votes
+	}
+
+	queue := make(coverageQueue, 0, len(candidates))
+	for _, attestation := range candidates {
+		if attestation.Data.Slot >= slot || attestation.Data.Slot+AttHistoryLength < slot {
+			// can not be included in the block
+			continue
+		}
+		if votes := newVotes(attestation); votes > 0 {
+			queue = append(queue, coverageItem{attestation: attestation, votes: votes})
+		}
+	}
+	heap.Init(&queue)
+
+	// the new votes of a candidate can only drop as others are picked,
+	// so the stale count of the top is an upper bound and only the top is recomputed
+	achievable := 0
+	picked := 0
+	for queue.Len() > 0 && picked < MaxBlockAttestations {
+		top := &queue[0]
+		votes := newVotes(top.attestation)
+		if votes == 0 {
+			heap.Pop(&queue)
+			continue
+		}
+		if votes < top.votes {
+			top.votes = votes
+			heap.Fix(&queue, 0)
+			continue
+		}
+
+		attestation := heap.Pop(&queue).(coverageItem).attestation
+		attSlot, committeeIndex := attestation.Data.Slot, attestation.Data.Index
+		if _, exists := covered[attSlot]; !exists {
+			covered[attSlot] = make(map[phase0.CommitteeIndex]bitfield.Bitlist)
+		}
+		if _, exists := covered[attSlot][committeeIndex]; !exists {
+			covered[attSlot][committeeIndex] = bitfield.NewBitlist(attestation.AggregationBits.Len())
+		}
+		for _, idx := range attestation.AggregationBits.BitIndices() {
+			covered[attSlot][committeeIndex].SetBitAt(uint64(idx), true)
+		}
+		achievable += votes
+		picked++
+	}
+	return achievable
+}
+
+// Packing judges the new votes of a proposal against the best packing of the pool snapshot
+// The attestations of the block are candidates too, as the pool may have changed since it was built.
+// The greedy packing is not optimal, so the proposal may beat it: it is then the best packing known,
+// and the efficiency is at most 1
+func (b *ClientLiveData) Packing(block BlockInput, newVotes int, pool []*phase0.Attestation) PackingMetrics {
+	candidates := make([]*phase0.Attestation, 0, len(pool)+len(block.Attestations))
+	candidates = append(candidates, pool...)
+	candidates = append(candidates, block.Attestations...)
+
+	metrics := PackingMetrics{
+		PoolSize:        len(pool),
+		AchievableVotes: b.AchievableVotes(block.Slot, candidates),
+	}
+	if newVotes > metrics.AchievableVotes {
+		metrics.AchievableVotes = newVotes
+	}
+	if metrics.AchievableVotes > 0 {
+		metrics.PackingEfficiency = float64(newVotes) / float64(metrics.AchievableVotes)
+	}
+	return metrics
+}
+
+type coverageItem struct {
+	attestation *phase0.Attestation
+	votes       int // new votes when last computed
+}
+
+// coverageQueue is a max heap by new votes
+type coverageQueue []coverageItem
+
+func (q coverageQueue) Len() int            { return len(q) }
+func (q coverageQueue) Less(i, j int) bool  { return q[i].votes > q[j].votes }
+func (q coverageQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *coverageQueue) Push(x interface{}) { *q = append(*q, x.(coverageItem)) }
+func (q *coverageQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,78 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/prysmaticlabs/go-bitfield"
+)
+
+func testAttestation(slot phase0.Slot, index phase0.CommitteeIndex, bits ...uint64) *phase0.Attestation {
+	aggregationBits := bitfield.NewBitlist(8)
+	for _, bit := range bits {
+		aggregationBits.SetBitAt(bit, true)
+	}
+	return &phase0.Attestation{
+		AggregationBits: aggregationBits,
+		Data: &phase0.AttestationData{
+			Slot:  slot,
+			Index: index,
+		},
+	}
+}
+
+func TestAchievableVotes(t *testing.T) {
+	b := &ClientLiveData{
+		AttHistory: make(map[phase0.Slot]map[phase0.CommitteeIndex]bitfield.Bitlist),
+	}
+	// bit 0 of slot 99 is already in the chain
+	b.AttHistory[99] = map[phase0.CommitteeIndex]bitfield.Bitlist{0: bitfield.NewBitlist(8)}
+	b.AttHistory[99][0].SetBitAt(0, true)
+
+	candidates := []*phase0.Attestation{
+		testAttestation(99, 0, 0, 1, 2),
+		testAttestation(99, 0, 2, 3),
+		testAttestation(99, 0, 1),          // subsumed by the first one
+		testAttestation(99, 1, 5),          // another committee
+		testAttestation(100, 0, 4, 5, 6),   // not includable yet
+		testAttestation(50, 0, 4, 5, 6, 7), // too old
+	}
+	if votes := b.AchievableVotes(100, candidates); votes != 4 {
+		t.Errorf("expected 4 achievable votes, got %d", votes)
+	}
+
+	input := BlockInput{Version: spec.DataVersionDeneb, Slot: 100, Attestations: candidates[2:3]}
+	packing := b.Packing(input, 1, candidates[:2])
+	if packing.PoolSize != 2 || packing.AchievableVotes != 3 || packing.PackingEfficiency != float64(1)/3 {
+		t.Errorf("unexpected packing: %+v", packing)
+	}
+}
+
+func TestAchievableVotesLimit(t *testing.T) {
+	b := &ClientLiveData{
+		AttHistory: make(map[phase0.Slot]map[phase0.CommitteeIndex]bitfield.Bitlist),
+	}
+	// 10 committees voting at each of 20 slots, one vote each
+	candidates := make([]*phase0.Attestation, 0)
+	for slot := phase0.Slot(80); slot < 100; slot++ {
+		for index := phase0.CommitteeIndex(0); index < 10; index++ {
+			candidates = append(candidates, testAttestation(slot, index, 0))
+		}
+	}
+	if votes := b.AchievableVotes(100, candidates); votes != MaxBlockAttestations {
+		t.Errorf("expected %d attestations to fit, got %d votes", MaxBlockAttestations, votes)
+	}
+}
+
+func TestPackingBeatsGreedy(t *testing.T) {
+	b := &ClientLiveData{
+		AttHistory: make(map[phase0.Slot]map[phase0.CommitteeIndex]bitfield.Bitlist),
+	}
+	candidates := []*phase0.Attestation{testAttestation(99, 0, 0, 1)}
+	// the proposal found more new votes than the candidates give
+	packing := b.Packing(BlockInput{Slot: 100}, 3, candidates)
+	if packing.AchievableVotes != 3 || packing.PackingEfficiency != 1 {
+		t.Errorf("unexpected packing: %+v", packing)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/attestantio/go-eth2-client/api"
 	"github.com/migalabs/streameth/pkg/utils"
@@ -24,7 +25,7 @@ func (b *ClientLiveData) CheckBlocksFolder() error {
 	return nil
 }
 
-func (b *ClientLiveData) PersistBlock(block api.VersionedProposal) error {
+func (b *ClientLiveData) PersistBlock(block api.VersionedProposal, offset time.Duration) error {
 	if block.IsEmpty() {
 		log.Errorf("attempt to persist empty block")
 		return fmt.Errorf("empty block persist")
@@ -36,8 +37,11 @@ func (b *ClientLiveData) PersistBlock(block api.VersionedProposal) error {
 		return err
 	}
 
-	// files are always new, block proposals are always in a new slot
+	// files are always new, block proposals are always in a new slot (and offset)
 	fileName := fmt.Sprintf("slot_%d.ssz", slot)
+	if offset != 0 {
+		fileName = fmt.Sprintf("slot_%d_%dms.ssz", slot, offset.Milliseconds())
+	}
 	fullPath := fmt.Sprintf("%s%s", b.blocksDir, fileName)
 
 	// create file
@@ -0,0 +1,232 @@
+package analysis
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/attestantio/go-eth2-client/api"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/utils"
+	"github.com/prysmaticlabs/go-bitfield"
+)
+
+// ProposalContent has the parts of a proposal that are compared between nodes
+type ProposalContent struct {
+	Label             string
+	Client            string
+	Attestations      []*phase0.Attestation
+	SyncBits          bitfield.Bitvector512
+	PayloadHash       phase0.Hash32
+	AttesterSlashings []*phase0.AttesterSlashing
+	ProposerSlashings []*phase0.ProposerSlashing
+}
+
+// ProposalContentFromProposal reads the content of the block proposal of a node
+func ProposalContentFromProposal(label string, client string, block *api.VersionedProposal) ProposalContent {
+	body := utils.BlockBodyFromProposal(*block)
+	content := ProposalContent{
+		Label:             label,
+		Client:            client,
+		Attestations:      body.Attestations,
+		AttesterSlashings: body.AttesterSlashings,
+		ProposerSlashings: body.ProposerSlashings,
+	}
+	if body.SyncAggregate != nil {
+		content.SyncBits = body.SyncAggregate.SyncCommitteeBits
+	}
+	if body.ExecutionPayload != nil {
+		content.PayloadHash = body.ExecutionPayload.BlockHash
+	}
+	return content
+}
+
+// DiffAttestation identifies an attestation in the detailed diff
+type DiffAttestation struct {
+	Slot           phase0.Slot           `json:"slot"`
+	CommitteeIndex phase0.CommitteeIndex `json:"committee_index"`
+	DataRoot       string                `json:"data_root"`
+	Bits           string                `json:"aggregation_bits"`
+}
+
+// ProposalDiffDetails lists what differs between both proposals
+type ProposalDiffDetails struct {
+	UniqueAttsA     []DiffAttestation    `json:"unique_attestations_a"`
+	UniqueAttsB     []DiffAttestation    `json:"unique_attestations_b"`
+	OverlappingAtts [][2]DiffAttestation `json:"overlapping_attestations"` // same data, bits in common, but not equal
+	SyncBits        []uint64             `json:"sync_bits"`                // set in only one of the proposals
+	SlashingsA      []string             `json:"unique_slashings_a"`
+	SlashingsB      []string             `json:"unique_slashings_b"`
+}
+
+// ProposalDiff summarizes the differences between the proposals of two nodes for the same slot
+type ProposalDiff struct {
+	A, B             ProposalContent
+	CommonAtts       int // identical attestations in both
+	UniqueAttsA      int
+	UniqueAttsB      int
+	OverlappingAtts  int
+	SyncBitsDiff     int
+	PayloadDiffers   bool
+	UniqueSlashingsA int
+	UniqueSlashingsB int
+	Details          ProposalDiffDetails
+}
+
+type diffAttestation struct {
+	id          DiffAttestation
+	dataRoot    [32]byte
+	attestation *phase0.Attestation
+}
+
+// DiffProposals compares the content of two proposals
+func DiffProposals(a ProposalContent, b ProposalContent) (ProposalDiff, error) {
+	diff := ProposalDiff{
+		A:              a,
+		B:              b,
+		PayloadDiffers: a.PayloadHash != b.PayloadHash,
+		Details: ProposalDiffDetails{
+			UniqueAttsA:     make([]DiffAttestation, 0),
+			UniqueAttsB:     make([]DiffAttestation, 0),
+			OverlappingAtts: make([][2]DiffAttestation, 0),
+			SyncBits:        make([]uint64, 0),
+		},
+	}
+
+	attsA, err := diffAttestations(a.Attestations)
+	if err != nil {
+		return diff, err
+	}
+	attsB, err := diffAttestations(b.Attestations)
+	if err != nil {
+		return diff, err
+	}
+
+	// identical attestations are removed from both sides, the rest are unique
+	uniqueA := make([]diffAttestation, 0)
+	for key, item := range attsA {
+		if _, ok := attsB[key]; ok {
+			diff.CommonAtts++
+			delete(attsB, key)
+			continue
+		}
+		uniqueA = append(uniqueA, item)
+	}
+	uniqueB := make([]diffAttestation, 0, len(attsB))
+	for _, item := range attsB {
+		uniqueB = append(uniqueB, item)
+	}
+	sortDiffAttestations(uniqueA)
+	sortDiffAttestations(uniqueB)
+	for _, item := range uniqueA {
+		diff.Details.UniqueAttsA = append(diff.Details.UniqueAttsA, item.id)
+	}
+	for _, item := range uniqueB {
+		diff.Details.UniqueAttsB = append(diff.Details.UniqueAttsB, item.id)
+	}
+	diff.UniqueAttsA = len(uniqueA)
+	diff.UniqueAttsB = len(uniqueB)
+
+	for _, itemA := range uniqueA {
+		for _, itemB := range uniqueB {
+			if itemA.dataRoot != itemB.dataRoot {
+				continue
+			}
+			overlaps, err := itemA.attestation.AggregationBits.Overlaps(itemB.attestation.AggregationBits)
+			if err != nil || !overlaps {
+				continue
+			}
+			diff.OverlappingAtts++
+			diff.Details.OverlappingAtts = append(diff.Details.OverlappingAtts, [2]DiffAttestation{itemA.id, itemB.id})
+		}
+	}
+
+	for i := uint64(0); i < a.SyncBits.Len() || i < b.SyncBits.Len(); i++ {
+		if a.SyncBits.BitAt(i) != b.SyncBits.BitAt(i) {
+			diff.Details.SyncBits = append(diff.Details.SyncBits, i)
+		}
+	}
+	diff.SyncBitsDiff = len(diff.Details.SyncBits)
+
+	slashingsA, err := slashingRoots(a)
+	if err != nil {
+		return diff, err
+	}
+	slashingsB, err := slashingRoots(b)
+	if err != nil {
+		return diff, err
+	}
+	diff.Details.SlashingsA = uniqueKeys(slashingsA, slashingsB)
+	diff.Details.SlashingsB = uniqueKeys(slashingsB, slashingsA)
+	diff.UniqueSlashingsA = len(diff.Details.SlashingsA)
+	diff.UniqueSlashingsB = len(diff.Details.SlashingsB)
+
+	return diff, nil
+}
+
+// attestations by data root and aggregation bits
+func diffAttestations(attestations []*phase0.Attestation) (map[string]diffAttestation, error) {
+	result := make(map[string]diffAttestation)
+	for _, attestation := range attestations {
+		dataRoot, err := attestation.Data.HashTreeRoot()
+		if err != nil {
+			return nil, fmt.Errorf("could not hash attestation data: %s", err)
+		}
+		item := diffAttestation{
+			id: DiffAttestation{
+				Slot:           attestation.Data.Slot,
+				CommitteeIndex: attestation.Data.Index,
+				DataRoot:       fmt.Sprintf("%#x", dataRoot),
+				Bits:           "0x" + hex.EncodeToString(attestation.AggregationBits),
+			},
+			dataRoot:    dataRoot,
+			attestation: attestation,
+		}
+		result[item.id.DataRoot+item.id.Bits] = item
+	}
+	return result, nil
+}
+
+func sortDiffAttestations(items []diffAttestation) {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].id.Slot != items[j].id.Slot {
+			return items[i].id.Slot < items[j].id.Slot
+		}
+		if items[i].id.CommitteeIndex != items[j].id.CommitteeIndex {
+			return items[i].id.CommitteeIndex < items[j].id.CommitteeIndex
+		}
+		return items[i].id.Bits < items[j].id.Bits
+	})
+}
+
+// roots of the attester and proposer slashings of the proposal
+func slashingRoots(content ProposalContent) (map[string]struct{}, error) {
+	roots := make(map[string]struct{})
+	for _, slashing := range content.AttesterSlashings {
+		root, err := slashing.HashTreeRoot()
+		if err != nil {
+			return nil, fmt.Errorf("could not hash attester slashing: %s", err)
+		}
+		roots[fmt.Sprintf("%#x", root)] = struct{}{}
+	}
+	for _, slashing := range content.ProposerSlashings {
+		root, err := slashing.HashTreeRoot()
+		if err != nil {
+			return nil, fmt.Errorf("could not hash proposer slashing: %s", err)
+		}
+		roots[fmt.Sprintf("%#x", root)] = struct{}{}
+	}
+	return roots, nil
+}
+
+// keys of a that are not in b
+func uniqueKeys(a map[string]struct{}, b map[string]struct{}) []string {
+	result := make([]string, 0)
+	for key := range a {
+		if _, ok := b[key]; !ok {
+			result = append(result, key)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,57 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/prysmaticlabs/go-bitfield"
+)
+
+func TestDiffProposals(t *testing.T) {
+	syncA := bitfield.NewBitvector512()
+	syncA.SetBitAt(1, true)
+	syncA.SetBitAt(2, true)
+	syncB := bitfield.NewBitvector512()
+	syncB.SetBitAt(2, true)
+	syncB.SetBitAt(3, true)
+
+	a := ProposalContent{
+		Label: "a",
+		Attestations: []*phase0.Attestation{
+			testAttestation(99, 0, 0, 1),
+			testAttestation(99, 1, 2),
+			testAttestation(98, 0, 4),
+		},
+		SyncBits:    syncA,
+		PayloadHash: phase0.Hash32{1},
+	}
+	b := ProposalContent{
+		Label: "b",
+		Attestations: []*phase0.Attestation{
+			testAttestation(99, 0, 1, 2), // overlaps the first one of a
+			testAttestation(99, 1, 2),
+		},
+		SyncBits:    syncB,
+		PayloadHash: phase0.Hash32{1},
+	}
+
+	diff, err := DiffProposals(a, b)
+	if err != nil {
+		t.Fatalf("could not diff proposals: %s", err)
+	}
+	if diff.CommonAtts != 1 || diff.UniqueAttsA != 2 || diff.UniqueAttsB != 1 {
+		t.Errorf("unexpected attestation counts: %+v", diff)
+	}
+	if diff.OverlappingAtts != 1 || diff.Details.OverlappingAtts[0][0].Slot != 99 {
+		t.Errorf("expected one overlapping pair: %+v", diff.Details.OverlappingAtts)
+	}
+	if diff.SyncBitsDiff != 2 || diff.Details.SyncBits[0] != 1 || diff.Details.SyncBits[1] != 3 {
+		t.Errorf("unexpected sync bits diff: %v", diff.Details.SyncBits)
+	}
+	if diff.PayloadDiffers {
+		t.Errorf("payloads are the same")
+	}
+	if diff.UniqueSlashingsA != 0 || diff.UniqueSlashingsB != 0 {
+		t.Errorf("no slashings expected")
+	}
+}
@@ -0,0 +1,147 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/api"
+	api_v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/recording"
+)
+
+// StartRecording writes the events and responses the analyzer consumes to the record dir
+func (b *ClientLiveData) StartRecording(dir string, node recording.NodeInfo) error {
+	recorder, err := recording.NewRecorder(dir, b.label, node)
+	if err != nil {
+		return err
+	}
+	b.recorderMu.Lock()
+	defer b.recorderMu.Unlock()
+	b.recorder = recorder
+	return nil
+}
+
+// StopRecording closes the recording, if any
+func (b *ClientLiveData) StopRecording() {
+	b.recorderMu.Lock()
+	defer b.recorderMu.Unlock()
+	if b.recorder == nil {
+		return
+	}
+	if err := b.recorder.Close(); err != nil {
+		b.log.Errorf("could not close recording: %s", err)
+	}
+	b.recorder = nil
+}
+
+func (b *ClientLiveData) record(record recording.Record, data interface{}) {
+	b.recorderMu.Lock()
+	defer b.recorderMu.Unlock()
+	if b.recorder == nil {
+		return
+	}
+	content, err := json.Marshal(data)
+	if err != nil {
+		b.log.Errorf("could not encode %s record: %s", record.Kind, err)
+		return
+	}
+	record.Data = content
+	if err := b.recorder.Write(record); err != nil {
+		b.log.Errorf("could not record %s: %s", record.Kind, err)
+	}
+}
+
+// Recorded wraps the event handler so the events are recorded before being handled
+func (b *ClientLiveData) Recorded(handler func(event *api_v1.Event)) func(event *api_v1.Event) {
+	return func(event *api_v1.Event) {
+		if event.Data != nil {
+			b.record(recording.Record{
+				Time:  b.now(),
+				Kind:  recording.KindEvent,
+				Topic: event.Topic,
+			}, event.Data)
+		}
+		handler(event)
+	}
+}
+
+// fetchSignedBlock requests the block to the source, recording the response
+func (b *ClientLiveData) fetchSignedBlock(ctx context.Context, blockID string) (*spec.VersionedSignedBeaconBlock, error) {
+	block, err := b.source.SignedBeaconBlock(ctx, blockID)
+	if err == nil && block != nil {
+		b.record(recording.Record{
+			Time:    b.now(),
+			Kind:    recording.KindBlock,
+			Request: blockID,
+		}, block)
+	}
+	return block, err
+}
+
+// fetchProposal requests the block proposal to the source, recording the response
+func (b *ClientLiveData) fetchProposal(ctx context.Context, slot phase0.Slot, offset time.Duration) (*api.VersionedProposal, time.Duration, error) {
+	block, blockTime, err := b.source.Proposal(ctx, slot, b.GetClient())
+	if err == nil && block != nil {
+		b.record(recording.Record{
+			Time:     b.now(),
+			Kind:     recording.KindProposal,
+			Slot:     uint64(slot),
+			OffsetMs: offset.Milliseconds(),
+			Duration: blockTime,
+		}, block)
+	}
+	return block, blockTime, err
+}
+
+// ReplayEvent decodes a recorded event and handles it as if it was received from the node
+func (b *ClientLiveData) ReplayEvent(topic string, data json.RawMessage) error {
+	event := &api_v1.Event{Topic: topic}
+	switch topic {
+	case "head":
+		head := &api_v1.HeadEvent{}
+		if err := json.Unmarshal(data, head); err != nil {
+			return fmt.Errorf("could not decode head event: %s", err)
+		}
+		event.Data = head
+		b.HandleHeadEvent(event)
+	case "attestation":
+		attestation := &phase0.Attestation{}
+		if err := json.Unmarshal(data, attestation); err != nil {
+			return fmt.Errorf("could not decode attestation event: %s", err)
+		}
+		event.Data = attestation
+		b.HandleAttestationEvent(event)
+	case "chain_reorg":
+		reorg := &api_v1.ChainReorgEvent{}
+		if err := json.Unmarshal(data, reorg); err != nil {
+			return fmt.Errorf("could not decode chain reorg event: %s", err)
+		}
+		event.Data = reorg
+		b.HandleReOrgEvent(event)
+	default:
+		return fmt.Errorf("topic %s can not be replayed", topic)
+	}
+	return nil
+}
+
+// ReplayHistoryBlock adds a recorded block that no handler asked for to the history,
+// those are the blocks requested to build the history
+func (b *ClientLiveData) ReplayHistoryBlock(block *spec.VersionedSignedBeaconBlock) error {
+	slot, err := block.Slot()
+	if err != nil {
+		return fmt.Errorf("could not get block slot: %s", err)
+	}
+	root, err := block.Root()
+	if err != nil {
+		return fmt.Errorf("could not get root of block %d: %s", slot, err)
+	}
+	b.historyMu.Lock()
+	b.BlockRootHistory[slot] = root
+	b.historyMu.Unlock()
+	b.UpdateAttestations(*block)
+	return nil
+}
@@ -0,0 +1,77 @@
+package analysis
+
+import (
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+)
+
+// RedundancyStats counts the aggregates of a block that waste space
+type RedundancyStats struct {
+	ZeroNewVotes  int // aggregates adding no new votes
+	Subsumed      int // aggregates whose votes are all in another aggregate of the block with the same data
+	MergeablePair int // pairs of aggregates with the same data and disjoint votes, that could be a single one
+	WastedBytes   int // ssz size of the aggregates that could be dropped (no new votes or subsumed)
+}
+
+// redundancyTracker follows the aggregates of a block as they are scored
+type redundancyTracker struct {
+	stats    RedundancyStats
+	byData   map[phase0.Root][]int // data root -> positions in the block
+	dropable map[int]bool          // positions that could be dropped
+	block    []*phase0.Attestation
+}
+
+func newRedundancyTracker() *redundancyTracker {
+	return &redundancyTracker{
+		byData:   make(map[phase0.Root][]int),
+		dropable: make(map[int]bool),
+		block:    make([]*phase0.Attestation, 0),
+	}
+}
+
+// add registers the next aggregate of the block, with the new votes it adds
+func (r *redundancyTracker) add(attestation *phase0.Attestation, newVotes int) {
+	position := len(r.block)
+	r.block = append(r.block, attestation)
+	if newVotes == 0 {
+		r.stats.ZeroNewVotes++
+		r.dropable[position] = true
+	}
+	dataRoot, err := attestation.Data.HashTreeRoot()
+	if err != nil {
+		log.Warnf("could not hash attestation data: %s", err)
+		return
+	}
+	r.byData[dataRoot] = append(r.byData[dataRoot], position)
+}
+
+// finish compares the aggregates with the same data
+func (r *redundancyTracker) finish() RedundancyStats {
+	for _, positions := range r.byData {
+		for i, posA := range positions {
+			bitsA := r.block[posA].AggregationBits
+			for j, posB := range positions {
+				if i == j {
+					continue
+				}
+				bitsB := r.block[posB].AggregationBits
+				// equal aggregates subsume each other, only the later one is counted
+				contains, err := bitsB.Contains(bitsA)
+				if err == nil && contains && (j < i || bitsA.Count() < bitsB.Count()) {
+					r.stats.Subsumed++
+					r.dropable[posA] = true
+					break
+				}
+			}
+			for _, posB := range positions[i+1:] {
+				overlaps, err := bitsA.Overlaps(r.block[posB].AggregationBits)
+				if err == nil && !overlaps {
+					r.stats.MergeablePair++
+				}
+			}
+		}
+	}
+	for position := range r.dropable {
+		r.stats.WastedBytes += r.block[position].SizeSSZ()
+	}
+	return r.stats
+}
@@ -0,0 +1,41 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/prysmaticlabs/go-bitfield"
+)
+
+func TestScoreBlockRedundancy(t *testing.T) {
+	b := &ClientLiveData{
+		AttHistory:       make(map[phase0.Slot]map[phase0.CommitteeIndex]bitfield.Bitlist),
+		BlockRootHistory: make(map[phase0.Slot]phase0.Root),
+	}
+	// bit 7 of slot 99 is already in the chain
+	b.AttHistory[99] = map[phase0.CommitteeIndex]bitfield.Bitlist{0: bitfield.NewBitlist(8)}
+	b.AttHistory[99][0].SetBitAt(7, true)
+
+	attestations := []*phase0.Attestation{
+		testAttestation(99, 0, 0, 1, 2),
+		testAttestation(99, 0, 1, 2), // subsumed by the first one, no new votes
+		testAttestation(99, 0, 4, 5), // can be merged with the first one
+		testAttestation(99, 0, 7),    // already in the chain, can be merged with the first and third
+		testAttestation(98, 0, 1),
+	}
+	metrics, _ := b.ScoreBlock(BlockInput{Slot: 100, Attestations: attestations})
+
+	if metrics.ZeroNewVoteAtts != 2 {
+		t.Errorf("expected 2 aggregates without new votes, got %d", metrics.ZeroNewVoteAtts)
+	}
+	if metrics.SubsumedAtts != 1 {
+		t.Errorf("expected 1 subsumed aggregate, got %d", metrics.SubsumedAtts)
+	}
+	// (0,2), (0,3), (1,2), (1,3), (2,3)
+	if metrics.MergeableAttPairs != 5 {
+		t.Errorf("expected 5 mergeable pairs, got %d", metrics.MergeableAttPairs)
+	}
+	if metrics.WastedAttBytes != attestations[1].SizeSSZ()+attestations[3].SizeSSZ() {
+		t.Errorf("unexpected wasted bytes: %d", metrics.WastedAttBytes)
+	}
+}
@@ -0,0 +1,37 @@
+package analysis
+
+import (
+	"context"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/api"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/client_api"
+)
+
+// RequestSource answers the requests whose responses are analyzed,
+// the beacon node when live, a recording when replaying
+type RequestSource interface {
+	SignedBeaconBlock(ctx context.Context, blockID string) (*spec.VersionedSignedBeaconBlock, error)
+	Proposal(ctx context.Context, slot phase0.Slot, client string) (*api.VersionedProposal, time.Duration, error)
+}
+
+// liveSource asks the beacon node
+type liveSource struct {
+	client *client_api.APIClient
+}
+
+func (s liveSource) SignedBeaconBlock(ctx context.Context, blockID string) (*spec.VersionedSignedBeaconBlock, error) {
+	block, err := s.client.Api.SignedBeaconBlock(ctx, &api.SignedBeaconBlockOpts{
+		Block: blockID,
+	})
+	if err != nil || block == nil {
+		return nil, err
+	}
+	return block.Data, nil
+}
+
+func (s liveSource) Proposal(ctx context.Context, slot phase0.Slot, client string) (*api.VersionedProposal, time.Duration, error) {
+	return s.client.ProposeNewBlock(ctx, slot, client)
+}
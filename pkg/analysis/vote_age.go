@@ -0,0 +1,79 @@
+package analysis
+
+import (
+	"sort"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+)
+
+const (
+	// votes older than this are past the source reward window (integer_sqrt(SLOTS_PER_EPOCH))
+	SourceRewardWindow = 5
+)
+
+// VoteAgeStats summarizes how old the new votes of a block are,
+// the age being the slots between the block and the attestation
+type VoteAgeStats struct {
+	Histogram map[uint64]int // age -> new votes
+	Mean      float64
+	Median    uint64
+	P90       uint64
+	Max       uint64
+	Fresh     int // included in the next slot, the only ones rewarded for the head
+	Stale     int // past the source reward window
+	Worthless int // earning no reward at all, wrong or late source, target and head
+}
+
+// VoteAges computes the age distribution of the new votes of a block from its attestation scores
+func VoteAges(slot phase0.Slot, attScores []AttestationScore) VoteAgeStats {
+	stats := VoteAgeStats{
+		Histogram: make(map[uint64]int),
+	}
+	total := 0
+	sum := uint64(0)
+	for _, item := range attScores {
+		if item.NewVotes == 0 || item.Slot >= slot {
+			continue
+		}
+		age := uint64(slot - item.Slot)
+		stats.Histogram[age] += item.NewVotes
+		total += item.NewVotes
+		sum += age * uint64(item.NewVotes)
+
+		if age == 1 {
+			stats.Fresh += item.NewVotes
+		}
+		if age > SourceRewardWindow {
+			stats.Stale += item.NewVotes
+		}
+		if !item.CorrectSource && !item.CorrectTarget && !item.CorrectHead {
+			stats.Worthless += item.NewVotes
+		}
+	}
+	if total == 0 {
+		return stats
+	}
+	stats.Mean = float64(sum) / float64(total)
+
+	ages := make([]uint64, 0, len(stats.Histogram))
+	for age := range stats.Histogram {
+		ages = append(ages, age)
+	}
+	sort.Slice(ages, func(i, j int) bool { return ages[i] < ages[j] })
+	stats.Max = ages[len(ages)-1]
+	stats.Median = ageQuantile(ages, stats.Histogram, total, 0.5)
+	stats.P90 = ageQuantile(ages, stats.Histogram, total, 0.9)
+	return stats
+}
+
+// smallest age covering the given fraction of the votes
+func ageQuantile(ages []uint64, histogram map[uint64]int, total int, quantile float64) uint64 {
+	covered := 0
+	for _, age := range ages {
+		covered += histogram[age]
+		if float64(covered) >= quantile*float64(total) {
+			return age
+		}
+	}
+	return ages[len(ages)-1]
+}
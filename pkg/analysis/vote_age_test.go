@@ -0,0 +1,27 @@
+package analysis
+
+import "testing"
+
+func TestVoteAges(t *testing.T) {
+	attScores := []AttestationScore{
+		{Slot: 99, NewVotes: 6, CorrectSource: true, CorrectTarget: true, CorrectHead: true},
+		{Slot: 98, NewVotes: 2, CorrectSource: true, CorrectTarget: true},
+		{Slot: 97, NewVotes: 0, CorrectSource: true, CorrectTarget: true}, // no new votes
+		{Slot: 90, NewVotes: 1, CorrectTarget: true},
+		{Slot: 80, NewVotes: 1},
+	}
+	stats := VoteAges(100, attScores)
+
+	if stats.Histogram[1] != 6 || stats.Histogram[2] != 2 || stats.Histogram[10] != 1 || stats.Histogram[20] != 1 || len(stats.Histogram) != 4 {
+		t.Errorf("unexpected histogram: %v", stats.Histogram)
+	}
+	if stats.Mean != 4 {
+		t.Errorf("expected a mean age of 4, got %f", stats.Mean)
+	}
+	if stats.Median != 1 || stats.P90 != 10 || stats.Max != 20 {
+		t.Errorf("unexpected quantiles: median %d, p90 %d, max %d", stats.Median, stats.P90, stats.Max)
+	}
+	if stats.Fresh != 6 || stats.Stale != 2 || stats.Worthless != 1 {
+		t.Errorf("unexpected counts: fresh %d, stale %d, worthless %d", stats.Fresh, stats.Stale, stats.Worthless)
+	}
+}
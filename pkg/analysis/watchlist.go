@@ -0,0 +1,374 @@
+package analysis
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/api"
+	api_v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/postgresql"
+	"github.com/migalabs/streameth/pkg/utils"
+)
+
+// Watchlist has the validators to monitor, shared by all the analyzers.
+// It keeps the inclusions of their attestations seen by any node, forks included, until the epoch is closed
+type Watchlist struct {
+	mu           sync.Mutex
+	indices      map[phase0.ValidatorIndex]bool
+	attestations map[phase0.Epoch]map[phase0.ValidatorIndex]map[phase0.Root]WatchedAttestation // by including block
+}
+
+// WatchedAttestation is the attestation of a watched validator in an epoch
+type WatchedAttestation struct {
+	ValidatorIndex phase0.ValidatorIndex
+	Epoch          phase0.Epoch
+	Slot           phase0.Slot // slot the validator attested to
+	Included       bool
+	InclusionSlot  phase0.Slot
+	InclusionRoot  phase0.Root // block that included it
+	CorrectSource  bool
+	CorrectTarget  bool
+	CorrectHead    bool
+}
+
+func (a WatchedAttestation) InclusionDelay() uint64 {
+	if !a.Included {
+		return 0
+	}
+	return uint64(a.InclusionSlot - a.Slot)
+}
+
+func NewWatchlist(indices []phase0.ValidatorIndex) *Watchlist {
+	w := &Watchlist{
+		indices:      make(map[phase0.ValidatorIndex]bool),
+		attestations: make(map[phase0.Epoch]map[phase0.ValidatorIndex]map[phase0.Root]WatchedAttestation),
+	}
+	for _, item := range indices {
+		w.indices[item] = true
+	}
+	return w
+}
+
+func (w *Watchlist) Contains(index phase0.ValidatorIndex) bool {
+	if w == nil {
+		return false
+	}
+	return w.indices[index]
+}
+
+func (w *Watchlist) Indices() []phase0.ValidatorIndex {
+	indices := make([]phase0.ValidatorIndex, 0, len(w.indices))
+	for item := range w.indices {
+		indices = append(indices, item)
+	}
+	return indices
+}
+
+// RecordInclusion keeps the inclusion of the attestation by the block that included it
+func (w *Watchlist) RecordInclusion(attestation WatchedAttestation) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.attestations[attestation.Epoch]; !ok {
+		w.attestations[attestation.Epoch] = make(map[phase0.ValidatorIndex]map[phase0.Root]WatchedAttestation)
+	}
+	inclusions, ok := w.attestations[attestation.Epoch][attestation.ValidatorIndex]
+	if !ok {
+		inclusions = make(map[phase0.Root]WatchedAttestation)
+		w.attestations[attestation.Epoch][attestation.ValidatorIndex] = inclusions
+	}
+	inclusions[attestation.InclusionRoot] = attestation
+}
+
+// CloseEpoch returns the attestation of every watched validator in the epoch: the earliest inclusion in a block
+// of the canonical chain, missed if there is none. canonical tells if the block at the slot is in that chain,
+// it is asked after releasing the watchlist, as it may request blocks.
+// Attestations can be included until the end of the next epoch, so it should be closed after that
+func (w *Watchlist) CloseEpoch(epoch phase0.Epoch, canonical func(root phase0.Root, slot phase0.Slot) (bool, error)) []WatchedAttestation {
+	w.mu.Lock()
+	included := w.attestations[epoch]
+	indices := w.Indices()
+	for item := range w.attestations {
+		if item <= epoch {
+			delete(w.attestations, item)
+		}
+	}
+	w.mu.Unlock()
+
+	resolved := make(map[phase0.Root]bool) // blocks include several watched validators
+	isCanonical := func(item WatchedAttestation) bool {
+		if result, ok := resolved[item.InclusionRoot]; ok {
+			return result
+		}
+		result, err := canonical(item.InclusionRoot, item.InclusionSlot)
+		if err != nil {
+			log.Errorf("could not tell if block %#x at slot %d is canonical, its watched inclusions are ignored: %s",
+				item.InclusionRoot, item.InclusionSlot, err)
+		}
+		resolved[item.InclusionRoot] = result
+		return result
+	}
+
+	result := make([]WatchedAttestation, 0, len(indices))
+	for _, index := range indices {
+		var earliest *WatchedAttestation
+		for _, item := range included[index] {
+			if earliest != nil && earliest.InclusionSlot <= item.InclusionSlot {
+				continue
+			}
+			if isCanonical(item) {
+				item := item
+				earliest = &item
+			}
+		}
+		if earliest != nil {
+			result = append(result, *earliest)
+			continue
+		}
+		result = append(result, WatchedAttestation{
+			ValidatorIndex: index,
+			Epoch:          epoch,
+		})
+	}
+	return result
+}
+
+// IsCanonical tells if the block is the one at the slot in the chain of the current head of the node
+func (b *ClientLiveData) IsCanonical(root phase0.Root, slot phase0.Slot) (bool, error) {
+	head := b.GetHead()
+	if head.Timestamp.IsZero() {
+		return false, fmt.Errorf("no head received from %s yet", b.label)
+	}
+	if head.Slot < slot {
+		return false, fmt.Errorf("head of %s at slot %d is before slot %d", b.label, head.Slot, slot)
+	}
+	canonicalRoot, err := b.canonicalRootAt(head.Root, slot)
+	if err != nil {
+		return false, err
+	}
+	return canonicalRoot == root, nil
+}
+
+// trackWatchedArrival stores when the node received an attestation of a watched validator
+func (b *ClientLiveData) trackWatchedArrival(attestation *phase0.Attestation, timestamp time.Time) {
+	for _, index := range b.watchedAttesters(attestation) {
+		params := make([]interface{}, 0)
+		params = append(params, b.label)
+		params = append(params, uint64(index))
+		params = append(params, uint64(attestation.Data.Slot))
+		params = append(params, uint64(attestation.Data.Index))
+		params = append(params, timestamp)
+		b.DBClient.Persist(b.ctx, postgresql.WriteTask{
+			QueryString: postgresql.InsertNewWatchAttArrival,
+			Params:      params,
+			Type:        postgresql.RecordWatchlist,
+		})
+	}
+}
+
+// trackWatchedInclusions records the attestations of watched validators included in a new head block
+// The votes are judged against the chain of the block: the source against the justified checkpoint of its state,
+// the target and head against the canonical roots, resolved through its ancestors
+func (b *ClientLiveData) trackWatchedInclusions(block *spec.VersionedSignedBeaconBlock) {
+	input, err := BlockInputFromSignedBlock(block)
+	if err != nil {
+		b.log.Errorf("could not read block to track the watchlist: %s", err)
+		return
+	}
+	root, err := block.Root()
+	if err != nil {
+		b.log.Errorf("could not get root of block %d to track the watchlist: %s", input.Slot, err)
+		return
+	}
+	stateRoot, err := block.StateRoot()
+	if err != nil {
+		b.log.Errorf("could not get state root of block %d to track the watchlist: %s", input.Slot, err)
+		return
+	}
+	b.watchChain.add(root, chainBlock{slot: input.Slot, parentRoot: input.ParentRoot})
+	b.watchChain.prune(input.Slot)
+
+	var finality *api_v1.Finality
+	for _, attestation := range input.Attestations {
+		watched := b.watchedAttesters(attestation)
+		if len(watched) == 0 {
+			continue
+		}
+		if finality == nil {
+			if finality, err = b.justifiedAt(stateRoot); err != nil {
+				b.log.Errorf("could not get the checkpoints of block %d to judge the watchlist votes: %s", input.Slot, err)
+				finality = &api_v1.Finality{}
+			}
+		}
+		correctSource := isWatchedSourceCorrect(attestation, input.Slot, finality)
+		correctTarget, correctHead := false, false
+		targetRoot, err := b.canonicalRootAt(input.ParentRoot, phase0.Slot(uint64(attestation.Data.Target.Epoch)*utils.SlotsPerEpoch))
+		if err != nil {
+			b.log.Errorf("could not resolve the target root of the attestation at slot %d: %s", attestation.Data.Slot, err)
+		} else {
+			correctTarget = targetRoot == attestation.Data.Target.Root
+		}
+		headRoot, err := b.canonicalRootAt(input.ParentRoot, attestation.Data.Slot)
+		if err != nil {
+			b.log.Errorf("could not resolve the head root of the attestation at slot %d: %s", attestation.Data.Slot, err)
+		} else {
+			correctHead = headRoot == attestation.Data.BeaconBlockRoot
+		}
+		for _, index := range watched {
+			b.watchlist.RecordInclusion(WatchedAttestation{
+				ValidatorIndex: index,
+				Epoch:          phase0.Epoch(uint64(attestation.Data.Slot) / utils.SlotsPerEpoch),
+				Slot:           attestation.Data.Slot,
+				Included:       true,
+				InclusionSlot:  input.Slot,
+				InclusionRoot:  root,
+				CorrectSource:  correctSource,
+				CorrectTarget:  correctTarget,
+				CorrectHead:    correctHead,
+			})
+		}
+	}
+}
+
+// isWatchedSourceCorrect compares the source of the attestation with the justified checkpoint
+// of the state of the including block: the current one for attestations of the same epoch, the previous one otherwise
+func isWatchedSourceCorrect(attestation *phase0.Attestation, inclusionSlot phase0.Slot, finality *api_v1.Finality) bool {
+	justified := finality.Justified
+	if uint64(attestation.Data.Slot)/utils.SlotsPerEpoch < uint64(inclusionSlot)/utils.SlotsPerEpoch {
+		justified = finality.PreviousJustified
+	}
+	if justified == nil || attestation.Data.Source == nil {
+		return false
+	}
+	return attestation.Data.Source.Epoch == justified.Epoch && attestation.Data.Source.Root == justified.Root
+}
+
+// justifiedAt requests the checkpoints of the given state
+func (b *ClientLiveData) justifiedAt(stateRoot phase0.Root) (*api_v1.Finality, error) {
+	if b.offline {
+		return nil, fmt.Errorf("there is no node to ask")
+	}
+	var finality *api_v1.Finality
+	err := b.withRetries(func() error {
+		response, err := b.Eth2Provider.Api.Finality(b.ctx, &api.FinalityOpts{
+			State: fmt.Sprintf("%#x", stateRoot),
+		})
+		if err != nil {
+			return err
+		}
+		finality = response.Data
+		return nil
+	})
+	return finality, err
+}
+
+// canonicalRootAt walks the ancestors of the given block until the slot,
+// the root is the one of the last block at or before the slot, as skipped slots keep the previous block
+// Ancestors not seen in the head events are requested by root
+func (b *ClientLiveData) canonicalRootAt(from phase0.Root, slot phase0.Slot) (phase0.Root, error) {
+	return b.watchChain.rootAt(from, slot, func(root phase0.Root) (chainBlock, error) {
+		block, err := b.fetchSignedBlock(b.ctx, fmt.Sprintf("%#x", root))
+		if err != nil {
+			return chainBlock{}, err
+		}
+		if block == nil {
+			return chainBlock{}, fmt.Errorf("block %#x is not available", root)
+		}
+		blockSlot, err := block.Slot()
+		if err != nil {
+			return chainBlock{}, err
+		}
+		parentRoot, err := block.ParentRoot()
+		if err != nil {
+			return chainBlock{}, err
+		}
+		return chainBlock{slot: blockSlot, parentRoot: parentRoot}, nil
+	})
+}
+
+// chainBlock links a block to its parent
+type chainBlock struct {
+	slot       phase0.Slot
+	parentRoot phase0.Root
+}
+
+// chainIndex keeps the recent blocks seen by the node by root.
+// Walking the parents from a block only goes through its own chain, so blocks reorged out are never taken as canonical
+type chainIndex struct {
+	mu     sync.Mutex
+	blocks map[phase0.Root]chainBlock
+}
+
+func newChainIndex() *chainIndex {
+	return &chainIndex{
+		blocks: make(map[phase0.Root]chainBlock),
+	}
+}
+
+func (c *chainIndex) add(root phase0.Root, block chainBlock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blocks[root] = block
+}
+
+// prune removes the blocks that attestations included from the given slot on can not reference
+func (c *chainIndex) prune(slot phase0.Slot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for root, block := range c.blocks {
+		if block.slot+2*BlockRootHistoryLength < slot {
+			delete(c.blocks, root)
+		}
+	}
+}
+
+// rootAt returns the root of the last block at or before the slot in the chain of the given block,
+// the missing blocks are resolved with fetch and kept
+func (c *chainIndex) rootAt(from phase0.Root, slot phase0.Slot, fetch func(phase0.Root) (chainBlock, error)) (phase0.Root, error) {
+	root := from
+	for {
+		c.mu.Lock()
+		block, ok := c.blocks[root]
+		c.mu.Unlock()
+		if !ok {
+			if root == (phase0.Root{}) {
+				return phase0.Root{}, fmt.Errorf("slot %d is before genesis", slot)
+			}
+			var err error
+			block, err = fetch(root)
+			if err != nil {
+				return phase0.Root{}, fmt.Errorf("could not resolve block %#x: %s", root, err)
+			}
+			c.add(root, block)
+		}
+		if block.slot <= slot {
+			return root, nil
+		}
+		root = block.parentRoot
+	}
+}
+
+// watchedAttesters resolves the committee of the attestation and returns the watched validators in it
+func (b *ClientLiveData) watchedAttesters(attestation *phase0.Attestation) []phase0.ValidatorIndex {
+	committee := b.EpochData.GetBeaconCommittee(uint64(attestation.Data.Slot), uint64(attestation.Data.Index))
+	if committee == nil {
+		b.log.Debugf("could not retrieve beacon committee at slot %d", uint64(attestation.Data.Slot))
+		return nil
+	}
+	watched := make([]phase0.ValidatorIndex, 0)
+	for _, bit := range attestation.AggregationBits.BitIndices() {
+		if bit < len(committee) && b.watchlist.Contains(committee[bit]) {
+			watched = append(watched, committee[bit])
+		}
+	}
+	return watched
+}
+
+// SetWatchlist enables the tracking of the given validators
+func (b *ClientLiveData) SetWatchlist(watchlist *Watchlist) {
+	b.watchlist = watchlist
+	b.watchChain = newChainIndex()
+}
@@ -0,0 +1,114 @@
+package analysis
+
+import (
+	"fmt"
+	"testing"
+
+	api_v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+)
+
+func TestWatchlistCloseEpoch(t *testing.T) {
+	w := NewWatchlist([]phase0.ValidatorIndex{1, 2, 3})
+	root := func(b byte) phase0.Root { return phase0.Root{b} }
+
+	w.RecordInclusion(WatchedAttestation{ValidatorIndex: 1, Epoch: 3, Slot: 100, Included: true, InclusionSlot: 103, InclusionRoot: root(103)})
+	// the earliest canonical inclusion is kept
+	w.RecordInclusion(WatchedAttestation{ValidatorIndex: 1, Epoch: 3, Slot: 100, Included: true, InclusionSlot: 101, InclusionRoot: root(101), CorrectHead: true})
+	w.RecordInclusion(WatchedAttestation{ValidatorIndex: 1, Epoch: 3, Slot: 100, Included: true, InclusionSlot: 102, InclusionRoot: root(102)})
+	// the earliest inclusion was in a block reorged out
+	w.RecordInclusion(WatchedAttestation{ValidatorIndex: 3, Epoch: 3, Slot: 100, Included: true, InclusionSlot: 101, InclusionRoot: root(201), CorrectHead: true})
+	w.RecordInclusion(WatchedAttestation{ValidatorIndex: 3, Epoch: 3, Slot: 100, Included: true, InclusionSlot: 104, InclusionRoot: root(104)})
+	// only included in a fork
+	w.RecordInclusion(WatchedAttestation{ValidatorIndex: 2, Epoch: 3, Slot: 100, Included: true, InclusionSlot: 102, InclusionRoot: root(202)})
+
+	asked := make(map[phase0.Root]int)
+	canonical := func(r phase0.Root, slot phase0.Slot) (bool, error) {
+		asked[r]++
+		if r == root(104) {
+			return false, fmt.Errorf("block not available")
+		}
+		return r[0] < 200, nil
+	}
+	result := make(map[phase0.ValidatorIndex]WatchedAttestation)
+	for _, item := range w.CloseEpoch(3, canonical) {
+		result[item.ValidatorIndex] = item
+	}
+	if len(result) != 3 {
+		t.Fatalf("expected an attestation per watched validator, got %d", len(result))
+	}
+	if item := result[1]; !item.Included || item.InclusionDelay() != 1 || !item.CorrectHead {
+		t.Errorf("unexpected attestation of validator 1: %+v", item)
+	}
+	if item := result[2]; item.Included || item.Epoch != 3 {
+		t.Errorf("validator 2 should have missed epoch 3: %+v", item)
+	}
+	if item := result[3]; item.Included {
+		t.Errorf("validator 3 was only included in a fork and in an unresolved block: %+v", item)
+	}
+	for r, count := range asked {
+		if count != 1 {
+			t.Errorf("block %#x asked %d times", r, count)
+		}
+	}
+	if len(w.attestations) != 0 {
+		t.Errorf("closed epochs should be removed")
+	}
+}
+
+func TestChainIndexRootAt(t *testing.T) {
+	root := func(b byte) phase0.Root { return phase0.Root{b} }
+	c := newChainIndex()
+	// 10 <- 11 <- 13 (slot 12 skipped in this chain), block 12 on top of 11 was reorged out
+	c.add(root(10), chainBlock{slot: 10, parentRoot: root(9)})
+	c.add(root(11), chainBlock{slot: 11, parentRoot: root(10)})
+	c.add(root(12), chainBlock{slot: 12, parentRoot: root(11)})
+	c.add(root(13), chainBlock{slot: 13, parentRoot: root(11)})
+
+	fetched := 0
+	fetch := func(r phase0.Root) (chainBlock, error) {
+		fetched++
+		if r != root(9) {
+			t.Fatalf("unexpected request of block %#x", r)
+		}
+		return chainBlock{slot: 9, parentRoot: root(8)}, nil
+	}
+
+	expected := map[phase0.Slot]phase0.Root{13: root(13), 12: root(11), 11: root(11), 10: root(10), 9: root(9)}
+	for slot, want := range expected {
+		got, err := c.rootAt(root(13), slot, fetch)
+		if err != nil {
+			t.Fatalf("could not resolve slot %d: %s", slot, err)
+		}
+		if got != want {
+			t.Errorf("slot %d: expected root %#x, got %#x", slot, want, got)
+		}
+	}
+	if fetched != 1 {
+		t.Errorf("the missing ancestor should be requested once, got %d requests", fetched)
+	}
+
+	c.prune(9 + 2*BlockRootHistoryLength + 1)
+	if _, ok := c.blocks[root(9)]; ok {
+		t.Errorf("old blocks should be pruned")
+	}
+}
+
+func TestWatchedSourceCorrect(t *testing.T) {
+	current := &phase0.Checkpoint{Epoch: 3, Root: phase0.Root{3}}
+	previous := &phase0.Checkpoint{Epoch: 2, Root: phase0.Root{2}}
+	finality := &api_v1.Finality{Justified: current, PreviousJustified: previous}
+
+	attestation := testAttestation(4*32+1, 0, 1)
+	attestation.Data.Source = current
+	if !isWatchedSourceCorrect(attestation, 4*32+2, finality) {
+		t.Errorf("attestation of the block epoch should vote for the current justified checkpoint")
+	}
+	if isWatchedSourceCorrect(attestation, 5*32, finality) {
+		t.Errorf("attestation of the previous epoch should vote for the previous justified checkpoint")
+	}
+	attestation.Data.Source = &phase0.Checkpoint{Epoch: 3, Root: phase0.Root{9}}
+	if isWatchedSourceCorrect(attestation, 4*32+2, finality) {
+		t.Errorf("a different root is not the justified checkpoint")
+	}
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const (
+	AdminNodesUrl = "/nodes"
+)
+
+// RunAdmin serves the admin endpoints to add and remove beacon nodes at runtime
+//
+//	GET    /nodes                  list the analyzed nodes
+//	POST   /nodes                  add a node: {"client": "", "label": "", "endpoint": ""}
+//	DELETE /nodes?label=<label>    remove the node with the given label (as listed)
+func (s *AppService) RunAdmin() {
+	mux := http.NewServeMux()
+	mux.HandleFunc(AdminNodesUrl, s.handleAdminNodes)
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", s.adminAddress, s.adminPort),
+		Handler: mux,
+	}
+
+	go func() {
+		<-s.ctx.Done()
+		server.Close()
+	}()
+
+	log.Infof("admin endpoint listening on: %s", server.Addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Errorf("admin endpoint stopped: %s", err)
+	}
+}
+
+type adminNode struct {
+	Client string `json:"client"`
+	Label  string `json:"label"`
+}
+
+func (s *AppService) handleAdminNodes(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		nodes := make([]adminNode, 0)
+		for _, item := range s.GetAnalyzers() {
+			nodes = append(nodes, adminNode{
+				Client: item.GetClient(),
+				Label:  item.GetLabel(),
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(nodes)
+
+	case http.MethodPost:
+		node := NodeDefinition{}
+		if err := json.NewDecoder(r.Body).Decode(&node); err != nil {
+			http.Error(w, fmt.Sprintf("could not parse node: %s", err), http.StatusBadRequest)
+			return
+		}
+		err := s.AddAnalyzer(fmt.Sprintf("%s/%s/%s", node.Client, node.Label, node.Endpoint))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// the history is built in the background
+		w.WriteHeader(http.StatusAccepted)
+
+	case http.MethodDelete:
+		err := s.RemoveAnalyzer(r.URL.Query().Get("label"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
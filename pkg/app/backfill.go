@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/api"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/analysis"
+	"github.com/migalabs/streameth/pkg/config"
+	"github.com/migalabs/streameth/pkg/postgresql"
+)
+
+// archive nodes can take a while to serve old blocks
+var BackfillQueryTimeout = 30 * time.Second
+
+// RunBackfill scores the canonical blocks in [fromSlot, toSlot] using the first beacon node,
+// toSlot is the last finalized slot if 0
+func RunBackfill(ctx context.Context, conf config.StreamethConfig, fromSlot phase0.Slot, toSlot phase0.Slot) error {
+	node, err := ParseNodeDefinition(strings.Split(conf.BnEndpoints, ",")[0])
+	if err != nil {
+		return err
+	}
+
+	// no record can be discarded, every queue blocks when full
+	dbClient, err := postgresql.ConnectToDB(ctx, conf.DBEndpoint, conf.DbWorkers, postgresql.MAX_BATCH_QUEUE, conf.PartitionSlots, postgresql.QueueConfig{
+		Size:           conf.DBQueueSize,
+		DeadLetterFile: conf.DBDeadLetter,
+	})
+	if err != nil {
+		return fmt.Errorf("could not connect to database: %s", err)
+	}
+
+	analyzer, err := analysis.NewBlockAnalyzer(ctx, node.Client, node.Label, node.Endpoint,
+		BackfillQueryTimeout, dbClient, conf.BlocksDir, "")
+	if err != nil {
+		return fmt.Errorf("could not create client for endpoint %s: %s", node.Endpoint, err)
+	}
+	defer analyzer.Close()
+
+	if toSlot == 0 {
+		finalized, err := analyzer.Eth2Provider.Api.BeaconBlockHeader(ctx, &api.BeaconBlockHeaderOpts{
+			Block: "finalized",
+		})
+		if err != nil {
+			return fmt.Errorf("could not obtain finalized block header: %s", err)
+		}
+		toSlot = finalized.Data.Header.Message.Slot
+	}
+	if fromSlot > toSlot {
+		return fmt.Errorf("from slot %d is after to slot %d", fromSlot, toSlot)
+	}
+
+	err = dbClient.EnsurePartitions(uint64(fromSlot), uint64(toSlot))
+	if err != nil {
+		return fmt.Errorf("could not create table partitions: %s", err)
+	}
+
+	log.Infof("backfilling slots %d to %d from %s", fromSlot, toSlot, analyzer.GetLabel())
+	backfillErr := analyzer.Backfill(fromSlot, toSlot)
+
+	// write what is queued even if the backfill failed halfway
+	dbClient.DoneTasks()
+	dbClient.WgDBWriter.Wait()
+
+	return backfillErr
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/migalabs/streameth/pkg/ntp"
+	"github.com/migalabs/streameth/pkg/postgresql"
+)
+
+// parse the ntp servers and interval of the configuration, nil if no server is configured
+func newClockMonitor(servers string, interval string) (*ntp.Monitor, error) {
+	if servers == "" {
+		return nil, nil
+	}
+	duration, err := time.ParseDuration(interval)
+	if err != nil {
+		return nil, err
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("the interval must be positive: %s", interval)
+	}
+	list := make([]string, 0)
+	for _, server := range strings.Split(servers, ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			list = append(list, server)
+		}
+	}
+	return ntp.NewMonitor(list, duration, NtpTimeout, nil), nil
+}
+
+// clockOffset reads the last estimate of the monitor, stored with the arrivals so they can be compared
+// across machines without joining on time. nil if the offset is not measured
+func clockOffset(monitor *ntp.Monitor) func() (time.Duration, bool) {
+	if monitor == nil {
+		return nil
+	}
+	return func() (time.Duration, bool) {
+		estimate := monitor.Estimate()
+		return estimate.Offset, estimate.Valid()
+	}
+}
+
+// Measure the offset of the local clock periodically, storing every round of measurements
+func (s *AppService) RunClockMonitor() {
+	s.clockMonitor.Run(s.ctx, func(measurements []ntp.Measurement, estimate ntp.Estimate) {
+		for _, item := range measurements {
+			params := make([]interface{}, 0)
+			params = append(params, item.Time)
+			params = append(params, item.Server)
+			params = append(params, milliseconds(item.Offset))
+			params = append(params, milliseconds(item.Delay))
+			params = append(params, milliseconds(item.Uncertainty))
+			params = append(params, int(item.Stratum))
+			params = append(params, milliseconds(estimate.Offset))
+			params = append(params, milliseconds(estimate.Uncertainty))
+			params = append(params, s.clockCorrection)
+			s.DBClient.Persist(s.ctx, postgresql.WriteTask{
+				QueryString: postgresql.InsertNewClockOffset,
+				Params:      params,
+				Type:        postgresql.RecordNodeStatus,
+			})
+		}
+	})
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -1,6 +1,15 @@
 package app
 
+import "time"
+
 const (
-	DefaultMetricsPort  = 9080
-	DefaultPrometheusIP = "0.0.0.0"
+	DefaultMetricsPort   = 9080
+	NodeStatusInterval   = 12 * time.Second // poll the node health once per slot
+	HistoryRetryInterval = 12 * time.Second
+	ReportInterval       = 24 * time.Hour
+	FinalityInterval     = 12 * time.Second
+	HeadCheckInterval    = 1 * time.Second
+	NtpTimeout           = 5 * time.Second
+	// nodes apply the epoch transition at slightly different times
+	FinalityDisagreementGrace = 2 * FinalityInterval
 )
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/migalabs/streameth/pkg/config"
+	"github.com/migalabs/streameth/pkg/exporter"
+	"github.com/migalabs/streameth/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// the metrics are served for scraping, and pushed to the configured collectors
+// Both push modes gather the same registry the metric modules register in
+func newExporter(ctx context.Context, conf config.StreamethConfig, pushInterval time.Duration) *exporter.PrometheusMetrics {
+	exporterService := exporter.NewPrometheusMetrics(ctx, conf.MetricsAddress, conf.PrometheusPort, conf.MetricsPath)
+	exporterService.PushInterval = pushInterval
+
+	// pushed series of different hosts are told apart by the instance
+	instance, err := os.Hostname()
+	if err != nil {
+		log.Warnf("could not read hostname for the pushed metrics: %s", err)
+	}
+	if conf.PushgatewayUrl != "" {
+		exporterService.AddPusher(exporter.NewPushgatewayPusher(conf.PushgatewayUrl, conf.PushgatewayJob, instance, prometheus.DefaultGatherer))
+	}
+	if conf.OtlpMetrics != "" {
+		exporterService.AddPusher(exporter.NewOTLPPusher(conf.OtlpMetrics, utils.CliName, utils.Version, instance, prometheus.DefaultGatherer))
+	}
+	return exporterService
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"sync"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/analysis"
+	"github.com/migalabs/streameth/pkg/utils"
+)
+
+// finalityMonitor compares the finality checkpoints of the nodes
+type finalityMonitor struct {
+	mu            sync.Mutex
+	lagEpochs     uint64
+	state         map[string]nodeFinality
+	disagreeSince map[string]time.Time // when the node stopped following the majority
+}
+
+// nodeFinality is the last verdict on a node
+type nodeFinality struct {
+	analysis.FinalityStatus
+	Label       string
+	Client      string
+	LagEpochs   uint64 // epochs since the finalized one
+	Lagging     bool   // lag above the threshold
+	Disagreeing bool   // finalized another root at the epoch of the majority for longer than the grace period
+}
+
+type finalizedCheckpoint struct {
+	epoch phase0.Epoch
+	root  phase0.Root
+}
+
+func newFinalityMonitor(lagEpochs uint64) *finalityMonitor {
+	return &finalityMonitor{
+		lagEpochs:     lagEpochs,
+		state:         make(map[string]nodeFinality),
+		disagreeSince: make(map[string]time.Time),
+	}
+}
+
+// Poll the finality checkpoints of every node and compare them, once per slot
+func (s *AppService) RunFinalityMonitor(wg *sync.WaitGroup) {
+	defer wg.Done()
+	ticker := s.Clock.NewTicker(FinalityInterval)
+	defer ticker.Stop()
+
+	for {
+		var pollWg sync.WaitGroup
+		for _, item := range s.GetAnalyzers() {
+			pollWg.Add(1)
+			go func(item *analysis.ClientLiveData) {
+				defer pollWg.Done()
+				item.UpdateFinality(analysis.FinalitySourcePoll)
+			}(item)
+		}
+		pollWg.Wait()
+		s.checkFinality(s.Clock.Now())
+
+		select {
+		case <-s.ctx.Done():
+			log.Infof("closing finality routine")
+			return
+		case <-ticker.Chan():
+		}
+	}
+}
+
+func (s *AppService) checkFinality(now time.Time) {
+	currentEpoch := phase0.Epoch(uint64(s.ChainTime.CurrentSlot()) / utils.SlotsPerEpoch)
+	nodes := make(map[string]nodeFinality)
+	for _, item := range s.GetAnalyzers() {
+		status := item.GetFinality()
+		if status.Timestamp.IsZero() {
+			continue // not polled yet
+		}
+		nodes[item.GetLabel()] = nodeFinality{FinalityStatus: status, Label: item.GetLabel(), Client: item.GetClient()}
+	}
+	s.finality.check(now, currentEpoch, nodes)
+}
+
+// check updates the verdict of the nodes, and logs when a node starts or stops lagging or disagreeing
+func (m *finalityMonitor) check(now time.Time, currentEpoch phase0.Epoch, nodes map[string]nodeFinality) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// the checkpoint most nodes agree on, the highest epoch on ties
+	votes := make(map[finalizedCheckpoint]int)
+	var majority finalizedCheckpoint
+	for _, item := range nodes {
+		checkpoint := finalizedCheckpoint{epoch: item.FinalizedEpoch, root: item.FinalizedRoot}
+		votes[checkpoint]++
+		if votes[checkpoint] > votes[majority] ||
+			(votes[checkpoint] == votes[majority] && checkpoint.epoch > majority.epoch) {
+			majority = checkpoint
+		}
+	}
+
+	state := make(map[string]nodeFinality)
+	for label, item := range nodes {
+		previous := m.state[label]
+
+		if currentEpoch > item.FinalizedEpoch {
+			item.LagEpochs = uint64(currentEpoch - item.FinalizedEpoch)
+		}
+		item.Lagging = item.LagEpochs > m.lagEpochs
+		if item.Lagging && !previous.Lagging {
+			log.Warnf("finality of %s is lagging: finalized epoch %d, %d epochs behind", label, item.FinalizedEpoch, item.LagEpochs)
+		} else if !item.Lagging && previous.Lagging {
+			log.Infof("finality of %s recovered: finalized epoch %d", label, item.FinalizedEpoch)
+		}
+
+		// a node behind or ahead of the majority is only lagging or leading, it conflicts when
+		// it finalized another root at the same epoch
+		conflicting := item.FinalizedEpoch == majority.epoch && item.FinalizedRoot != majority.root
+		if !conflicting {
+			delete(m.disagreeSince, label)
+		} else if _, ok := m.disagreeSince[label]; !ok {
+			m.disagreeSince[label] = now
+		}
+		since, ok := m.disagreeSince[label]
+		item.Disagreeing = ok && now.Sub(since) >= FinalityDisagreementGrace
+		if item.Disagreeing && !previous.Disagreeing {
+			log.Warnf("%s disagrees on finality: finalized epoch %d (%s), most nodes report epoch %d (%s)",
+				label, item.FinalizedEpoch, item.FinalizedRoot, majority.epoch, majority.root)
+		} else if !item.Disagreeing && previous.Disagreeing {
+			log.Infof("%s agrees on finality again: finalized epoch %d", label, item.FinalizedEpoch)
+		}
+		state[label] = item
+	}
+	// removed nodes
+	for label := range m.disagreeSince {
+		if _, ok := nodes[label]; !ok {
+			delete(m.disagreeSince, label)
+		}
+	}
+	m.state = state
+}
+
+// nodes returns the last verdict of each node
+func (m *finalityMonitor) nodes() map[string]nodeFinality {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state := make(map[string]nodeFinality, len(m.state))
+	for label, item := range m.state {
+		state[label] = item
+	}
+	return state
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/analysis"
+)
+
+func finalized(epoch phase0.Epoch, root byte) nodeFinality {
+	return nodeFinality{FinalityStatus: analysis.FinalityStatus{
+		FinalizedEpoch: epoch,
+		FinalizedRoot:  phase0.Root{root},
+		Timestamp:      time.Now(),
+	}}
+}
+
+func TestFinalityMonitor(t *testing.T) {
+	m := newFinalityMonitor(4)
+	now := time.Now()
+
+	nodes := map[string]nodeFinality{
+		"a": finalized(100, 1),
+		"b": finalized(100, 1),
+		"c": finalized(100, 2), // same epoch, other root
+		"d": finalized(95, 3),  // behind, not conflicting
+		"e": finalized(101, 4), // ahead, not conflicting
+	}
+	m.check(now, 102, nodes)
+	state := m.nodes()
+	if state["a"].Lagging || !state["d"].Lagging || state["d"].LagEpochs != 7 {
+		t.Errorf("unexpected lag: a=%+v d=%+v", state["a"], state["d"])
+	}
+	if state["c"].Disagreeing {
+		t.Errorf("c should not disagree within the grace period")
+	}
+
+	m.check(now.Add(FinalityDisagreementGrace), 102, nodes)
+	state = m.nodes()
+	if !state["c"].Disagreeing || state["d"].Disagreeing || state["e"].Disagreeing || state["a"].Disagreeing {
+		t.Errorf("unexpected disagreement: %+v", state)
+	}
+
+	// c follows the majority again
+	nodes["c"] = finalized(100, 1)
+	m.check(now.Add(2*FinalityDisagreementGrace), 102, nodes)
+	if m.nodes()["c"].Disagreeing {
+		t.Errorf("c should agree again")
+	}
+}
@@ -0,0 +1,227 @@
+package app
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/analysis"
+	"github.com/migalabs/streameth/pkg/postgresql"
+)
+
+// headSource is a node reporting heads, the analyzers
+type headSource interface {
+	GetLabel() string
+	GetClient() string
+	GetHead() analysis.HeadStatus
+	ChainRootAt(slot phase0.Slot) (phase0.Root, bool, error)
+}
+
+// headDivergence is a period in which a node followed a different chain than most nodes
+type headDivergence struct {
+	Label        string
+	Client       string
+	StartSlot    phase0.Slot
+	EndSlot      phase0.Slot
+	Start        time.Time
+	End          time.Time
+	HeadRoot     phase0.Root // head of the node when it diverged
+	MajorityRoot phase0.Root // head of the majority at the same time
+	open         bool        // lasted more than the threshold, stored with no end until it is over
+}
+
+// headMonitor compares the heads of the nodes
+type headMonitor struct {
+	mu        sync.Mutex
+	threshold time.Duration
+	diverging map[string]*headDivergence
+}
+
+func newHeadMonitor(threshold time.Duration) *headMonitor {
+	return &headMonitor{
+		threshold: threshold,
+		diverging: make(map[string]*headDivergence),
+	}
+}
+
+// Compare the heads of every node periodically, and store the divergence windows
+// Windows are stored with no end once they pass the threshold, so they are kept across restarts, and completed when they are over
+func (s *AppService) RunHeadMonitor(wg *sync.WaitGroup) {
+	defer wg.Done()
+	ticker := s.Clock.NewTicker(HeadCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			log.Infof("closing head monitor routine")
+			return
+		case <-ticker.Chan():
+		}
+
+		nodes := make([]headSource, 0)
+		for _, item := range s.GetAnalyzers() {
+			nodes = append(nodes, item)
+		}
+		for _, item := range s.heads.check(s.Clock.Now(), nodes) {
+			params := make([]interface{}, 0)
+			params = append(params, item.Label)
+			params = append(params, item.Client)
+			params = append(params, uint64(item.StartSlot))
+			if item.End.IsZero() {
+				params = append(params, nil)
+				params = append(params, item.Start)
+				params = append(params, nil)
+			} else {
+				params = append(params, uint64(item.EndSlot))
+				params = append(params, item.Start)
+				params = append(params, item.End)
+			}
+			params = append(params, item.HeadRoot.String())
+			params = append(params, item.MajorityRoot.String())
+			s.DBClient.Persist(s.ctx, postgresql.WriteTask{
+				QueryString: postgresql.InsertNewHeadDivergence,
+				Params:      params,
+				Type:        postgresql.RecordHead,
+			})
+		}
+	}
+}
+
+// sameChain is true if the head of one node is an ancestor of (or the same as) the head of the other
+// If the chain of the higher node can not be resolved at the slot of the lower head, they are not told apart
+func sameChain(a headSource, b headSource) bool {
+	headA, headB := a.GetHead(), b.GetHead()
+	if headA.Root == headB.Root {
+		return true
+	}
+	higher, lower := a, headB
+	if headB.Slot > headA.Slot {
+		higher, lower = b, headA
+	}
+	if higher.GetHead().Slot > lower.Slot+analysis.HeadHistoryLength {
+		return true // too far behind to compare, a sync issue rather than a fork
+	}
+	root, skipped, err := higher.ChainRootAt(lower.Slot)
+	if err != nil {
+		log.Debugf("could not compare the chain of %s at slot %d: %s", higher.GetLabel(), lower.Slot, err)
+		return true
+	}
+	return !skipped && root == lower.Root
+}
+
+// isTip is true if no other node has a later head on the same chain
+func isTip(candidate headSource, nodes []headSource) bool {
+	slot := candidate.GetHead().Slot
+	for _, item := range nodes {
+		if item.GetHead().Slot > slot && sameChain(candidate, item) {
+			return false
+		}
+	}
+	return true
+}
+
+// check compares the nodes with the chain most of them follow, and returns the divergence windows to store:
+// the ones that passed the threshold, with no end, and the ones that finished
+// The chains are compared before taking the lock, as it may ask the nodes for block roots
+func (m *headMonitor) check(now time.Time, nodes []headSource) []headDivergence {
+	active := make([]headSource, 0, len(nodes))
+	for _, item := range nodes {
+		if !item.GetHead().Timestamp.IsZero() {
+			active = append(active, item)
+		}
+	}
+	// deterministic choice on ties
+	sort.Slice(active, func(i, j int) bool { return active[i].GetLabel() < active[j].GetLabel() })
+
+	// the reference is the tip most nodes agree with, the highest head on ties.
+	// Nodes behind a tip agree with any fork built on top of their head, so they can not be the reference
+	var reference headSource
+	bestVotes := 0
+	for _, candidate := range active {
+		if !isTip(candidate, active) {
+			continue
+		}
+		votes := 0
+		for _, item := range active {
+			if sameChain(candidate, item) {
+				votes++
+			}
+		}
+		if votes > bestVotes || (votes == bestVotes && candidate.GetHead().Slot > reference.GetHead().Slot) {
+			reference = candidate
+			bestVotes = votes
+		}
+	}
+	onReference := make(map[string]bool, len(active))
+	for _, item := range active {
+		onReference[item.GetLabel()] = sameChain(reference, item)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	windows := make([]headDivergence, 0)
+	seen := make(map[string]bool)
+	for _, item := range active {
+		label := item.GetLabel()
+		seen[label] = true
+		head := item.GetHead()
+		window, diverging := m.diverging[label]
+
+		if onReference[label] {
+			if diverging {
+				if window.open {
+					window.EndSlot = head.Slot
+					window.End = now
+					windows = append(windows, *window)
+					log.Infof("%s is back on the chain of most nodes after %s", label, now.Sub(window.Start))
+				}
+				delete(m.diverging, label)
+			}
+			continue
+		}
+
+		if !diverging {
+			window = &headDivergence{
+				Label:        label,
+				Client:       item.GetClient(),
+				StartSlot:    head.Slot,
+				Start:        now,
+				HeadRoot:     head.Root,
+				MajorityRoot: reference.GetHead().Root,
+			}
+			m.diverging[label] = window
+		}
+		if !window.open && now.Sub(window.Start) >= m.threshold {
+			window.open = true
+			windows = append(windows, *window)
+			log.Warnf("%s follows its own chain since slot %d: head %s at slot %d, most nodes have %s at slot %d",
+				label, window.StartSlot, head.Root, head.Slot, reference.GetHead().Root, reference.GetHead().Slot)
+		}
+	}
+	// removed nodes, their open windows stay stored with no end
+	for label, window := range m.diverging {
+		if !seen[label] {
+			if window.open {
+				log.Infof("%s was removed while following its own chain since slot %d", label, window.StartSlot)
+			}
+			delete(m.diverging, label)
+		}
+	}
+	return windows
+}
+
+// divergingNodes returns the labels of the nodes on their own chain for longer than the threshold
+func (m *headMonitor) divergingNodes() map[string]bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	labels := make(map[string]bool)
+	for label, window := range m.diverging {
+		if window.open {
+			labels[label] = true
+		}
+	}
+	return labels
+}
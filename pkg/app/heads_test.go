@@ -0,0 +1,144 @@
+package app
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/analysis"
+)
+
+type fakeNode struct {
+	label   string
+	roots   map[phase0.Slot]phase0.Root
+	skipped map[phase0.Slot]bool // slots without block in the chain of the node
+	head    analysis.HeadStatus
+	asked   chan struct{} // if set, signaled when a root is asked, which then waits for release
+	release chan struct{}
+}
+
+func (f *fakeNode) GetLabel() string             { return f.label }
+func (f *fakeNode) GetClient() string            { return "client" }
+func (f *fakeNode) GetHead() analysis.HeadStatus { return f.head }
+func (f *fakeNode) ChainRootAt(slot phase0.Slot) (phase0.Root, bool, error) {
+	if f.asked != nil {
+		f.asked <- struct{}{}
+		<-f.release
+	}
+	if root, ok := f.roots[slot]; ok {
+		return root, false, nil
+	}
+	if f.skipped[slot] {
+		return phase0.Root{}, true, nil
+	}
+	return phase0.Root{}, false, fmt.Errorf("unknown slot %d", slot)
+}
+
+func (f *fakeNode) setHead(slot phase0.Slot, root byte) {
+	if f.roots == nil {
+		f.roots = make(map[phase0.Slot]phase0.Root)
+	}
+	f.roots[slot] = phase0.Root{root}
+	f.head = analysis.HeadStatus{Slot: slot, Root: phase0.Root{root}, Timestamp: time.Now()}
+}
+
+func TestHeadMonitor(t *testing.T) {
+	m := newHeadMonitor(24 * time.Second)
+	a, b, c := &fakeNode{label: "a"}, &fakeNode{label: "b"}, &fakeNode{label: "c"}
+	nodes := []headSource{a, b, c}
+	now := time.Now()
+
+	for _, item := range []*fakeNode{a, b, c} {
+		item.setHead(10, 1)
+	}
+	// b is one slot behind on the same chain, c builds on its own block
+	a.setHead(11, 2)
+	c.setHead(11, 3)
+	if windows := m.check(now, nodes); len(windows) != 0 || len(m.divergingNodes()) != 0 {
+		t.Fatalf("no divergence expected within the threshold")
+	}
+
+	b.setHead(11, 2)
+	m.check(now.Add(12*time.Second), nodes)
+	if len(m.divergingNodes()) != 0 {
+		t.Errorf("no divergence expected within the threshold")
+	}
+	// the window is stored with no end once it passes the threshold
+	windows := m.check(now.Add(24*time.Second), nodes)
+	if diverging := m.divergingNodes(); !diverging["c"] || len(diverging) != 1 {
+		t.Errorf("only c should diverge: %v", diverging)
+	}
+	if len(windows) != 1 || windows[0].Label != "c" || !windows[0].End.IsZero() {
+		t.Errorf("unexpected open divergence windows: %+v", windows)
+	}
+
+	// c reorgs to the chain of the others
+	c.setHead(12, 4)
+	a.setHead(12, 4)
+	b.setHead(12, 4)
+	closed := m.check(now.Add(36*time.Second), nodes)
+	if len(closed) != 1 || closed[0].Label != "c" || closed[0].StartSlot != 11 || closed[0].EndSlot != 12 || closed[0].End.IsZero() {
+		t.Errorf("unexpected divergence windows: %+v", closed)
+	}
+	if len(m.divergingNodes()) != 0 {
+		t.Errorf("heads should agree again")
+	}
+}
+
+func TestHeadMonitorUnreportedSlots(t *testing.T) {
+	m := newHeadMonitor(0)
+	a, b, c := &fakeNode{label: "a"}, &fakeNode{label: "b"}, &fakeNode{label: "c"}
+	nodes := []headSource{a, b, c}
+	now := time.Now()
+
+	// a imported slots 11 and 12 in a batch, its chain at slot 11 is unknown
+	a.setHead(13, 3)
+	b.setHead(13, 3)
+	c.setHead(11, 1)
+	if windows := m.check(now, nodes); len(windows) != 0 || len(m.divergingNodes()) != 0 {
+		t.Errorf("a missing root should not be taken as a divergence: %+v", windows)
+	}
+
+	// the chain of the others skipped slot 11, the block of c was orphaned
+	a.skipped = map[phase0.Slot]bool{11: true}
+	b.skipped = map[phase0.Slot]bool{11: true}
+	m.check(now.Add(time.Second), nodes)
+	if diverging := m.divergingNodes(); !diverging["c"] || len(diverging) != 1 {
+		t.Errorf("c should diverge: %v", diverging)
+	}
+
+	// removed while diverging, the stored window keeps no end
+	if windows := m.check(now.Add(2*time.Second), []headSource{a, b}); len(windows) != 0 || len(m.divergingNodes()) != 0 {
+		t.Errorf("removed nodes should be forgotten: %+v", windows)
+	}
+}
+
+func TestHeadMonitorSlowNode(t *testing.T) {
+	m := newHeadMonitor(0)
+	a, b := &fakeNode{label: "a"}, &fakeNode{label: "b"}
+	a.setHead(11, 2)
+	b.setHead(10, 1)
+	a.asked, a.release = make(chan struct{}, 10), make(chan struct{})
+
+	done := make(chan struct{})
+	go func() {
+		m.check(time.Now(), []headSource{a, b})
+		close(done)
+	}()
+	<-a.asked
+
+	// the verdicts can be read while the node answers
+	read := make(chan struct{})
+	go func() {
+		m.divergingNodes()
+		close(read)
+	}()
+	select {
+	case <-read:
+	case <-time.After(time.Second):
+		t.Errorf("the verdicts are locked while the chains are compared")
+	}
+	close(a.release)
+	<-done
+}
@@ -0,0 +1,350 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/migalabs/streameth/pkg/analysis"
+	"github.com/migalabs/streameth/pkg/postgresql"
+	"github.com/migalabs/streameth/pkg/recording"
+	"github.com/migalabs/streameth/pkg/utils"
+)
+
+// NodeDefinition is a beacon node as configured in bn-endpoints (client/label/endpoint)
+type NodeDefinition struct {
+	Client   string `json:"client"`
+	Label    string `json:"label"`
+	Endpoint string `json:"endpoint"`
+}
+
+// parse each beacon node endpoint, need to have 3 sections
+// the client can be left empty (/label/endpoint) to detect it from the node version
+func ParseNodeDefinition(bnEndpoint string) (NodeDefinition, error) {
+	endpointParts := strings.SplitN(strings.TrimSpace(bnEndpoint), "/", 3)
+	if len(endpointParts) < 3 || endpointParts[1] == "" || endpointParts[2] == "" {
+		return NodeDefinition{}, fmt.Errorf("incorrect format for endpoint: %s", bnEndpoint)
+	}
+	return NodeDefinition{
+		Client:   endpointParts[0],
+		Label:    endpointParts[1],
+		Endpoint: endpointParts[2],
+	}, nil
+}
+
+// Key identifies the analyzer of the node, same as its label
+func (n NodeDefinition) Key() string {
+	return analysis.ComposeLabel(n.Label, n.Endpoint)
+}
+
+func newAnalyzer(ctx context.Context,
+	bnEndpoint string,
+	dbClient *postgresql.PostgresDBService,
+	blocksDir string,
+	historyDir string) (*analysis.ClientLiveData, error) {
+
+	node, err := ParseNodeDefinition(bnEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return analysis.NewBlockAnalyzer(
+		ctx,
+		node.Client,
+		node.Label,
+		node.Endpoint,
+		time.Second*5,
+		dbClient,
+		blocksDir,
+		historyDir)
+}
+
+// record what the analyzer consumes from the node, the analyzer keeps running if it can not be recorded
+func (s *AppService) startRecording(item *analysis.ClientLiveData, node NodeDefinition) {
+	err := item.StartRecording(s.recordDir, recording.NodeInfo{
+		Client:   item.GetClient(),
+		Label:    node.Label,
+		Endpoint: node.Endpoint,
+		Genesis:  s.ChainTime.GenesisTime,
+	})
+	if err != nil {
+		log.Errorf("could not record %s: %s", item.GetLabel(), err)
+	}
+}
+
+// GetAnalyzers returns a snapshot of the running analyzers
+func (s *AppService) GetAnalyzers() []*analysis.ClientLiveData {
+	s.analyzersMu.RLock()
+	defer s.analyzersMu.RUnlock()
+
+	analyzers := make([]*analysis.ClientLiveData, len(s.Analyzers))
+	copy(analyzers, s.Analyzers)
+	return analyzers
+}
+
+func (s *AppService) getAnalyzer(label string) *analysis.ClientLiveData {
+	for _, item := range s.GetAnalyzers() {
+		if item.GetLabel() == label {
+			return item
+		}
+	}
+	return nil
+}
+
+func (s *AppService) isMetricEnabled(metric string) bool {
+	for _, item := range s.Metrics {
+		if item == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// start every analyzer configured at startup, building the histories in parallel
+// Analyzers that can not be started are removed
+func (s *AppService) startAnalyzers() {
+	var wg sync.WaitGroup
+	workerNum := s.historyWorkers
+	if workerNum < 1 {
+		workerNum = 1
+	}
+	workers := make(chan struct{}, workerNum)
+
+	for _, item := range s.GetAnalyzers() {
+		wg.Add(1)
+		go func(item *analysis.ClientLiveData) {
+			defer wg.Done()
+			workers <- struct{}{}
+			defer func() { <-workers }()
+
+			err := s.startAnalyzer(item)
+			if err != nil {
+				log.Errorf("could not start analyzer %s, removing it: %s", item.GetLabel(), err)
+				s.RemoveAnalyzer(item.GetLabel())
+			}
+		}(item)
+	}
+	wg.Wait()
+}
+
+// Build the history (if needed) and subscribe to the events of the enabled metrics
+// Subscriptions live as long as the analyzer, so closing it tears them down
+func (s *AppService) startAnalyzer(item *analysis.ClientLiveData) error {
+	topics := make([]string, 0)
+	rawTopics := make([]string, 0)
+	for _, metric := range s.Metrics {
+		if utils.IsEventMetric(metric) {
+			if analysis.RawEventTopics[metric] {
+				rawTopics = append(rawTopics, metric)
+			} else {
+				topics = append(topics, metric)
+			}
+		}
+	}
+	if len(topics) > 0 {
+		err := item.Eth2Provider.Api.Events(item.Context(), topics, item.HandleEvent)
+		if err != nil {
+			return fmt.Errorf("failed to subscribe to %s events: %s", strings.Join(topics, ","), err)
+		}
+	}
+	if len(rawTopics) > 0 {
+		item.Eth2Provider.RawEvents(item.Context(), rawTopics, item.HandleRawEvent)
+	}
+
+	for _, metric := range s.Metrics {
+		switch metric {
+		case utils.AttestationMetric:
+			err := item.Eth2Provider.Api.Events(item.Context(), []string{"attestation"}, item.Recorded(item.HandleAttestationEvent))
+			if err != nil {
+				return fmt.Errorf("failed to subscribe to attestation events: %s", err)
+			}
+
+		case utils.FinalityMetric:
+			// not recorded: the event only triggers a poll of the checkpoints, which a replay has no node to answer
+			err := item.Eth2Provider.Api.Events(item.Context(), []string{"finalized_checkpoint"}, item.HandleFinalizedCheckpointEvent)
+			if err != nil {
+				return fmt.Errorf("failed to subscribe to finalized checkpoint events: %s", err)
+			}
+
+		case utils.HeadsMetric:
+			if s.isMetricEnabled(utils.ProposalMetric) {
+				continue // the head events of the proposals record the heads too
+			}
+			err := item.Eth2Provider.Api.Events(item.Context(), []string{"head"}, item.Recorded(item.HandleHeadRootEvent))
+			if err != nil {
+				return fmt.Errorf("failed to subscribe to head events: %s", err)
+			}
+
+		case utils.ReorgMetric:
+			err := item.Eth2Provider.Api.Events(item.Context(), []string{"chain_reorg"}, item.Recorded(item.HandleReOrgEvent))
+			if err != nil {
+				return fmt.Errorf("failed to subscribe to reorg events: %s", err)
+			}
+
+		case utils.ProposalMetric:
+			err := item.RestoreHistory()
+			if err != nil {
+				log.Warnf("could not restore history of %s, building it from scratch: %s", item.GetLabel(), err)
+			}
+			for {
+				err = item.BuildHistory()
+				if err == nil {
+					break
+				}
+				log.Errorf("could not build history of %s, retrying: %s", item.GetLabel(), err)
+				select {
+				case <-item.Context().Done():
+					return fmt.Errorf("analyzer closed while building history")
+				case <-s.Clock.After(HistoryRetryInterval):
+				}
+			}
+			err = item.Eth2Provider.Api.Events(item.Context(), []string{"head"}, item.Recorded(item.HandleHeadEvent)) // every new head
+			if err != nil {
+				return fmt.Errorf("failed to subscribe to head events: %s", err)
+			}
+		}
+	}
+	return nil
+}
+
+// AddAnalyzer creates a new analyzer for the given node at runtime
+// History and subscriptions are built before it joins the running ones, so it does not disturb them
+func (s *AppService) AddAnalyzer(bnEndpoint string) error {
+	node, err := ParseNodeDefinition(bnEndpoint)
+	if err != nil {
+		return err
+	}
+	if s.getAnalyzer(node.Key()) != nil {
+		return fmt.Errorf("node %s is already being analyzed", node.Key())
+	}
+
+	s.analyzersMu.Lock()
+	if _, ok := s.pendingAnalyzers[node.Key()]; ok {
+		s.analyzersMu.Unlock()
+		return fmt.Errorf("node %s is already being added", node.Key())
+	}
+	s.pendingAnalyzers[node.Key()] = struct{}{}
+	s.analyzersMu.Unlock()
+
+	item, err := s.connect(bnEndpoint)
+	if err != nil {
+		s.analyzersMu.Lock()
+		delete(s.pendingAnalyzers, node.Key())
+		s.analyzersMu.Unlock()
+		return fmt.Errorf("could not create client for endpoint: %s: %s", bnEndpoint, err)
+	}
+	item.SetWatchlist(s.watchlist)
+	item.SetPoolSnapshot(s.poolSnapshot)
+	item.SetClock(s.Clock)
+	item.SetClockOffset(clockOffset(s.clockMonitor))
+	item.SetArrivalDelayObserver(s.arrivalObserver)
+	if s.recordDir != "" {
+		s.startRecording(item, node)
+	}
+
+	go func() {
+		log.Infof("starting new analyzer: %s", item.GetLabel())
+		err := s.startAnalyzer(item)
+
+		s.analyzersMu.Lock()
+		defer s.analyzersMu.Unlock()
+		delete(s.pendingAnalyzers, node.Key())
+		if err != nil {
+			log.Errorf("could not start analyzer %s: %s", item.GetLabel(), err)
+			item.Close()
+			return
+		}
+		s.Analyzers = append(s.Analyzers, item)
+		log.Infof("analyzer %s added", item.GetLabel())
+	}()
+
+	return nil
+}
+
+// RemoveAnalyzer stops the analyzer with the given label and its subscriptions
+func (s *AppService) RemoveAnalyzer(label string) error {
+	s.analyzersMu.Lock()
+	defer s.analyzersMu.Unlock()
+
+	for i, item := range s.Analyzers {
+		if item.GetLabel() == label {
+			s.Analyzers = append(s.Analyzers[:i], s.Analyzers[i+1:]...)
+			item.Close()
+			log.Infof("analyzer %s removed", label)
+			return nil
+		}
+	}
+	return fmt.Errorf("node %s is not being analyzed", label)
+}
+
+// TrackConfigEndpoints marks the nodes of the config file, the only ones a reload removes
+func (s *AppService) TrackConfigEndpoints(bnEndpoints string) {
+	s.analyzersMu.Lock()
+	defer s.analyzersMu.Unlock()
+	s.configNodes = make(map[string]struct{})
+	for _, item := range strings.Split(bnEndpoints, ",") {
+		if node, err := ParseNodeDefinition(item); err == nil {
+			s.configNodes[node.Key()] = struct{}{}
+		}
+	}
+}
+
+// ReloadEndpoints applies the bn-endpoints of the config file: the new nodes are added and the ones
+// of the file that are not listed anymore are removed. Nodes present in both are not touched, and
+// the nodes given in the command line or added through the admin endpoint are kept.
+// Nothing is applied if the list is empty or any endpoint can not be parsed
+func (s *AppService) ReloadEndpoints(bnEndpoints string) error {
+	wanted := make(map[string]string)
+	for _, item := range strings.Split(bnEndpoints, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		node, err := ParseNodeDefinition(item)
+		if err != nil {
+			return err
+		}
+		wanted[node.Key()] = item
+	}
+	if len(wanted) == 0 {
+		return fmt.Errorf("no beacon node in bn-endpoints")
+	}
+
+	s.analyzersMu.Lock()
+	running := make(map[string]bool) // or being added
+	for _, item := range s.Analyzers {
+		running[item.GetLabel()] = true
+	}
+	for key := range s.pendingAnalyzers {
+		running[key] = true
+	}
+	previous := s.configNodes
+	s.configNodes = make(map[string]struct{})
+	for key := range wanted {
+		_, fromFile := previous[key]
+		if fromFile || !running[key] {
+			s.configNodes[key] = struct{}{} // nodes of other origins are not claimed by the file
+		}
+	}
+	s.analyzersMu.Unlock()
+
+	for key := range previous {
+		if _, ok := wanted[key]; ok || !running[key] {
+			continue
+		}
+		if err := s.RemoveAnalyzer(key); err != nil {
+			log.Errorf("could not remove analyzer on reload: %s", err)
+		}
+	}
+
+	for key, bnEndpoint := range wanted {
+		if running[key] {
+			continue
+		}
+		if err := s.AddAnalyzer(bnEndpoint); err != nil {
+			log.Errorf("could not add analyzer on reload: %s", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/migalabs/streameth/pkg/analysis"
+	"github.com/migalabs/streameth/pkg/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+// testNodesService creates offline analyzers, the nodes that can not be reached are the ones labeled "down"
+func testNodesService(t *testing.T) *AppService {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	blocksDir := t.TempDir()
+	return &AppService{
+		ctx:              ctx,
+		cancel:           cancel,
+		pendingAnalyzers: make(map[string]struct{}),
+		configNodes:      make(map[string]struct{}),
+		Clock:            clock.Real{},
+		connect: func(bnEndpoint string) (*analysis.ClientLiveData, error) {
+			node, err := ParseNodeDefinition(bnEndpoint)
+			if err != nil {
+				return nil, err
+			}
+			if node.Label == "down" {
+				return nil, fmt.Errorf("could not reach %s", node.Endpoint)
+			}
+			return analysis.NewReplayAnalyzer(ctx, node.Client, node.Label, node.Endpoint, nil, blocksDir, nil, clock.Real{}), nil
+		},
+	}
+}
+
+func runningLabels(s *AppService) []string {
+	labels := make([]string, 0)
+	for _, item := range s.GetAnalyzers() {
+		labels = append(labels, item.GetLabel())
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// nodes are added in the background, wait for them
+func waitForNodes(t *testing.T, s *AppService, labels ...string) {
+	sort.Strings(labels)
+	assert.Eventually(t, func() bool {
+		s.analyzersMu.RLock()
+		pending := len(s.pendingAnalyzers)
+		s.analyzersMu.RUnlock()
+		return pending == 0 && strings.Join(runningLabels(s), ",") == strings.Join(labels, ",")
+	}, time.Second, time.Millisecond, "expected nodes %v, got %v", labels, runningLabels(s))
+}
+
+func TestAddRemoveAnalyzer(t *testing.T) {
+	s := testNodesService(t)
+
+	assert.Nil(t, s.AddAnalyzer("Teku/a/http://a:5052"))
+	waitForNodes(t, s, "a_http://a:5052")
+	assert.NotNil(t, s.AddAnalyzer("Teku/a/http://a:5052")) // already analyzed
+	assert.NotNil(t, s.AddAnalyzer("Teku/down/http://down:5052"))
+	assert.NotNil(t, s.AddAnalyzer("Teku/a/")) // no endpoint
+	waitForNodes(t, s, "a_http://a:5052")
+
+	item := s.getAnalyzer("a_http://a:5052")
+	assert.Nil(t, s.RemoveAnalyzer("a_http://a:5052"))
+	assert.NotNil(t, item.Context().Err(), "the removed analyzer should be closed")
+	assert.NotNil(t, s.RemoveAnalyzer("a_http://a:5052"))
+	waitForNodes(t, s)
+}
+
+func TestReloadEndpoints(t *testing.T) {
+	s := testNodesService(t)
+	s.TrackConfigEndpoints("Teku/a/http://a:5052,Teku/b/http://b:5052")
+	for _, item := range []string{"Teku/a/http://a:5052", "Teku/b/http://b:5052", "Teku/cli/http://cli:5052"} {
+		assert.Nil(t, s.AddAnalyzer(item))
+	}
+	waitForNodes(t, s, "a_http://a:5052", "b_http://b:5052", "cli_http://cli:5052")
+
+	// b is removed from the file and c added, the node of the command line is kept
+	assert.Nil(t, s.ReloadEndpoints("Teku/a/http://a:5052, Teku/c/http://c:5052"))
+	waitForNodes(t, s, "a_http://a:5052", "c_http://c:5052", "cli_http://cli:5052")
+
+	// a node added through the admin endpoint, and listed afterwards in the file, is not claimed by it
+	assert.Nil(t, s.AddAnalyzer("Teku/admin/http://admin:5052"))
+	waitForNodes(t, s, "a_http://a:5052", "admin_http://admin:5052", "c_http://c:5052", "cli_http://cli:5052")
+	assert.Nil(t, s.ReloadEndpoints("Teku/a/http://a:5052,Teku/admin/http://admin:5052"))
+	waitForNodes(t, s, "a_http://a:5052", "admin_http://admin:5052", "cli_http://cli:5052")
+	assert.Nil(t, s.ReloadEndpoints("Teku/a/http://a:5052"))
+	waitForNodes(t, s, "a_http://a:5052", "admin_http://admin:5052", "cli_http://cli:5052")
+
+	// lists that would tear down the nodes by mistake are refused as a whole
+	assert.NotNil(t, s.ReloadEndpoints(""))
+	assert.NotNil(t, s.ReloadEndpoints(" , "))
+	assert.NotNil(t, s.ReloadEndpoints("Teku/d/http://d:5052,http://e:5052"))
+	waitForNodes(t, s, "a_http://a:5052", "admin_http://admin:5052", "cli_http://cli:5052")
+}
+
+func TestAdminNodes(t *testing.T) {
+	s := testNodesService(t)
+	request := func(method string, target string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.handleAdminNodes(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	w := request(http.MethodPost, AdminNodesUrl, `{"client": "Teku", "label": "a", "endpoint": "http://a:5052"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	waitForNodes(t, s, "a_http://a:5052")
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, AdminNodesUrl, `{"client": "Teku", "label": "a", "endpoint": "http://a:5052"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, AdminNodesUrl, `{"client": "Teku", "label": "", "endpoint": "http://b:5052"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, AdminNodesUrl, `not json`).Code)
+
+	w = request(http.MethodGet, AdminNodesUrl, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	nodes := make([]adminNode, 0)
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&nodes))
+	assert.Equal(t, []adminNode{{Client: "Teku", Label: "a_http://a:5052"}}, nodes)
+
+	assert.Equal(t, http.StatusOK, request(http.MethodDelete, AdminNodesUrl+"?label=a_http://a:5052", "").Code)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodDelete, AdminNodesUrl+"?label=a_http://a:5052", "").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, request(http.MethodPut, AdminNodesUrl, "").Code)
+	waitForNodes(t, s)
+}
@@ -1,7 +1,10 @@
 package app
 
 import (
+	"fmt"
+
 	"github.com/migalabs/streameth/pkg/exporter"
+	"github.com/migalabs/streameth/pkg/utils"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -17,6 +20,169 @@ var (
 	},
 		[]string{"clientName", "label"},
 	)
+
+	NodeIsSyncing = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "clients",
+		Name:      "node_is_syncing",
+		Help:      "Beacon node reports is_syncing",
+	},
+		[]string{"clientName", "label"},
+	)
+
+	NodeElOffline = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "clients",
+		Name:      "node_el_offline",
+		Help:      "Beacon node reports el_offline",
+	},
+		[]string{"clientName", "label"},
+	)
+
+	NodeSyncDistance = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "clients",
+		Name:      "node_sync_distance",
+		Help:      "Slots between the head of the beacon node and the current slot",
+	},
+		[]string{"clientName", "label"},
+	)
+
+	NodeHealthCode = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "clients",
+		Name:      "node_health_code",
+		Help:      "Status code of the beacon node health endpoint",
+	},
+		[]string{"clientName", "label"},
+	)
+
+	NodePeers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "clients",
+		Name:      "node_peers_connected",
+		Help:      "Connected peers of the beacon node",
+	},
+		[]string{"clientName", "label"},
+	)
+
+	NodeVersion = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "clients",
+		Name:      "node_version",
+		Help:      "Version reported by the beacon node",
+	},
+		[]string{"clientName", "label", "version"},
+	)
+
+	NodeJustifiedEpoch = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "clients",
+		Name:      "node_justified_epoch",
+		Help:      "Justified epoch reported by the beacon node",
+	},
+		[]string{"clientName", "label"},
+	)
+
+	NodeFinalizedEpoch = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "clients",
+		Name:      "node_finalized_epoch",
+		Help:      "Finalized epoch reported by the beacon node",
+	},
+		[]string{"clientName", "label"},
+	)
+
+	NodeFinalityLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "clients",
+		Name:      "node_finality_lag_epochs",
+		Help:      "Epochs between the current epoch and the finalized epoch of the beacon node",
+	},
+		[]string{"clientName", "label"},
+	)
+
+	NodeFinalityLagging = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "clients",
+		Name:      "node_finality_lagging",
+		Help:      "The finality of the beacon node lags beyond the configured epochs",
+	},
+		[]string{"clientName", "label"},
+	)
+
+	NodeFinalityDisagreement = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "clients",
+		Name:      "node_finality_disagreement",
+		Help:      "The beacon node finalized another root than most nodes at the same epoch",
+	},
+		[]string{"clientName", "label"},
+	)
+
+	HeadsAgree = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "clients",
+		Name:      "heads_agree",
+		Help:      "All the beacon nodes follow the same chain",
+	})
+
+	NodeHeadDiverging = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "clients",
+		Name:      "node_head_diverging",
+		Help:      "The beacon node follows a different chain than most nodes",
+	},
+		[]string{"clientName", "label"},
+	)
+
+	AttestationArrivalDelay = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "clients",
+		Name:      "attestation_arrival_delay_seconds",
+		Help:      "Delay of the attestation events received by the beacon node, from the slot start or the attestation deadline",
+		Buckets:   []float64{-4, -2, -1, -0.5, 0, 0.25, 0.5, 0.75, 1, 1.5, 2, 3, 4, 6, 8, 12, 24},
+	},
+		[]string{"clientName", "label", "kind"},
+	)
+
+	ClockOffset = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "clock",
+		Name:      "offset_seconds",
+		Help:      "Offset of the local clock measured against the NTP servers, to add to the local time",
+	})
+
+	ClockOffsetUncertainty = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "clock",
+		Name:      "offset_uncertainty_seconds",
+		Help:      "The measured clock offset is within this many seconds of the true one",
+	})
+
+	WatchlistAttestations = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "watchlist",
+		Name:      "attestations",
+		Help:      "Attestations of the watched validators in the last closed epoch, included or missed",
+	},
+		[]string{"result"},
+	)
+
+	WatchlistIncorrectVotes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "watchlist",
+		Name:      "incorrect_votes",
+		Help:      "Included attestations of the watched validators with an incorrect source, target or head vote in the last closed epoch",
+	},
+		[]string{"vote"},
+	)
+
+	WatchlistValidatorMissed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "watchlist",
+		Name:      "validator_missed_attestation",
+		Help:      "The watched validator missed its attestation in the last closed epoch",
+	},
+		[]string{"validator_index"},
+	)
+
+	WatchlistInclusionDelay = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "watchlist",
+		Name:      "inclusion_delay",
+		Help:      "Inclusion delay in slots of the attestation of the watched validator in the last closed epoch",
+	},
+		[]string{"validator_index"},
+	)
+
+	WatchlistNextProposal = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "watchlist",
+		Name:      "next_proposal_slot",
+		Help:      "Next slot the watched validator proposes at, in the current or next epoch",
+	},
+		[]string{"validator_index"},
+	)
 )
 
 func (c *AppService) GetPrometheusMetrics() *exporter.MetricsModule {
@@ -27,6 +193,18 @@ func (c *AppService) GetPrometheusMetrics() *exporter.MetricsModule {
 	// compose all the metrics
 
 	metricsMod.AddIndvMetric(c.getProposalsUp())
+	metricsMod.AddIndvMetric(c.getNodeStatus())
+	metricsMod.AddIndvMetric(c.getFinality())
+	metricsMod.AddIndvMetric(c.getHeads())
+	if c.isMetricEnabled(utils.AttestationMetric) {
+		metricsMod.AddIndvMetric(c.getAttestationArrival())
+	}
+	if c.watchlist != nil {
+		metricsMod.AddIndvMetric(c.getWatchlist())
+	}
+	if c.clockMonitor != nil {
+		metricsMod.AddIndvMetric(c.getClockOffset())
+	}
 
 	return metricsMod
 }
@@ -41,7 +219,7 @@ func (s *AppService) getProposalsUp() *exporter.IndvMetrics {
 	updateFn := func() (interface{}, error) {
 		countUp := 0
 
-		for _, item := range s.Analyzers {
+		for _, item := range s.GetAnalyzers() {
 			ProposalsUp.With(
 				prometheus.Labels{
 					"clientName": item.GetClient(),
@@ -67,3 +245,261 @@ func (s *AppService) getProposalsUp() *exporter.IndvMetrics {
 
 	return indvMetr
 }
+
+func (s *AppService) getNodeStatus() *exporter.IndvMetrics {
+
+	initFn := func() error {
+		prometheus.MustRegister(NodeIsSyncing)
+		prometheus.MustRegister(NodeElOffline)
+		prometheus.MustRegister(NodeSyncDistance)
+		prometheus.MustRegister(NodeHealthCode)
+		prometheus.MustRegister(NodePeers)
+		prometheus.MustRegister(NodeVersion)
+		return nil
+	}
+
+	updateFn := func() (interface{}, error) {
+		countSick := 0
+
+		NodeVersion.Reset() // versions change, do not keep the old ones
+		for _, item := range s.GetAnalyzers() {
+			status := item.GetNodeStatus()
+			labels := prometheus.Labels{
+				"clientName": item.GetClient(),
+				"label":      item.GetLabel(),
+			}
+			NodeIsSyncing.With(labels).Set(boolToFloat(status.IsSyncing))
+			NodeElOffline.With(labels).Set(boolToFloat(status.ElOffline))
+			NodeSyncDistance.With(labels).Set(float64(status.SyncDistance))
+			NodeHealthCode.With(labels).Set(float64(status.HealthCode))
+			NodePeers.With(labels).Set(float64(status.PeersConnected))
+			labels["version"] = status.Version
+			NodeVersion.With(labels).Set(1)
+
+			if status.IsSyncing || status.ElOffline {
+				countSick += 1
+			}
+		}
+		return countSick, nil
+	}
+
+	indvMetr, err := exporter.NewIndvMetrics(
+		"node_status",
+		initFn,
+		updateFn,
+	)
+	if err != nil {
+		log.Error(errors.Wrap(err, "unable to init node_status"))
+		return nil
+	}
+
+	return indvMetr
+}
+
+func (s *AppService) getFinality() *exporter.IndvMetrics {
+
+	initFn := func() error {
+		prometheus.MustRegister(NodeJustifiedEpoch)
+		prometheus.MustRegister(NodeFinalizedEpoch)
+		prometheus.MustRegister(NodeFinalityLag)
+		prometheus.MustRegister(NodeFinalityLagging)
+		prometheus.MustRegister(NodeFinalityDisagreement)
+		return nil
+	}
+
+	updateFn := func() (interface{}, error) {
+		countAlerts := 0
+
+		for _, item := range s.finality.nodes() {
+			labels := prometheus.Labels{
+				"clientName": item.Client,
+				"label":      item.Label,
+			}
+			NodeJustifiedEpoch.With(labels).Set(float64(item.JustifiedEpoch))
+			NodeFinalizedEpoch.With(labels).Set(float64(item.FinalizedEpoch))
+			NodeFinalityLag.With(labels).Set(float64(item.LagEpochs))
+			NodeFinalityLagging.With(labels).Set(boolToFloat(item.Lagging))
+			NodeFinalityDisagreement.With(labels).Set(boolToFloat(item.Disagreeing))
+
+			if item.Lagging || item.Disagreeing {
+				countAlerts += 1
+			}
+		}
+		return countAlerts, nil
+	}
+
+	indvMetr, err := exporter.NewIndvMetrics(
+		"finality",
+		initFn,
+		updateFn,
+	)
+	if err != nil {
+		log.Error(errors.Wrap(err, "unable to init finality"))
+		return nil
+	}
+
+	return indvMetr
+}
+
+func (s *AppService) getHeads() *exporter.IndvMetrics {
+
+	initFn := func() error {
+		prometheus.MustRegister(HeadsAgree)
+		prometheus.MustRegister(NodeHeadDiverging)
+		return nil
+	}
+
+	updateFn := func() (interface{}, error) {
+		diverging := s.heads.divergingNodes()
+
+		for _, item := range s.GetAnalyzers() {
+			NodeHeadDiverging.With(
+				prometheus.Labels{
+					"clientName": item.GetClient(),
+					"label":      item.GetLabel(),
+				},
+			).Set(boolToFloat(diverging[item.GetLabel()]))
+		}
+		HeadsAgree.Set(boolToFloat(len(diverging) == 0))
+		return len(diverging), nil
+	}
+
+	indvMetr, err := exporter.NewIndvMetrics(
+		"heads",
+		initFn,
+		updateFn,
+	)
+	if err != nil {
+		log.Error(errors.Wrap(err, "unable to init heads"))
+		return nil
+	}
+
+	return indvMetr
+}
+
+func (s *AppService) getWatchlist() *exporter.IndvMetrics {
+
+	initFn := func() error {
+		prometheus.MustRegister(WatchlistAttestations)
+		prometheus.MustRegister(WatchlistIncorrectVotes)
+		prometheus.MustRegister(WatchlistValidatorMissed)
+		prometheus.MustRegister(WatchlistInclusionDelay)
+		prometheus.MustRegister(WatchlistNextProposal)
+		return nil
+	}
+
+	updateFn := func() (interface{}, error) {
+		s.watchStats.mu.RLock()
+		defer s.watchStats.mu.RUnlock()
+
+		included, missed := 0, 0
+		incorrect := map[string]int{"source": 0, "target": 0, "head": 0}
+		for _, item := range s.watchStats.attestations {
+			index := fmt.Sprintf("%d", item.ValidatorIndex)
+			if !item.Included {
+				missed++
+				WatchlistValidatorMissed.WithLabelValues(index).Set(1)
+				WatchlistInclusionDelay.DeleteLabelValues(index)
+				continue
+			}
+			included++
+			WatchlistValidatorMissed.WithLabelValues(index).Set(0)
+			WatchlistInclusionDelay.WithLabelValues(index).Set(float64(item.InclusionDelay()))
+			if !item.CorrectSource {
+				incorrect["source"]++
+			}
+			if !item.CorrectTarget {
+				incorrect["target"]++
+			}
+			if !item.CorrectHead {
+				incorrect["head"]++
+			}
+		}
+		WatchlistAttestations.WithLabelValues("included").Set(float64(included))
+		WatchlistAttestations.WithLabelValues("missed").Set(float64(missed))
+		for vote, count := range incorrect {
+			WatchlistIncorrectVotes.WithLabelValues(vote).Set(float64(count))
+		}
+
+		WatchlistNextProposal.Reset() // proposals already done are not kept
+		for index, slot := range s.watchStats.nextProposals {
+			WatchlistNextProposal.WithLabelValues(fmt.Sprintf("%d", index)).Set(float64(slot))
+		}
+		return missed, nil
+	}
+
+	indvMetr, err := exporter.NewIndvMetrics(
+		"watchlist",
+		initFn,
+		updateFn,
+	)
+	if err != nil {
+		log.Error(errors.Wrap(err, "unable to init watchlist"))
+		return nil
+	}
+
+	return indvMetr
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (s *AppService) getClockOffset() *exporter.IndvMetrics {
+
+	initFn := func() error {
+		prometheus.MustRegister(ClockOffset)
+		prometheus.MustRegister(ClockOffsetUncertainty)
+		return nil
+	}
+
+	updateFn := func() (interface{}, error) {
+		estimate := s.clockMonitor.Estimate()
+		if !estimate.Valid() {
+			return nil, nil
+		}
+		ClockOffset.Set(estimate.Offset.Seconds())
+		ClockOffsetUncertainty.Set(estimate.Uncertainty.Seconds())
+		return estimate.Offset.Seconds(), nil
+	}
+
+	indvMetr, err := exporter.NewIndvMetrics(
+		"clock_offset",
+		initFn,
+		updateFn,
+	)
+	if err != nil {
+		log.Error(errors.Wrap(err, "unable to init clock_offset"))
+		return nil
+	}
+
+	return indvMetr
+}
+
+func (s *AppService) getAttestationArrival() *exporter.IndvMetrics {
+
+	initFn := func() error {
+		prometheus.MustRegister(AttestationArrivalDelay)
+		return nil
+	}
+
+	// observed as the attestation events arrive
+	updateFn := func() (interface{}, error) {
+		return nil, nil
+	}
+
+	indvMetr, err := exporter.NewIndvMetrics(
+		"attestation_arrival",
+		initFn,
+		updateFn,
+	)
+	if err != nil {
+		log.Error(errors.Wrap(err, "unable to init attestation_arrival"))
+		return nil
+	}
+
+	return indvMetr
+}
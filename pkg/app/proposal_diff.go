@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/analysis"
+	"github.com/migalabs/streameth/pkg/postgresql"
+)
+
+// what to store of the comparison of the proposals of each slot
+const (
+	ProposalDiffNone     = "none"
+	ProposalDiffSummary  = "summary"  // a row of counters per pair of nodes
+	ProposalDiffDetailed = "detailed" // plus the differing attestations, sync bits and slashings as json
+)
+
+// compare the proposals of every pair of nodes for the same slot and offset
+func (s *AppService) diffProposals(ctx context.Context, slot phase0.Slot, offset time.Duration, proposals []analysis.ProposalContent) {
+	sort.Slice(proposals, func(i, j int) bool {
+		return proposals[i].Label < proposals[j].Label
+	})
+
+	for i := 0; i < len(proposals); i++ {
+		for j := i + 1; j < len(proposals); j++ {
+			diff, err := analysis.DiffProposals(proposals[i], proposals[j])
+			if err != nil {
+				log.Errorf("could not compare proposals of %s and %s at slot %d: %s", proposals[i].Label, proposals[j].Label, slot, err)
+				continue
+			}
+
+			params := make([]interface{}, 0)
+			params = append(params, uint64(slot))
+			params = append(params, int(offset.Milliseconds()))
+			params = append(params, diff.A.Label)
+			params = append(params, diff.B.Label)
+			params = append(params, diff.A.Client)
+			params = append(params, diff.B.Client)
+			params = append(params, diff.CommonAtts)
+			params = append(params, diff.UniqueAttsA)
+			params = append(params, diff.UniqueAttsB)
+			params = append(params, diff.OverlappingAtts)
+			params = append(params, diff.SyncBitsDiff)
+			params = append(params, diff.A.PayloadHash.String())
+			params = append(params, diff.B.PayloadHash.String())
+			params = append(params, diff.UniqueSlashingsA)
+			params = append(params, diff.UniqueSlashingsB)
+			if s.proposalDiff == ProposalDiffDetailed {
+				details, err := json.Marshal(diff.Details)
+				if err != nil {
+					log.Errorf("could not encode proposal diff details: %s", err)
+					params = append(params, nil)
+				} else {
+					params = append(params, string(details))
+				}
+			} else {
+				params = append(params, nil)
+			}
+
+			s.DBClient.Persist(ctx, postgresql.WriteTask{
+				QueryString: postgresql.InsertNewProposalDiff,
+				Params:      params,
+				Type:        postgresql.RecordScore,
+			})
+		}
+	}
+}
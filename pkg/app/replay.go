@@ -0,0 +1,263 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/api"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/analysis"
+	"github.com/migalabs/streameth/pkg/chain_stats"
+	"github.com/migalabs/streameth/pkg/clock"
+	"github.com/migalabs/streameth/pkg/config"
+	"github.com/migalabs/streameth/pkg/postgresql"
+	"github.com/migalabs/streameth/pkg/recording"
+)
+
+// how far after a block request its recorded response is looked for
+var ReplayBlockWindow = 1 * time.Minute
+
+// replayStream reads the recordings of a node, and answers the requests of its analyzer with them
+type replayStream struct {
+	reader   *recording.Reader
+	node     recording.NodeInfo
+	clock    *clock.Fake // simulated clock, follows the time of the records
+	analyzer *analysis.ClientLiveData
+	pending  []recording.Record     // records read ahead, in order
+	done     bool                   // every record was read
+	proposal *api.VersionedProposal // answer to the proposal being replayed
+	duration time.Duration          // time the node took to answer it
+}
+
+func newReplayStream(nodeDir string, clk *clock.Fake) (*replayStream, error) {
+	reader, err := recording.NewReader(nodeDir)
+	if err != nil {
+		return nil, err
+	}
+	s := &replayStream{
+		reader: reader,
+		clock:  clk,
+	}
+	first, ok, err := s.peek()
+	if err != nil || !ok || first.Kind != recording.KindNode {
+		reader.Close()
+		return nil, fmt.Errorf("recording %s does not start with the node info", nodeDir)
+	}
+	if err := json.Unmarshal(first.Data, &s.node); err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("could not decode node info of %s: %s", nodeDir, err)
+	}
+	return s, nil
+}
+
+// read one more record into the pending ones
+func (s *replayStream) readAhead() (bool, error) {
+	if s.done {
+		return false, nil
+	}
+	record, err := s.reader.Next()
+	if err == io.EOF {
+		s.done = true
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	s.pending = append(s.pending, record)
+	return true, nil
+}
+
+// peek returns the next record without consuming it
+func (s *replayStream) peek() (recording.Record, bool, error) {
+	if len(s.pending) == 0 {
+		ok, err := s.readAhead()
+		if !ok || err != nil {
+			return recording.Record{}, false, err
+		}
+	}
+	return s.pending[0], true, nil
+}
+
+func (s *replayStream) pop() recording.Record {
+	record := s.pending[0]
+	s.pending = s.pending[1:]
+	return record
+}
+
+// SignedBeaconBlock answers with the first recorded response to the same request
+// that comes after the current time, within the ReplayBlockWindow
+func (s *replayStream) SignedBeaconBlock(ctx context.Context, blockID string) (*spec.VersionedSignedBeaconBlock, error) {
+	limit := s.clock.Now().Add(ReplayBlockWindow)
+	for i := 0; ; i++ {
+		if i == len(s.pending) {
+			ok, err := s.readAhead()
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+		}
+		record := s.pending[i]
+		if record.Time.After(limit) {
+			break
+		}
+		if record.Kind != recording.KindBlock || record.Request != blockID {
+			continue
+		}
+		s.pending = append(s.pending[:i], s.pending[i+1:]...)
+		block := &spec.VersionedSignedBeaconBlock{}
+		if err := json.Unmarshal(record.Data, block); err != nil {
+			return nil, fmt.Errorf("could not decode recorded block %s: %s", blockID, err)
+		}
+		return block, nil
+	}
+	return nil, fmt.Errorf("block %s was not recorded", blockID)
+}
+
+// Proposal answers with the recorded proposal being replayed
+func (s *replayStream) Proposal(ctx context.Context, slot phase0.Slot, client string) (*api.VersionedProposal, time.Duration, error) {
+	if s.proposal == nil {
+		return nil, 0, fmt.Errorf("proposal of slot %d was not recorded", slot)
+	}
+	return s.proposal, s.duration, nil
+}
+
+// replay hands the record to the analyzer as it was received from the node
+func (s *replayStream) replay(ctx context.Context, record recording.Record) error {
+	switch record.Kind {
+	case recording.KindNode:
+		// a new file of the same node
+		return nil
+	case recording.KindEvent:
+		return s.analyzer.ReplayEvent(record.Topic, record.Data)
+	case recording.KindBlock:
+		// no handler asked for it, it was requested to build the history
+		block := &spec.VersionedSignedBeaconBlock{}
+		if err := json.Unmarshal(record.Data, block); err != nil {
+			return fmt.Errorf("could not decode recorded block %s: %s", record.Request, err)
+		}
+		return s.analyzer.ReplayHistoryBlock(block)
+	case recording.KindProposal:
+		proposal := &api.VersionedProposal{}
+		if err := json.Unmarshal(record.Data, proposal); err != nil {
+			return fmt.Errorf("could not decode recorded proposal of slot %d: %s", record.Slot, err)
+		}
+		s.proposal, s.duration = proposal, record.Duration
+		s.analyzer.ProposeNewBlock(ctx, phase0.Slot(record.Slot), time.Duration(record.OffsetMs)*time.Millisecond)
+		s.proposal, s.duration = nil, 0
+		return nil
+	default:
+		return fmt.Errorf("unknown record kind %s", record.Kind)
+	}
+}
+
+// RunReplay feeds the recordings of every node in the record dir to analyzers,
+// in the order they were recorded, as if the nodes were live
+func RunReplay(ctx context.Context, conf config.StreamethConfig) error {
+	if conf.RecordDir == "" {
+		return fmt.Errorf("record-dir is required")
+	}
+	nodeDirs, err := recording.NodeDirs(conf.RecordDir)
+	if err != nil {
+		return err
+	}
+	if len(nodeDirs) == 0 {
+		return fmt.Errorf("no recordings in %s", conf.RecordDir)
+	}
+
+	clk := clock.NewFake(time.Time{})
+	streams := make([]*replayStream, 0, len(nodeDirs))
+	defer func() {
+		for _, stream := range streams {
+			stream.reader.Close()
+		}
+	}()
+	for _, nodeDir := range nodeDirs {
+		stream, err := newReplayStream(nodeDir, clk)
+		if err != nil {
+			return err
+		}
+		streams = append(streams, stream)
+	}
+
+	// no record can be discarded, every queue blocks when full
+	dbClient, err := postgresql.ConnectToDB(ctx, conf.DBEndpoint, conf.DbWorkers, postgresql.MAX_BATCH_QUEUE, conf.PartitionSlots, postgresql.QueueConfig{
+		Size:           conf.DBQueueSize,
+		DeadLetterFile: conf.DBDeadLetter,
+	})
+	if err != nil {
+		return fmt.Errorf("could not connect to database: %s", err)
+	}
+
+	for _, stream := range streams {
+		stream.analyzer = analysis.NewReplayAnalyzer(ctx, stream.node.Client, stream.node.Label, stream.node.Endpoint,
+			dbClient, conf.BlocksDir, stream, clk)
+		defer stream.analyzer.Close()
+	}
+	chainTime := chain_stats.ChainTime{
+		GenesisTime: streams[0].node.Genesis,
+		Clock:       clk,
+	}
+
+	log.Infof("replaying the recordings of %d nodes from %s", len(streams), conf.RecordDir)
+	ensuredSlot := uint64(0) // partitions exist up to this slot
+	replayErr := replayStreams(ctx, streams, clk, func() error {
+		// partitions are created as the simulated time goes by
+		currentSlot := uint64(chainTime.CurrentSlot())
+		if currentSlot+conf.PartitionSlots <= ensuredSlot {
+			return nil
+		}
+		ensuredSlot = currentSlot + postgresql.PartitionsAhead*conf.PartitionSlots
+		return dbClient.EnsurePartitions(currentSlot, ensuredSlot)
+	})
+
+	// write what is queued even if the replay failed halfway
+	dbClient.DoneTasks()
+	dbClient.WgDBWriter.Wait()
+
+	return replayErr
+}
+
+// replayStreams replays the next record of all the nodes, the oldest first, until every stream is done
+// beforeRecord is called once the clock is set to the time of each record
+func replayStreams(ctx context.Context, streams []*replayStream, clk *clock.Fake, beforeRecord func() error) error {
+	replayed := 0
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var next *replayStream
+		var nextTime time.Time
+		for _, stream := range streams {
+			record, ok, err := stream.peek()
+			if err != nil {
+				return fmt.Errorf("could not read recording of %s: %s", stream.node.Label, err)
+			}
+			if ok && (next == nil || record.Time.Before(nextTime)) {
+				next, nextTime = stream, record.Time
+			}
+		}
+		if next == nil {
+			log.Infof("replayed %d records", replayed)
+			return nil
+		}
+
+		record := next.pop()
+		if record.Time.After(clk.Now()) {
+			// records of different nodes can be slightly out of order
+			clk.Set(record.Time)
+		}
+		if err := beforeRecord(); err != nil {
+			return fmt.Errorf("could not create table partitions: %s", err)
+		}
+		if err := next.replay(ctx, record); err != nil {
+			log.Warnf("could not replay %s record of %s: %s", record.Kind, next.node.Label, err)
+		}
+		replayed++
+	}
+}
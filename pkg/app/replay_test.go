@@ -0,0 +1,72 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/migalabs/streameth/pkg/clock"
+	"github.com/migalabs/streameth/pkg/recording"
+)
+
+func TestReplayStreamBlocks(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := recording.NewRecorder(dir, "node", recording.NodeInfo{Client: "teku", Label: "node"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := &spec.VersionedSignedBeaconBlock{
+		Version: spec.DataVersionDeneb,
+		Deneb: &deneb.SignedBeaconBlock{
+			Message: &deneb.BeaconBlock{Slot: 10, Body: &deneb.BeaconBlockBody{}},
+		},
+	}
+	data, err := json.Marshal(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	records := []recording.Record{
+		{Time: start, Kind: recording.KindEvent, Topic: "head", Data: json.RawMessage(`{}`)},
+		{Time: start.Add(time.Second), Kind: recording.KindEvent, Topic: "attestation", Data: json.RawMessage(`{}`)},
+		{Time: start.Add(2 * time.Second), Kind: recording.KindBlock, Request: "0x01", Data: data},
+		{Time: start.Add(2 * ReplayBlockWindow), Kind: recording.KindBlock, Request: "0x02", Data: data},
+	}
+	for _, record := range records {
+		if err := recorder.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recorder.Close()
+
+	clk := clock.NewFake(time.Time{})
+	stream, err := newReplayStream(dir+"/node", clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.reader.Close()
+	stream.pop() // node info
+
+	// the head event asks for its block, recorded after the attestation
+	head, _, _ := stream.peek()
+	stream.pop()
+	clk.Set(head.Time)
+	result, err := stream.SignedBeaconBlock(context.Background(), "0x01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slot, _ := result.Slot(); slot != 10 {
+		t.Errorf("expected the block of slot 10, got %d", slot)
+	}
+	// the records read ahead are kept in order, without the consumed block
+	if next, _, _ := stream.peek(); next.Topic != "attestation" || len(stream.pending) != 1 {
+		t.Errorf("unexpected pending records: %+v", stream.pending)
+	}
+	// responses too far ahead are not used
+	if _, err := stream.SignedBeaconBlock(context.Background(), "0x02"); err == nil {
+		t.Errorf("expected no block outside of the window")
+	}
+}
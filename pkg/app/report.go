@@ -0,0 +1,112 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/migalabs/streameth/pkg/chain_stats"
+	"github.com/migalabs/streameth/pkg/client_api"
+	"github.com/migalabs/streameth/pkg/config"
+	"github.com/migalabs/streameth/pkg/postgresql"
+	"github.com/migalabs/streameth/pkg/report"
+)
+
+// RunReports writes a client report to the report folder once a day
+func (s *AppService) RunReports() {
+	log := log.WithField("routine", "report")
+
+	if err := os.MkdirAll(s.reportDir, 0755); err != nil {
+		log.Errorf("could not create report folder: %s", err)
+		return
+	}
+
+	ticker := s.Clock.NewTicker(ReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			log.Infof("closing report routine")
+			return
+		case <-ticker.Chan():
+			path, err := s.WriteReport()
+			if err != nil {
+				log.Errorf("could not write report: %s", err)
+				continue
+			}
+			log.Infof("report written to %s", path)
+		}
+	}
+}
+
+// WriteReport writes a report of the last epochs to the report folder and returns its path
+func (s *AppService) WriteReport() (string, error) {
+	r, err := report.Build(s.ctx, s.DBClient, s.reportEpochs, &s.ChainTime)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(s.reportDir, r.FileName(s.reportFormat))
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("could not create report file: %s", err)
+	}
+	defer file.Close()
+
+	if err := r.Write(file, s.reportFormat); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// RunReport builds a report of the last epochs and writes it to output, or to stdout if output is empty.
+// Arrival delays are only computed if a beacon node is configured, to know the genesis time
+func RunReport(ctx context.Context, conf config.StreamethConfig, epochs uint64, format string, output string) error {
+	if !report.ValidFormat(format) {
+		return fmt.Errorf("unknown report format: %s", format)
+	}
+
+	var chainTime *chain_stats.ChainTime
+	if conf.BnEndpoints != "" {
+		node, err := ParseNodeDefinition(strings.Split(conf.BnEndpoints, ",")[0])
+		if err != nil {
+			return err
+		}
+		client, err := client_api.NewAPIClient(ctx, node.Label, node.Endpoint, BackfillQueryTimeout)
+		if err != nil {
+			return fmt.Errorf("could not create client for endpoint %s: %s", node.Endpoint, err)
+		}
+		genesis, err := client.Api.GenesisTime(ctx)
+		if err != nil {
+			return fmt.Errorf("could not obtain genesis time: %s", err)
+		}
+		chainTime = &chain_stats.ChainTime{GenesisTime: genesis}
+	} else {
+		log.Warnf("no beacon node given, block arrival delays will not be computed")
+	}
+
+	// the report only reads, the schema of the database is not touched
+	dbClient, err := postgresql.ConnectReadOnly(ctx, conf.DBEndpoint)
+	if err != nil {
+		return fmt.Errorf("could not connect to database: %s", err)
+	}
+	defer dbClient.Close()
+
+	r, err := report.Build(ctx, dbClient, epochs, chainTime)
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		return r.Write(os.Stdout, format)
+	}
+	file, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("could not create report file: %s", err)
+	}
+	defer file.Close()
+	return r.Write(file, format)
+}
@@ -12,11 +12,16 @@ import (
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/migalabs/streameth/pkg/analysis"
 	"github.com/migalabs/streameth/pkg/chain_stats"
+	"github.com/migalabs/streameth/pkg/clock"
 	"github.com/migalabs/streameth/pkg/config"
 	"github.com/migalabs/streameth/pkg/exporter"
+	"github.com/migalabs/streameth/pkg/ntp"
 	"github.com/migalabs/streameth/pkg/postgresql"
+	"github.com/migalabs/streameth/pkg/report"
+	"github.com/migalabs/streameth/pkg/tracing"
 	"github.com/migalabs/streameth/pkg/utils"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -26,16 +31,43 @@ var (
 )
 
 type AppService struct {
-	ctx             context.Context
-	cancel          context.CancelFunc
-	Analyzers       []*analysis.ClientLiveData
-	initTime        time.Time
-	ChainTime       chain_stats.ChainTime
-	HeadSlot        phase0.Slot
-	Metrics         []string
-	finishTasks     int32
-	DBClient        *postgresql.PostgresDBService
-	ExporterService *exporter.PrometheusMetrics
+	ctx              context.Context
+	cancel           context.CancelFunc
+	analyzersMu      sync.RWMutex // analyzers can be added and removed at runtime
+	Analyzers        []*analysis.ClientLiveData
+	pendingAnalyzers map[string]struct{}                                       // analyzers being added, building their history
+	configNodes      map[string]struct{}                                       // analyzers of the bn-endpoints of the config file, managed by the reload
+	connect          func(bnEndpoint string) (*analysis.ClientLiveData, error) // creates the analyzer of a node
+	blocksDir        string
+	historyDir       string
+	historyWorkers   int
+	adminPort        int
+	adminAddress     string // localhost by default, the admin endpoints can add and remove nodes without authentication
+	initTime         time.Time
+	Clock            clock.Clock // drives the slot timing, shared with the chain time and the analyzers
+	ChainTime        chain_stats.ChainTime
+	HeadSlot         phase0.Slot
+	Metrics          []string
+	ProposalOffsets  []time.Duration // when to ask for proposals, relative to the slot start
+	finishTasks      int32
+	retentionSlots   uint64
+	retentionMode    string
+	downsample       bool
+	reportDir        string
+	reportEpochs     uint64
+	reportFormat     string
+	finality         *finalityMonitor
+	heads            *headMonitor
+	watchlist        *analysis.Watchlist // validators to monitor, nil if none
+	watchStats       watchStats
+	poolSnapshot     bool         // fetch the attestation pool with each proposal
+	proposalDiff     string       // what to store of the comparison of the proposals of each slot
+	recordDir        string       // where to record what is consumed from the nodes, empty to disable
+	clockMonitor     *ntp.Monitor // measures the offset of the local clock, nil if disabled
+	clockCorrection  bool         // the clock is corrected by the measured offset
+	arrivalObserver  *analysis.ArrivalDelayObserver
+	DBClient         *postgresql.PostgresDBService
+	ExporterService  *exporter.PrometheusMetrics
 }
 
 func NewAppService(pCtx context.Context,
@@ -48,52 +80,124 @@ func NewAppService(pCtx context.Context,
 		return &AppService{}, err
 	}
 
+	proposalOffsets, err := utils.ParseProposalOffsets(conf.ProposalOffsets)
+	if err != nil {
+		return &AppService{}, err
+	}
+
+	if err := postgresql.CheckPartitionSlots(conf.PartitionSlots); err != nil {
+		return &AppService{}, err
+	}
+
+	if conf.RetentionMode != postgresql.RetentionDrop && conf.RetentionMode != postgresql.RetentionDetach {
+		return &AppService{}, fmt.Errorf("unknown retention mode: %s", conf.RetentionMode)
+	}
+
+	if conf.ReportDir != "" && !report.ValidFormat(conf.ReportFormat) {
+		return &AppService{}, fmt.Errorf("unknown report format: %s", conf.ReportFormat)
+	}
+
+	if conf.ArrivalFrom != analysis.ArrivalFromSlotStart && conf.ArrivalFrom != analysis.ArrivalFromDeadline {
+		return &AppService{}, fmt.Errorf("unknown attestation delay reference: %s", conf.ArrivalFrom)
+	}
+
+	switch conf.ProposalDiff {
+	case ProposalDiffNone, ProposalDiffSummary, ProposalDiffDetailed:
+	default:
+		return &AppService{}, fmt.Errorf("unknown proposal diff mode: %s", conf.ProposalDiff)
+	}
+
+	pushInterval, err := time.ParseDuration(conf.MetricsPush)
+	if err != nil {
+		return &AppService{}, fmt.Errorf("could not parse metrics push interval: %s", err)
+	}
+	if pushInterval <= 0 {
+		return &AppService{}, fmt.Errorf("metrics push interval must be positive: %s", conf.MetricsPush)
+	}
+
+	overflowPolicies, err := postgresql.ParseOverflowPolicies(conf.DBOverflow)
+	if err != nil {
+		return &AppService{}, err
+	}
+
+	var appClock clock.Clock = clock.Real{}
+	clockMonitor, err := newClockMonitor(conf.NtpServers, conf.NtpInterval)
+	if err != nil {
+		return &AppService{}, fmt.Errorf("could not parse ntp interval: %s", err)
+	}
+	if conf.ClockCorrection && clockMonitor == nil {
+		return &AppService{}, fmt.Errorf("clock-correction needs ntp-servers")
+	}
+	if clockMonitor != nil {
+		// the first measurement is done before anything is timestamped
+		if _, estimate, err := clockMonitor.Measure(); err != nil {
+			log.Warnf("could not measure the clock offset, assuming none: %s", err)
+		} else {
+			log.Infof("local clock offset %s (+-%s) against %s", estimate.Offset, estimate.Uncertainty, estimate.Server)
+		}
+		if conf.ClockCorrection {
+			appClock = clockMonitor.Clock(clock.Real{})
+		}
+	}
+
 	bnEndpoints := strings.Split(conf.BnEndpoints, ",")
 
 	ctx, cancel := context.WithCancel(pCtx)
+
+	// attestations and other append only records are bulk loaded on their own
 	batchLen := len(bnEndpoints)
-	for _, item := range metrics {
-		if item == utils.AttestationMetric {
-			batchLen = 100
-		}
-	}
 
-	dbClient, err := postgresql.ConnectToDB(ctx, conf.DBEndpoint, conf.DbWorkers, batchLen)
+	dbClient, err := postgresql.ConnectToDB(ctx, conf.DBEndpoint, conf.DbWorkers, batchLen, conf.PartitionSlots, postgresql.QueueConfig{
+		Policies:       overflowPolicies,
+		Size:           conf.DBQueueSize,
+		SpillDir:       conf.DBSpillDir,
+		DeadLetterFile: conf.DBDeadLetter,
+		Clock:          appClock,
+	})
 
 	if err != nil {
 		log.Panicf("could not connect to database: %s", err)
 	}
 
 	analyzers := make([]*analysis.ClientLiveData, 0) // one analyzer per beacon node
+	nodes := make([]NodeDefinition, 0)               // the definition of each analyzer
 
 	for i := range bnEndpoints {
-		// parse each beacon node endpoint, need to have 3 sections
-		if !strings.Contains(bnEndpoints[i], "/") || len(strings.Split(bnEndpoints[i], "/")) < 3 {
-			log.Errorf("incorrect format for endpoint: %s", bnEndpoints[i])
-		}
-		client := strings.Split(bnEndpoints[i], "/")[0]
-		label := strings.Split(bnEndpoints[i], "/")[1]
-		endpoint := strings.Split(bnEndpoints[i], "/")[2]
-
-		newAnalyzer, err := analysis.NewBlockAnalyzer(
-			ctx,
-			client,
-			label,
-			endpoint,
-			time.Second*5,
-			dbClient,
-			conf.BlocksDir)
-
+		newAnalyzer, err := newAnalyzer(ctx, bnEndpoints[i], dbClient, conf.BlocksDir, conf.HistoryDir)
 		if err != nil {
-			log.Errorf("could not create client for endpoint: %s ", endpoint, err)
+			log.Errorf("could not create client for endpoint: %s: %s", bnEndpoints[i], err)
 			continue
 		}
+		newAnalyzer.SetPoolSnapshot(conf.PoolSnapshot)
+		newAnalyzer.SetClock(appClock)
+		newAnalyzer.SetClockOffset(clockOffset(clockMonitor))
 		analyzers = append(analyzers, newAnalyzer)
+		node, _ := ParseNodeDefinition(bnEndpoints[i]) // already parsed by newAnalyzer
+		nodes = append(nodes, node)
+	}
+	if len(analyzers) == 0 {
+		cancel()
+		return nil, fmt.Errorf("no beacon node could be configured")
+	}
+	var watchlist *analysis.Watchlist
+	if conf.Watchlist != "" {
+		watchlist, err = newWatchlist(ctx, conf.Watchlist, analyzers[0])
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		for _, item := range analyzers {
+			item.SetWatchlist(watchlist)
+		}
+		if !utils.ContainsMetric(metrics, utils.ProposalMetric) || !utils.ContainsMetric(metrics, utils.AttestationMetric) {
+			log.Warnf("the watchlist needs the proposals (inclusion) and attestations (arrival) metrics")
+		}
 	}
 	// get genesis time to calculate each slot time
 	// Keep in mind first endpoint will be used as master
 	genesis, err := analyzers[0].Eth2Provider.Api.GenesisTime(ctx)
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("could not obtain genesis time: %s", err)
 	}
 	// check the current chain head
@@ -101,27 +205,79 @@ func NewAppService(pCtx context.Context,
 		Block: "head",
 	})
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("could not obtain head block header: %s", err)
 	}
 
 	// Prometheus metrics
-	exporterService := exporter.NewPrometheusMetrics(ctx, DefaultPrometheusIP, conf.PrometheusPort)
+	exporterService := newExporter(ctx, conf, pushInterval)
 
 	appService := &AppService{
-		ctx:       ctx,
-		cancel:    cancel,
-		Analyzers: analyzers,
-		initTime:  time.Now(),
-		HeadSlot:  headHeader.Data.Header.Message.Slot,
+		ctx:              ctx,
+		cancel:           cancel,
+		Analyzers:        analyzers,
+		pendingAnalyzers: make(map[string]struct{}),
+		configNodes:      make(map[string]struct{}),
+		connect: func(bnEndpoint string) (*analysis.ClientLiveData, error) {
+			return newAnalyzer(ctx, bnEndpoint, dbClient, conf.BlocksDir, conf.HistoryDir)
+		},
+		blocksDir:       conf.BlocksDir,
+		historyDir:      conf.HistoryDir,
+		historyWorkers:  conf.HistoryWorkers,
+		adminPort:       conf.AdminPort,
+		adminAddress:    conf.AdminAddress,
+		retentionSlots:  conf.RetentionSlots,
+		retentionMode:   conf.RetentionMode,
+		downsample:      conf.Downsample,
+		reportDir:       conf.ReportDir,
+		reportEpochs:    conf.ReportEpochs,
+		reportFormat:    conf.ReportFormat,
+		finality:        newFinalityMonitor(conf.FinalityLag),
+		watchlist:       watchlist,
+		poolSnapshot:    conf.PoolSnapshot,
+		proposalDiff:    conf.ProposalDiff,
+		recordDir:       conf.RecordDir,
+		clockMonitor:    clockMonitor,
+		clockCorrection: conf.ClockCorrection,
+		heads:           newHeadMonitor(time.Duration(conf.HeadDivergence) * chain_stats.SLOT_DURATION * time.Second),
+		initTime:        appClock.Now(),
+		Clock:           appClock,
+		HeadSlot:        headHeader.Data.Header.Message.Slot,
 		ChainTime: chain_stats.ChainTime{
 			GenesisTime: genesis,
+			Clock:       appClock,
+		},
+		arrivalObserver: &analysis.ArrivalDelayObserver{
+			ChainTime: chain_stats.ChainTime{GenesisTime: genesis},
+			Reference: conf.ArrivalFrom,
+			Histogram: AttestationArrivalDelay,
 		},
 		Metrics:         metrics,
+		ProposalOffsets: proposalOffsets,
 		DBClient:        dbClient,
 		ExporterService: exporterService,
 	}
 
+	// partitions for the current slots must exist before the first insert
+	currentSlot := uint64(appService.ChainTime.CurrentSlot())
+	err = dbClient.EnsurePartitions(currentSlot, currentSlot+postgresql.PartitionsAhead*conf.PartitionSlots)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("could not create table partitions: %s", err)
+	}
+
+	for _, item := range analyzers {
+		item.SetArrivalDelayObserver(appService.arrivalObserver)
+	}
+
+	if conf.RecordDir != "" {
+		for i, item := range analyzers {
+			appService.startRecording(item, nodes[i])
+		}
+	}
+
 	exporterService.AddMetricsModule(appService.GetPrometheusMetrics())
+	exporterService.AddMetricsModule(dbClient.GetPrometheusMetrics())
 
 	exporterService.Start()
 
@@ -133,16 +289,57 @@ func (s *AppService) Run() {
 
 	defer s.cancel()
 	var wg sync.WaitGroup
+
+	// the node status is always needed, proposals are skipped on sick nodes
+	go s.RunNodeStatus()
+
+	go s.DBClient.RunRetention(s.retentionSlots, s.retentionMode, s.downsample, func() uint64 {
+		return uint64(s.ChainTime.CurrentSlot())
+	})
+
+	if s.adminPort > 0 {
+		go s.RunAdmin()
+	}
+
+	if s.clockMonitor != nil {
+		go s.RunClockMonitor()
+	}
+
+	if s.reportDir != "" {
+		go s.RunReports()
+	}
+
+	if s.watchlist != nil {
+		log.Infof("initiating watchlist monitoring")
+		wg.Add(1)
+		go s.RunWatchlist(&wg)
+	}
+
 	for _, item := range s.Metrics {
 		if item == utils.AttestationMetric {
 			log.Infof("initiating attestation events monitoring")
 			wg.Add(1)
-			s.RunAttestations()
 		}
 
 		if item == utils.ReorgMetric {
 			wg.Add(1)
-			s.RunReOrgs()
+		}
+
+		if item == utils.FinalityMetric {
+			log.Infof("initiating finality monitoring")
+			wg.Add(1)
+			go s.RunFinalityMonitor(&wg)
+		}
+
+		if item == utils.HeadsMetric {
+			log.Infof("initiating head divergence monitoring")
+			wg.Add(1)
+			go s.RunHeadMonitor(&wg)
+		}
+
+		if utils.IsEventMetric(item) {
+			log.Infof("initiating %s events monitoring", item)
+			wg.Add(1)
 		}
 
 		if item == utils.ProposalMetric {
@@ -152,33 +349,31 @@ func (s *AppService) Run() {
 		}
 	}
 
+	if !s.isMetricEnabled(utils.ProposalMetric) {
+		// otherwise the main routine starts them once the history is built
+		s.startAnalyzers()
+	}
+
 	wg.Wait()
 
 }
 
-// Main routine: build block history and block proposals every 12 seconds
-func (s *AppService) RunAttestations() {
+// Poll the health of every beacon node periodically
+func (s *AppService) RunNodeStatus() {
+	ticker := s.Clock.NewTicker(NodeStatusInterval)
+	defer ticker.Stop()
 
-	// Subscribe to events from each client
-	for _, item := range s.Analyzers {
-		err := item.Eth2Provider.Api.Events(s.ctx, []string{"attestation"}, item.HandleAttestationEvent) // every new head
-		if err != nil {
-			log.Panicf("failed to subscribe to head events: %s, label: %s", err, item.GetLabel())
+	for {
+		for _, item := range s.GetAnalyzers() {
+			go item.UpdateNodeStatus()
 		}
 
-	}
-}
-
-// Main routine: build block history and block proposals every 12 seconds
-func (s *AppService) RunReOrgs() {
-
-	// Subscribe to events from each client
-	for _, item := range s.Analyzers {
-		err := item.Eth2Provider.Api.Events(s.ctx, []string{"chain_reorg"}, item.HandleAttestationEvent) // every new head
-		if err != nil {
-			log.Panicf("failed to subscribe to reorg events: %s, label: %s", err, item.GetLabel())
+		select {
+		case <-s.ctx.Done():
+			log.Infof("closing node status routine")
+			return
+		case <-ticker.Chan():
 		}
-
 	}
 }
 
@@ -186,29 +381,13 @@ func (s *AppService) RunReOrgs() {
 func (s *AppService) RunMainRoutine(wg *sync.WaitGroup) {
 	defer wg.Done()
 	log = log.WithField("routine", "main")
-	historyBuilt := false
-
-	for !historyBuilt {
-		historyBuilt = true
-		for _, item := range s.Analyzers {
-			ok := item.BuildHistory()
-			if !ok {
-				historyBuilt = false
-			}
-		}
-	}
 
-	// Subscribe to events from each client
-	for _, item := range s.Analyzers {
-		err := item.Eth2Provider.Api.Events(s.ctx, []string{"head"}, item.HandleHeadEvent) // every new head
-		if err != nil {
-			log.Panicf("failed to subscribe to head events: %s, label: %s", err, item.GetLabel())
-		}
-
-	}
+	// build the history and subscribe to events of each client
+	s.startAnalyzers()
 
 	// tick every slot start (12 seconds)
-	ticker := time.After(time.Until(s.ChainTime.SlotTime(phase0.Slot(s.HeadSlot + 1))))
+	ticker := s.ChainTime.SlotTicker()
+	defer ticker.Stop()
 loop:
 	for {
 
@@ -223,26 +402,74 @@ loop:
 			s.DBClient.DoneTasks() // all the analyzers have the same db client
 			break loop
 
-		case <-ticker:
+		case tick := <-ticker.C:
 			// we entered a new slot time
-			s.HeadSlot++
-			log.Infof("Entered a new slot!: %d, time: %s", s.HeadSlot, time.Now())
-			// reset ticker to next slot
-			ticker = time.After(time.Until(s.ChainTime.SlotTime(phase0.Slot(s.HeadSlot + 1))))
+			if tick.Skipped > 0 {
+				log.Warnf("skipped %d slots, the clock jumped or the routine was late", tick.Skipped)
+			}
+			s.HeadSlot = phase0.Slot(tick.Period)
+			log.Infof("Entered a new slot!: %d, time: %s", s.HeadSlot, s.Clock.Now())
 			// a new slot has begun, therefore execute all needed actions
-			log.Tracef("Time until next slot tick: %s", time.Until(s.ChainTime.SlotTime(phase0.Slot(s.HeadSlot+1))).String())
-			for _, analyzer := range s.Analyzers {
-				// for each beacon node, get a new block and analyze it
-				go analyzer.ProposeNewBlock(s.HeadSlot)
+			log.Tracef("Time until next slot tick: %s", s.ChainTime.Until(s.ChainTime.SlotTime(s.HeadSlot+1)).String())
+			for _, offset := range s.ProposalOffsets {
+				proposalSlot := s.HeadSlot
+				if offset < 0 {
+					// ask before the next slot begins
+					proposalSlot++
+				}
+				go s.proposeAt(proposalSlot, offset)
 			}
 		}
 	}
 	log.Infof("finished")
 }
 
+// Wait until the offset of the slot is reached and ask every beacon node for a block
+func (s *AppService) proposeAt(slot phase0.Slot, offset time.Duration) {
+	select {
+	case <-s.ctx.Done():
+		return
+	case <-s.Clock.After(s.ChainTime.Until(s.ChainTime.SlotTime(slot).Add(offset))):
+	}
+
+	ctx, span := tracing.Tracer().Start(s.ctx, "SlotTick", trace.WithAttributes(
+		tracing.SlotKey.Int64(int64(slot)),
+		tracing.OffsetKey.String(offset.String())))
+	defer span.End()
+
+	var wg sync.WaitGroup
+	var proposalsMu sync.Mutex
+	proposals := make([]analysis.ProposalContent, 0)
+	for _, analyzer := range s.GetAnalyzers() {
+		// for each beacon node, get a new block and analyze it
+		wg.Add(1)
+		go func(analyzer *analysis.ClientLiveData) {
+			defer wg.Done()
+			block := analyzer.ProposeNewBlock(ctx, slot, offset)
+			if block == nil || s.proposalDiff == ProposalDiffNone {
+				return
+			}
+			proposalsMu.Lock()
+			proposals = append(proposals, analysis.ProposalContentFromProposal(analyzer.GetLabel(), analyzer.GetClient(), block))
+			proposalsMu.Unlock()
+		}(analyzer)
+	}
+	wg.Wait()
+
+	if len(proposals) > 1 {
+		s.diffProposals(ctx, slot, offset, proposals)
+	}
+}
+
 func (s *AppService) Close() {
 	log.Info("Sudden closed detected, closing Live Metrics")
 	atomic.AddInt32(&s.finishTasks, int32(1))
+	for _, item := range s.GetAnalyzers() {
+		if err := item.SaveHistory(); err != nil {
+			log.Errorf("could not save history of %s: %s", item.GetLabel(), err)
+		}
+		item.StopRecording()
+	}
 	s.DBClient.WgDBWriter.Wait()
 	s.cancel()
 }
@@ -0,0 +1,44 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/migalabs/streameth/pkg/config"
+	"github.com/migalabs/streameth/pkg/utils"
+)
+
+func TestNewAppServicePushInterval(t *testing.T) {
+	for _, interval := range []string{"0s", "-1s"} {
+		conf := *config.NewStreamethConfig()
+		conf.Metrics = utils.ProposalMetric
+		conf.MetricsPush = interval
+		_, err := NewAppService(context.Background(), conf)
+		if err == nil || !strings.Contains(err.Error(), "push interval") {
+			t.Errorf("push interval %s should be rejected, got %v", interval, err)
+		}
+	}
+}
+
+func TestNewClockMonitor(t *testing.T) {
+	monitor, err := newClockMonitor("", "0s")
+	if err != nil || monitor != nil {
+		t.Errorf("no servers should disable the monitor, got %v %v", monitor, err)
+	}
+	if clockOffset(monitor) != nil {
+		t.Errorf("no monitor should not give a clock offset")
+	}
+	for _, interval := range []string{"0s", "-1m", "one minute"} {
+		if _, err := newClockMonitor("pool.ntp.org", interval); err == nil {
+			t.Errorf("ntp interval %s should be rejected", interval)
+		}
+	}
+	monitor, err = newClockMonitor("a, b", "1m")
+	if err != nil || monitor == nil {
+		t.Fatalf("could not create the monitor: %v", err)
+	}
+	if _, ok := clockOffset(monitor)(); ok {
+		t.Errorf("the offset should not be valid before any measurement")
+	}
+}
@@ -0,0 +1,173 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/attestantio/go-eth2-client/api"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/analysis"
+	"github.com/migalabs/streameth/pkg/postgresql"
+	"github.com/migalabs/streameth/pkg/utils"
+)
+
+// watchStats is the outcome of the last closed epoch for the watched validators, exported to Prometheus
+type watchStats struct {
+	mu            sync.RWMutex
+	epoch         phase0.Epoch
+	attestations  []analysis.WatchedAttestation
+	nextProposals map[phase0.ValidatorIndex]phase0.Slot
+}
+
+// newWatchlist parses the configured validators, pubkeys are resolved with the given node
+func newWatchlist(ctx context.Context, input string, node *analysis.ClientLiveData) (*analysis.Watchlist, error) {
+	indices, pubkeys, err := utils.ParseWatchlist(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(pubkeys) > 0 {
+		validators, err := node.Eth2Provider.Api.Validators(ctx, &api.ValidatorsOpts{
+			State:   "head",
+			PubKeys: pubkeys,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve watchlist pubkeys: %s", err)
+		}
+		if len(validators.Data) < len(pubkeys) {
+			log.Warnf("only %d of %d watchlist pubkeys are known validators", len(validators.Data), len(pubkeys))
+		}
+		for index := range validators.Data {
+			indices = append(indices, index)
+		}
+	}
+	if len(indices) == 0 {
+		return nil, fmt.Errorf("watchlist is empty")
+	}
+	return analysis.NewWatchlist(indices), nil
+}
+
+// Close the attestations of the watched validators and refresh their proposer duties at every epoch start
+// The inclusions of the epoch the routine starts in were not all seen, so only the epochs after it are closed
+func (s *AppService) RunWatchlist(wg *sync.WaitGroup) {
+	defer wg.Done()
+	log := log.WithField("routine", "watchlist")
+	ticker := s.ChainTime.EpochTicker()
+	defer ticker.Stop()
+
+	currentEpoch := phase0.Epoch(uint64(s.ChainTime.CurrentSlot()) / utils.SlotsPerEpoch)
+	firstEpoch := currentEpoch + 1 // first epoch fully observed
+	log.Infof("watched attestations are closed from epoch %d on", firstEpoch)
+	for {
+		s.updateWatchedDuties(currentEpoch)
+		// attestations can be included until the end of the next epoch
+		if currentEpoch >= firstEpoch+2 {
+			s.closeWatchedEpoch(currentEpoch - 2)
+		}
+
+		select {
+		case <-s.ctx.Done():
+			log.Infof("closing watchlist routine")
+			return
+		case tick := <-ticker.C:
+			// the attestations of the skipped epochs are closed too
+			for epoch := currentEpoch + 1; epoch < phase0.Epoch(tick.Period); epoch++ {
+				if epoch >= firstEpoch+2 {
+					s.closeWatchedEpoch(epoch - 2)
+				}
+			}
+			currentEpoch = phase0.Epoch(tick.Period)
+		}
+	}
+}
+
+// closeWatchedEpoch stores the attestations of the watched validators in the epoch, the inclusions
+// are judged against the chain of the first node, the one the duties are requested from
+func (s *AppService) closeWatchedEpoch(epoch phase0.Epoch) {
+	analyzers := s.GetAnalyzers()
+	if len(analyzers) == 0 {
+		log.Errorf("no node to resolve the canonical chain, epoch %d of the watchlist is not closed", epoch)
+		return
+	}
+	attestations := s.watchlist.CloseEpoch(epoch, analyzers[0].IsCanonical)
+
+	missed := 0
+	for _, item := range attestations {
+		params := make([]interface{}, 0)
+		params = append(params, uint64(item.ValidatorIndex))
+		params = append(params, uint64(item.Epoch))
+		if item.Included {
+			params = append(params, uint64(item.Slot))
+			params = append(params, true)
+			params = append(params, uint64(item.InclusionSlot))
+			params = append(params, item.InclusionDelay())
+		} else {
+			missed++
+			params = append(params, nil)
+			params = append(params, false)
+			params = append(params, nil)
+			params = append(params, nil)
+		}
+		params = append(params, item.CorrectSource)
+		params = append(params, item.CorrectTarget)
+		params = append(params, item.CorrectHead)
+		s.DBClient.Persist(s.ctx, postgresql.WriteTask{
+			QueryString: postgresql.InsertNewWatchAttestation,
+			Params:      params,
+			Type:        postgresql.RecordWatchlist,
+		})
+	}
+	if missed > 0 {
+		log.Warnf("%d of %d watched validators missed their attestation in epoch %d", missed, len(attestations), epoch)
+	}
+
+	s.watchStats.mu.Lock()
+	s.watchStats.epoch = epoch
+	s.watchStats.attestations = attestations
+	s.watchStats.mu.Unlock()
+}
+
+// updateWatchedDuties stores the proposals of the watched validators in the current and next epochs
+func (s *AppService) updateWatchedDuties(currentEpoch phase0.Epoch) {
+	analyzers := s.GetAnalyzers()
+	if len(analyzers) == 0 {
+		return
+	}
+
+	currentSlot := s.ChainTime.CurrentSlot()
+	nextProposals := make(map[phase0.ValidatorIndex]phase0.Slot)
+	for _, epoch := range []phase0.Epoch{currentEpoch, currentEpoch + 1} {
+		duties, err := analyzers[0].Eth2Provider.ProposerDuties(epoch)
+		if err != nil {
+			log.Errorf("could not get watchlist proposer duties: %s", err)
+			continue
+		}
+		for _, duty := range duties {
+			if !s.watchlist.Contains(duty.ValidatorIndex) {
+				continue
+			}
+			params := make([]interface{}, 0)
+			params = append(params, uint64(duty.ValidatorIndex))
+			params = append(params, uint64(epoch))
+			params = append(params, uint64(duty.Slot))
+			params = append(params, s.Clock.Now())
+			s.DBClient.Persist(s.ctx, postgresql.WriteTask{
+				QueryString: postgresql.InsertNewWatchProposerDuty,
+				Params:      params,
+				Type:        postgresql.RecordWatchlist,
+			})
+
+			if duty.Slot < currentSlot {
+				continue
+			}
+			if next, ok := nextProposals[duty.ValidatorIndex]; !ok || duty.Slot < next {
+				nextProposals[duty.ValidatorIndex] = duty.Slot
+			}
+			log.Infof("watched validator %d proposes at slot %d", duty.ValidatorIndex, duty.Slot)
+		}
+	}
+
+	s.watchStats.mu.Lock()
+	s.watchStats.nextProposals = nextProposals
+	s.watchStats.mu.Unlock()
+}
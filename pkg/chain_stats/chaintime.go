@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/clock"
+	"github.com/migalabs/streameth/pkg/utils"
 )
 
 const (
@@ -12,9 +14,34 @@ const (
 
 type ChainTime struct {
 	GenesisTime time.Time
+	Clock       clock.Clock // the system clock if nil
 }
 
 // Calculate at which time a given slot happens
 func (c ChainTime) SlotTime(slot phase0.Slot) time.Time {
 	return c.GenesisTime.Add(time.Duration(slot) * SLOT_DURATION * time.Second)
 }
+
+// Calculate the slot at the current time
+func (c ChainTime) CurrentSlot() phase0.Slot {
+	elapsed := clock.OrReal(c.Clock).Now().Sub(c.GenesisTime)
+	if elapsed < 0 {
+		return 0
+	}
+	return phase0.Slot(elapsed / (SLOT_DURATION * time.Second))
+}
+
+// Until returns how long until the given time, by the clock
+func (c ChainTime) Until(t time.Time) time.Duration {
+	return t.Sub(clock.OrReal(c.Clock).Now())
+}
+
+// SlotTicker announces the start of every slot from now on
+func (c ChainTime) SlotTicker() *clock.PeriodTicker {
+	return clock.NewPeriodTicker(clock.OrReal(c.Clock), c.GenesisTime, SLOT_DURATION*time.Second)
+}
+
+// EpochTicker announces the start of every epoch from now on
+func (c ChainTime) EpochTicker() *clock.PeriodTicker {
+	return clock.NewPeriodTicker(clock.OrReal(c.Clock), c.GenesisTime, utils.SlotsPerEpoch*SLOT_DURATION*time.Second)
+}
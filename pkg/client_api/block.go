@@ -1,6 +1,7 @@
 package client_api
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -8,11 +9,14 @@ import (
 
 	"github.com/attestantio/go-eth2-client/api"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/tracing"
 	"github.com/migalabs/streameth/pkg/utils"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Asks for a block proposal to the client and stores score in the database
-func (b *APIClient) ProposeNewBlock(slot phase0.Slot, client string) (*api.VersionedProposal, time.Duration, error) {
+// The context carries the tracing span of the proposal, and aborts the request when the analyzer is closed
+func (b *APIClient) ProposeNewBlock(ctx context.Context, slot phase0.Slot, client string) (*api.VersionedProposal, time.Duration, error) {
 	log.Debugf("proposing new block: %d\n", slot)
 
 	skipRandaoVerification := false // only needed for Lighthouse, Nimbus and Grandine
@@ -30,11 +34,18 @@ func (b *APIClient) ProposeNewBlock(slot phase0.Slot, client string) (*api.Versi
 		SkipRandaoVerification: skipRandaoVerification,
 	}
 
+	// the span covers the request and the decode of the response
+	ctx, span := tracing.Tracer().Start(ctx, "Proposal", trace.WithAttributes(
+		tracing.SlotKey.Int64(int64(slot)),
+		tracing.ClientKey.String(client)))
+	defer span.End()
+
 	snapshot := time.Now()
-	block, err := b.Api.Proposal(b.ctx, &proposalOpts) // ask for block proposal
-	blockTime := time.Since(snapshot)                  // time to generate block
+	block, err := b.Api.Proposal(ctx, &proposalOpts) // ask for block proposal
+	blockTime := time.Since(snapshot)                // time to generate block
 
 	if err != nil {
+		span.RecordError(err)
 		return &api.VersionedProposal{}, 0 * time.Second, fmt.Errorf("error proposing block at slot %d: %s", slot, err)
 	}
 
@@ -2,6 +2,9 @@ package client_api
 
 import (
 	"context"
+	"fmt"
+	nethttp "net/http"
+	"strings"
 	"time"
 
 	"github.com/attestantio/go-eth2-client/http"
@@ -16,8 +19,10 @@ var (
 )
 
 type APIClient struct {
-	ctx context.Context
-	Api *http.Service
+	ctx      context.Context
+	Api      *http.Service
+	endpoint string          // base url used for the raw requests not covered by the Api
+	httpCli  *nethttp.Client // raw http client for the node endpoints
 }
 
 func NewAPIClient(ctx context.Context, label string, cliEndpoint string, timeout time.Duration) (*APIClient, error) {
@@ -36,9 +41,20 @@ func NewAPIClient(ctx context.Context, label string, cliEndpoint string, timeout
 	if !ok {
 		log.Error("gernerating the http api client")
 	}
+
+	// same address normalization as the Api
+	endpoint := cliEndpoint
+	if !strings.HasPrefix(endpoint, "http") {
+		endpoint = fmt.Sprintf("http://%s", endpoint)
+	}
+
 	return &APIClient{
-		ctx: ctx,
-		Api: hc,
+		ctx:      ctx,
+		Api:      hc,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		httpCli: &nethttp.Client{
+			Timeout: timeout,
+		},
 	}, nil
 }
 
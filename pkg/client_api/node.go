@@ -0,0 +1,155 @@
+package client_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"strconv"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/api"
+)
+
+const (
+	nodeSyncingPath   = "/eth/v1/node/syncing"
+	nodeHealthPath    = "/eth/v1/node/health"
+	nodePeerCountPath = "/eth/v1/node/peer_count"
+	nodeVersionPath   = "/eth/v1/node/version"
+)
+
+// NodeStatus gathers the health of the beacon node at a given time
+type NodeStatus struct {
+	Timestamp      time.Time // set by the caller, from its clock
+	HeadSlot       uint64
+	SyncDistance   uint64
+	IsSyncing      bool
+	IsOptimistic   bool
+	ElOffline      bool
+	HealthCode     int // http status code of the health endpoint (200 ready, 206 syncing, 503 not initialized)
+	PeersConnected uint64
+	Version        string
+}
+
+type nodeSyncingJSON struct {
+	Data struct {
+		HeadSlot     string `json:"head_slot"`
+		SyncDistance string `json:"sync_distance"`
+		IsSyncing    bool   `json:"is_syncing"`
+		IsOptimistic bool   `json:"is_optimistic"`
+		ElOffline    bool   `json:"el_offline"`
+	} `json:"data"`
+}
+
+type nodePeerCountJSON struct {
+	Data struct {
+		Connected string `json:"connected"`
+	} `json:"data"`
+}
+
+type nodeVersionJSON struct {
+	Data struct {
+		Version string `json:"version"`
+	} `json:"data"`
+}
+
+// NodeStatus polls the syncing, health, peer_count and version endpoints of the node
+// The Api caches some of these values (version) and does not expose el_offline, so they are requested directly
+func (s *APIClient) NodeStatus() (NodeStatus, error) {
+	status := NodeStatus{}
+
+	syncing := nodeSyncingJSON{}
+	if err := s.getJSON(s.ctx, nodeSyncingPath, &syncing); err != nil {
+		return status, fmt.Errorf("could not get node syncing status: %s", err)
+	}
+	headSlot, err := strconv.ParseUint(syncing.Data.HeadSlot, 10, 64)
+	if err != nil {
+		return status, fmt.Errorf("could not parse head slot %s: %s", syncing.Data.HeadSlot, err)
+	}
+	syncDistance, err := strconv.ParseUint(syncing.Data.SyncDistance, 10, 64)
+	if err != nil {
+		return status, fmt.Errorf("could not parse sync distance %s: %s", syncing.Data.SyncDistance, err)
+	}
+	status.HeadSlot = headSlot
+	status.SyncDistance = syncDistance
+	status.IsSyncing = syncing.Data.IsSyncing
+	status.IsOptimistic = syncing.Data.IsOptimistic
+	status.ElOffline = syncing.Data.ElOffline
+
+	healthCode, err := s.NodeHealth()
+	if err != nil {
+		return status, err
+	}
+	status.HealthCode = healthCode
+
+	peers := nodePeerCountJSON{}
+	if err := s.getJSON(s.ctx, nodePeerCountPath, &peers); err != nil {
+		return status, fmt.Errorf("could not get node peer count: %s", err)
+	}
+	connected, err := strconv.ParseUint(peers.Data.Connected, 10, 64)
+	if err != nil {
+		return status, fmt.Errorf("could not parse connected peers %s: %s", peers.Data.Connected, err)
+	}
+	status.PeersConnected = connected
+
+	version, err := s.NodeVersion()
+	if err != nil {
+		return status, err
+	}
+	status.Version = version
+
+	return status, nil
+}
+
+// NodeHealth returns the status code of the health endpoint, the body is empty
+func (s *APIClient) NodeHealth() (int, error) {
+	resp, err := s.get(s.ctx, nodeHealthPath)
+	if err != nil {
+		return 0, fmt.Errorf("could not get node health: %s", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	return resp.StatusCode, nil
+}
+
+// NodeVersion returns the version string reported by the node, without caching it
+func (s *APIClient) NodeVersion() (string, error) {
+	version := nodeVersionJSON{}
+	if err := s.getJSON(s.ctx, nodeVersionPath, &version); err != nil {
+		return "", fmt.Errorf("could not get node version: %s", err)
+	}
+	return version.Data.Version, nil
+}
+
+func (s *APIClient) get(ctx context.Context, path string) (*nethttp.Response, error) {
+	req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, s.endpoint+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	return s.httpCli.Do(req)
+}
+
+// getJSON decodes the response of the node, other status codes than 200 are returned as *api.Error, as the Api does
+func (s *APIClient) getJSON(ctx context.Context, path string, out interface{}) error {
+	resp, err := s.get(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != nethttp.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return &api.Error{
+			Method:     nethttp.MethodGet,
+			Endpoint:   path,
+			StatusCode: resp.StatusCode,
+			Data:       data,
+		}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
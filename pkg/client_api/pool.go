@@ -0,0 +1,100 @@
+package client_api
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"strings"
+
+	"github.com/attestantio/go-eth2-client/api"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+)
+
+const (
+	poolAttestationsPath   = "/eth/v1/beacon/pool/attestations"
+	poolAttestationsV2Path = "/eth/v2/beacon/pool/attestations"
+)
+
+type poolAttestationsJSON struct {
+	Version string            `json:"version"`
+	Data    []json.RawMessage `json:"data"`
+}
+
+// electra attestations carry the committee in the committee bits instead of the data index
+type poolCommitteeBitsJSON struct {
+	CommitteeBits string `json:"committee_bits"`
+}
+
+// AttestationPool returns the attestations in the pool of the node, of every slot
+// The v2 endpoint is requested first (needed from Electra on), nodes without it are asked the v1 one
+// Electra attestations are converted to the phase0 layout, those spanning several committees are skipped
+func (s *APIClient) AttestationPool(ctx context.Context) ([]*phase0.Attestation, error) {
+	pool := poolAttestationsJSON{}
+	err := s.getJSON(ctx, poolAttestationsV2Path, &pool)
+	if err != nil {
+		if !isStatusError(err, nethttp.StatusNotFound) && !isStatusError(err, nethttp.StatusBadRequest) {
+			return nil, fmt.Errorf("could not get attestation pool: %s", err)
+		}
+		pool = poolAttestationsJSON{}
+		if err := s.getJSON(ctx, poolAttestationsPath, &pool); err != nil {
+			return nil, fmt.Errorf("could not get attestation pool: %s", err)
+		}
+	}
+	return decodePoolAttestations(pool)
+}
+
+func decodePoolAttestations(pool poolAttestationsJSON) ([]*phase0.Attestation, error) {
+	attestations := make([]*phase0.Attestation, 0, len(pool.Data))
+	skipped := 0
+	for _, item := range pool.Data {
+		attestation := &phase0.Attestation{}
+		if err := json.Unmarshal(item, attestation); err != nil {
+			return nil, fmt.Errorf("could not decode pool attestation: %s", err)
+		}
+		if strings.EqualFold(pool.Version, "electra") {
+			committeeBits := poolCommitteeBitsJSON{}
+			if err := json.Unmarshal(item, &committeeBits); err != nil {
+				return nil, fmt.Errorf("could not decode committee bits: %s", err)
+			}
+			committees, err := setBits(committeeBits.CommitteeBits)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse committee bits %s: %s", committeeBits.CommitteeBits, err)
+			}
+			if len(committees) != 1 {
+				skipped++
+				continue
+			}
+			attestation.Data.Index = phase0.CommitteeIndex(committees[0])
+		}
+		attestations = append(attestations, attestation)
+	}
+	if skipped > 0 {
+		log.Debugf("skipped %d pool attestations of several committees", skipped)
+	}
+	return attestations, nil
+}
+
+// setBits returns the positions set in a hex encoded bitvector
+func setBits(input string) ([]uint64, error) {
+	content, err := hex.DecodeString(strings.TrimPrefix(input, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	bits := make([]uint64, 0)
+	for i, b := range content {
+		for j := 0; j < 8; j++ {
+			if b&(1<<j) != 0 {
+				bits = append(bits, uint64(i*8+j))
+			}
+		}
+	}
+	return bits, nil
+}
+
+func isStatusError(err error, statusCode int) bool {
+	var apiErr *api.Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == statusCode
+}
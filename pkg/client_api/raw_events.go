@@ -0,0 +1,93 @@
+package client_api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"strings"
+	"time"
+)
+
+var (
+	RawEventsReconnect = 2 * time.Second
+	maxEventSize       = 16 * 1024 * 1024
+)
+
+// RawEventHandler receives the topic and the json data of each event
+type RawEventHandler func(topic string, data []byte)
+
+// RawEvents subscribes to topics the Api can not decode, the stream is reopened until the context is done
+func (s *APIClient) RawEvents(ctx context.Context, topics []string, handler RawEventHandler) {
+	go func() {
+		for {
+			err := s.streamEvents(ctx, topics, handler)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warnf("event stream %s of %s closed, reconnecting: %s", strings.Join(topics, ","), s.endpoint, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(RawEventsReconnect):
+			}
+		}
+	}()
+}
+
+func (s *APIClient) streamEvents(ctx context.Context, topics []string, handler RawEventHandler) error {
+	url := fmt.Sprintf("%s/eth/v1/events?topics=%s", s.endpoint, strings.Join(topics, "&topics="))
+	req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	// the stream is long lived, no timeout
+	resp, err := (&nethttp.Client{}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != nethttp.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return readEvents(resp.Body, handler)
+}
+
+// readEvents parses a server-sent events stream, until it ends
+func readEvents(r io.Reader, handler RawEventHandler) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxEventSize)
+
+	topic := ""
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// end of the event
+			if topic != "" && data.Len() > 0 {
+				handler(topic, append([]byte(nil), data.Bytes()...))
+			}
+			topic = ""
+			data.Reset()
+		case strings.HasPrefix(line, ":"):
+			// comment, used as keepalive
+		case strings.HasPrefix(line, "event:"):
+			topic = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
@@ -0,0 +1,33 @@
+package client_api
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadEvents(t *testing.T) {
+	stream := ": keepalive\n\n" +
+		"event: block_gossip\ndata: {\"slot\":\"10\",\"block\":\"0x01\"}\n\n" +
+		"event: bls_to_execution_change\ndata: {\"message\":\ndata: {}}\n\n" +
+		"event: block_gossip\n"
+
+	topics := make([]string, 0)
+	data := make([]string, 0)
+	err := readEvents(strings.NewReader(stream), func(topic string, content []byte) {
+		topics = append(topics, topic)
+		data = append(data, string(content))
+	})
+	if err != io.EOF {
+		t.Fatalf("expected EOF at the end of the stream, got %v", err)
+	}
+	if len(topics) != 2 {
+		t.Fatalf("expected 2 events, got %d: %v", len(topics), topics)
+	}
+	if topics[0] != "block_gossip" || data[0] != `{"slot":"10","block":"0x01"}` {
+		t.Errorf("unexpected first event %s: %s", topics[0], data[0])
+	}
+	if data[1] != "{\"message\":\n{}}" {
+		t.Errorf("multi line data not joined: %q", data[1])
+	}
+}
@@ -0,0 +1,49 @@
+package clock
+
+/*
+
+This package abstracts the time source, so the slot timing can be driven by a fake clock
+in the tests and by the recorded times in the replays
+
+*/
+
+import (
+	"time"
+)
+
+// Clock is the source of the current time and of the timers
+type Clock interface {
+	Now() time.Time
+	// After sends the time once d has elapsed
+	After(d time.Duration) <-chan time.Time
+	// NewTicker sends the time every d, dropping ticks for slow receivers like time.Ticker
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is a periodic timer of a Clock
+type Ticker interface {
+	Chan() <-chan time.Time
+	Stop()
+}
+
+// Real is the system clock
+type Real struct{}
+
+func (Real) Now() time.Time                         { return time.Now() }
+func (Real) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (Real) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t realTicker) Chan() <-chan time.Time { return t.ticker.C }
+func (t realTicker) Stop()                  { t.ticker.Stop() }
+
+// OrReal returns the clock, or the system one if nil
+func OrReal(c Clock) Clock {
+	if c == nil {
+		return Real{}
+	}
+	return c
+}
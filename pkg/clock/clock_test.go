@@ -0,0 +1,115 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+const testSlot = 12 * time.Second
+
+var testGenesis = time.Unix(1606824023, 0)
+
+func expectTick(t *testing.T, ticker *PeriodTicker, period uint64, skipped uint64) {
+	t.Helper()
+	select {
+	case tick := <-ticker.C:
+		if tick.Period != period || tick.Skipped != skipped {
+			t.Fatalf("expected period %d (%d skipped), got %+v", period, skipped, tick)
+		}
+		if !tick.Start.Equal(testGenesis.Add(time.Duration(period) * testSlot)) {
+			t.Errorf("unexpected start of period %d: %s", period, tick.Start)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected period %d", period)
+	}
+}
+
+func expectNoTick(t *testing.T, ticker *PeriodTicker) {
+	t.Helper()
+	select {
+	case tick := <-ticker.C:
+		t.Fatalf("unexpected tick %+v", tick)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPeriodTickerSlotBoundary(t *testing.T) {
+	fake := NewFake(testGenesis.Add(100*testSlot + 5*time.Second))
+	ticker := NewPeriodTicker(fake, testGenesis, testSlot)
+	defer ticker.Stop()
+
+	// nothing until the next slot starts
+	fake.BlockUntil(1)
+	fake.Advance(7*time.Second - time.Nanosecond)
+	expectNoTick(t, ticker)
+
+	fake.Advance(time.Nanosecond)
+	expectTick(t, ticker, 101, 0)
+
+	fake.BlockUntil(1)
+	fake.Advance(testSlot)
+	expectTick(t, ticker, 102, 0)
+}
+
+func TestPeriodTickerSkippedTick(t *testing.T) {
+	fake := NewFake(testGenesis.Add(100 * testSlot))
+	ticker := NewPeriodTicker(fake, testGenesis, testSlot)
+	defer ticker.Stop()
+
+	// the clock moves three slots at once: only the current one is announced
+	fake.BlockUntil(1)
+	fake.Advance(3*testSlot + time.Second)
+	expectTick(t, ticker, 103, 2)
+	expectNoTick(t, ticker)
+
+	fake.BlockUntil(1)
+	fake.Advance(testSlot)
+	expectTick(t, ticker, 104, 0)
+}
+
+func TestPeriodTickerClockJump(t *testing.T) {
+	fake := NewFake(testGenesis.Add(100 * testSlot))
+	ticker := NewPeriodTicker(fake, testGenesis, testSlot)
+	defer ticker.Stop()
+
+	fake.BlockUntil(1)
+	fake.Advance(testSlot)
+	expectTick(t, ticker, 101, 0)
+
+	// the clock goes back two slots: the slots already announced are not announced again
+	fake.BlockUntil(1)
+	fake.Set(testGenesis.Add(99 * testSlot))
+	fake.BlockUntil(1)
+	fake.Advance(2 * testSlot)
+	expectNoTick(t, ticker)
+
+	fake.BlockUntil(1)
+	fake.Advance(testSlot)
+	expectTick(t, ticker, 102, 0)
+}
+
+func TestFakeTicker(t *testing.T) {
+	fake := NewFake(testGenesis)
+	ticker := fake.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	fake.Advance(time.Second)
+	if tick := <-ticker.Chan(); !tick.Equal(testGenesis.Add(time.Second)) {
+		t.Errorf("unexpected tick %s", tick)
+	}
+	// the ticks missed by a slow receiver are dropped
+	fake.Advance(5 * time.Second)
+	<-ticker.Chan()
+	select {
+	case tick := <-ticker.Chan():
+		t.Errorf("unexpected tick %s", tick)
+	default:
+	}
+
+	after := fake.After(time.Second)
+	fake.Advance(time.Second)
+	<-after
+	if fake.Waiters() != 1 {
+		t.Errorf("expected only the ticker to be pending, got %d timers", fake.Waiters())
+	}
+}
@@ -0,0 +1,134 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a clock that only moves when told to, its timers fire as the time reaches them
+type Fake struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	deadline time.Time
+	period   time.Duration // 0 for the After timers
+	c        chan time.Time
+}
+
+// NewFake returns a fake clock set at the given time
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.addWaiter(d, 0).c
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	return &fakeTicker{clock: f, waiter: f.addWaiter(d, d)}
+}
+
+func (f *Fake) addWaiter(d time.Duration, period time.Duration) *fakeWaiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := &fakeWaiter{
+		deadline: f.now.Add(d),
+		period:   period,
+		c:        make(chan time.Time, 1),
+	}
+	f.waiters = append(f.waiters, w)
+	f.fire()
+	f.cond.Broadcast()
+	return w
+}
+
+// Advance moves the clock forward, firing the timers that are reached
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	f.fire()
+}
+
+// Set moves the clock to the given time, which can be in the past
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = t
+	f.fire()
+}
+
+// Waiters returns how many timers are pending
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// BlockUntil waits until there are at least n pending timers,
+// so the tests know the goroutine under test is waiting before moving the clock
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
+
+// fire sends the time to the reached timers, must be called with the lock held
+func (f *Fake) fire() {
+	pending := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.deadline.After(f.now) {
+			pending = append(pending, w)
+			continue
+		}
+		select {
+		case w.c <- f.now:
+		default: // the receiver did not take the previous tick
+		}
+		if w.period == 0 {
+			continue
+		}
+		// the missed ticks are dropped
+		for !w.deadline.After(f.now) {
+			w.deadline = w.deadline.Add(w.period)
+		}
+		pending = append(pending, w)
+	}
+	f.waiters = pending
+}
+
+func (f *Fake) removeWaiter(waiter *fakeWaiter) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, w := range f.waiters {
+		if w == waiter {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock  *Fake
+	waiter *fakeWaiter
+}
+
+func (t *fakeTicker) Chan() <-chan time.Time { return t.waiter.c }
+func (t *fakeTicker) Stop()                  { t.clock.removeWaiter(t.waiter) }
@@ -0,0 +1,79 @@
+package clock
+
+import (
+	"time"
+)
+
+// PeriodTick announces the start of a period (slot or epoch)
+type PeriodTick struct {
+	Period  uint64 // number of the period since genesis
+	Start   time.Time
+	Skipped uint64 // periods that started since the previous tick without being announced
+}
+
+// PeriodTicker announces the start of each period since genesis,
+// it is built on After so it follows any Clock
+// If the clock jumps forward (or the receiver is late) the current period is announced once
+// with the skipped ones counted, if it jumps backward no period is announced twice
+type PeriodTicker struct {
+	C    <-chan PeriodTick
+	done chan struct{}
+}
+
+// NewPeriodTicker starts announcing the periods that start after now
+func NewPeriodTicker(c Clock, genesis time.Time, period time.Duration) *PeriodTicker {
+	ticks := make(chan PeriodTick)
+	t := &PeriodTicker{
+		C:    ticks,
+		done: make(chan struct{}),
+	}
+	go t.run(c, genesis, period, ticks)
+	return t
+}
+
+// Stop ends the ticker, no more periods are announced
+func (t *PeriodTicker) Stop() {
+	close(t.done)
+}
+
+func (t *PeriodTicker) run(c Clock, genesis time.Time, period time.Duration, ticks chan<- PeriodTick) {
+	// the next period to announce
+	next := uint64(0)
+	if current, started := periodAt(c.Now(), genesis, period); started {
+		next = current + 1
+	}
+	for {
+		start := genesis.Add(time.Duration(next) * period)
+		select {
+		case <-t.done:
+			return
+		case <-c.After(start.Sub(c.Now())):
+		}
+
+		current, started := periodAt(c.Now(), genesis, period)
+		if !started || current < next {
+			// the clock went back, wait again for the start
+			continue
+		}
+		tick := PeriodTick{
+			Period:  current,
+			Start:   genesis.Add(time.Duration(current) * period),
+			Skipped: current - next,
+		}
+		select {
+		case <-t.done:
+			return
+		case ticks <- tick:
+		}
+		next = current + 1
+	}
+}
+
+// periodAt returns the period at the given time, false before genesis
+func periodAt(now time.Time, genesis time.Time, period time.Duration) (uint64, bool) {
+	elapsed := now.Sub(genesis)
+	if elapsed < 0 {
+		return 0, false
+	}
+	return uint64(elapsed / period), true
+}
@@ -1,31 +1,123 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+
 	cli "github.com/urfave/cli/v2"
 )
 
 type StreamethConfig struct {
-	LogLevel       string `json:"log-level"`
-	BnEndpoints    string `json:"bn-endpoints"`
-	DBEndpoint     string `json:"db-endpoint"`
-	DbWorkers      int    `json:"db-workers"`
-	Metrics        string `json:"metrics"`
-	BlocksDir      string `json:"blocks-dir"`
-	PrometheusPort int    `json:"prometheus-port"`
+	LogLevel        string  `json:"log-level"`
+	BnEndpoints     string  `json:"bn-endpoints"`
+	DBEndpoint      string  `json:"db-endpoint"`
+	DbWorkers       int     `json:"db-workers"`
+	Metrics         string  `json:"metrics"`
+	BlocksDir       string  `json:"blocks-dir"`
+	PrometheusPort  int     `json:"prometheus-port"`
+	MetricsAddress  string  `json:"metrics-address"`
+	MetricsPath     string  `json:"metrics-path"`
+	PushgatewayUrl  string  `json:"pushgateway-url"`
+	PushgatewayJob  string  `json:"pushgateway-job"`
+	OtlpMetrics     string  `json:"otlp-metrics-endpoint"`
+	MetricsPush     string  `json:"metrics-push-interval"`
+	AdminPort       int     `json:"admin-port"`
+	AdminAddress    string  `json:"admin-address"`
+	HistoryDir      string  `json:"history-dir"`
+	HistoryWorkers  int     `json:"history-workers"`
+	ProposalOffsets string  `json:"proposal-offsets"`
+	Tracing         string  `json:"tracing"`
+	TracingEndpoint string  `json:"tracing-endpoint"`
+	TracingFile     string  `json:"tracing-file"`
+	TracingSampling float64 `json:"tracing-sampling"`
+	PartitionSlots  uint64  `json:"partition-slots"`
+	RetentionSlots  uint64  `json:"retention-slots"`
+	RetentionMode   string  `json:"retention-mode"`
+	Downsample      bool    `json:"retention-downsample"`
+	DBOverflow      string  `json:"db-overflow"`
+	DBQueueSize     int     `json:"db-queue-size"`
+	DBSpillDir      string  `json:"db-spill-dir"`
+	DBDeadLetter    string  `json:"db-deadletter-file"`
+	ReportDir       string  `json:"report-dir"`
+	ReportEpochs    uint64  `json:"report-epochs"`
+	ReportFormat    string  `json:"report-format"`
+	FinalityLag     uint64  `json:"finality-lag-epochs"`
+	HeadDivergence  uint64  `json:"head-divergence-slots"`
+	Watchlist       string  `json:"watchlist"`
+	PoolSnapshot    bool    `json:"pool-snapshot"`
+	ProposalDiff    string  `json:"proposal-diff"`
+	RecordDir       string  `json:"record-dir"`
+	NtpServers      string  `json:"ntp-servers"`
+	NtpInterval     string  `json:"ntp-interval"`
+	ClockCorrection bool    `json:"clock-correction"`
+	ArrivalFrom     string  `json:"attestation-delay-from"`
+	ConfigFile      string  `json:"-"`
 }
 
 // TODO: read from config-file
 func NewStreamethConfig() *StreamethConfig {
 	// Return Default values for the ethereum configuration
 	return &StreamethConfig{
-		LogLevel:       DefaultLogLevel,
-		BnEndpoints:    DefaultBnEndpoints,
-		DBEndpoint:     DefaultDBEndpoint,
-		DbWorkers:      DefaultDbWorkers,
-		Metrics:        DefaultMetrics,
-		BlocksDir:      DefaultBlocksDir,
-		PrometheusPort: DefaultPrometheusPort,
+		LogLevel:        DefaultLogLevel,
+		BnEndpoints:     DefaultBnEndpoints,
+		DBEndpoint:      DefaultDBEndpoint,
+		DbWorkers:       DefaultDbWorkers,
+		Metrics:         DefaultMetrics,
+		BlocksDir:       DefaultBlocksDir,
+		PrometheusPort:  DefaultPrometheusPort,
+		MetricsAddress:  DefaultMetricsAddress,
+		MetricsPath:     DefaultMetricsPath,
+		PushgatewayUrl:  DefaultPushgatewayUrl,
+		PushgatewayJob:  DefaultPushgatewayJob,
+		OtlpMetrics:     DefaultOtlpMetrics,
+		MetricsPush:     DefaultMetricsPush,
+		AdminPort:       DefaultAdminPort,
+		AdminAddress:    DefaultAdminAddress,
+		HistoryDir:      DefaultHistoryDir,
+		HistoryWorkers:  DefaultHistoryWorkers,
+		ProposalOffsets: DefaultProposalOffsets,
+		Tracing:         DefaultTracing,
+		TracingEndpoint: DefaultTracingEndpoint,
+		TracingFile:     DefaultTracingFile,
+		TracingSampling: DefaultTracingSampling,
+		PartitionSlots:  DefaultPartitionSlots,
+		RetentionSlots:  DefaultRetentionSlots,
+		RetentionMode:   DefaultRetentionMode,
+		Downsample:      DefaultDownsample,
+		DBOverflow:      DefaultDBOverflow,
+		DBQueueSize:     DefaultDBQueueSize,
+		DBSpillDir:      DefaultDBSpillDir,
+		DBDeadLetter:    DefaultDBDeadLetter,
+		ReportDir:       DefaultReportDir,
+		ReportEpochs:    DefaultReportEpochs,
+		ReportFormat:    DefaultReportFormat,
+		FinalityLag:     DefaultFinalityLag,
+		HeadDivergence:  DefaultHeadDivergence,
+		Watchlist:       DefaultWatchlist,
+		PoolSnapshot:    DefaultPoolSnapshot,
+		ProposalDiff:    DefaultProposalDiff,
+		RecordDir:       DefaultRecordDir,
+		NtpServers:      DefaultNtpServers,
+		NtpInterval:     DefaultNtpInterval,
+		ClockCorrection: DefaultClockCorrection,
+		ArrivalFrom:     DefaultArrivalFrom,
+		ConfigFile:      DefaultConfigFile,
+	}
+}
+
+// ReadFile applies the values of the given json config file, keys are named as the flags
+func (c *StreamethConfig) ReadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file %s: %s", path, err)
+	}
+	err = json.Unmarshal(content, c)
+	if err != nil {
+		return fmt.Errorf("could not parse config file %s: %s", path, err)
 	}
+	c.ConfigFile = path
+	return nil
 }
 
 func (c *StreamethConfig) Apply(ctx *cli.Context) {
@@ -58,4 +150,128 @@ func (c *StreamethConfig) Apply(ctx *cli.Context) {
 	if ctx.IsSet("prometheus-port") {
 		c.PrometheusPort = ctx.Int("prometheus-port")
 	}
+	if ctx.IsSet("metrics-address") {
+		c.MetricsAddress = ctx.String("metrics-address")
+	}
+	if ctx.IsSet("metrics-path") {
+		c.MetricsPath = ctx.String("metrics-path")
+	}
+	// metrics push
+	if ctx.IsSet("pushgateway-url") {
+		c.PushgatewayUrl = ctx.String("pushgateway-url")
+	}
+	if ctx.IsSet("pushgateway-job") {
+		c.PushgatewayJob = ctx.String("pushgateway-job")
+	}
+	if ctx.IsSet("otlp-metrics-endpoint") {
+		c.OtlpMetrics = ctx.String("otlp-metrics-endpoint")
+	}
+	if ctx.IsSet("metrics-push-interval") {
+		c.MetricsPush = ctx.String("metrics-push-interval")
+	}
+	// admin port
+	if ctx.IsSet("admin-port") {
+		c.AdminPort = ctx.Int("admin-port")
+	}
+	if ctx.IsSet("admin-address") {
+		c.AdminAddress = ctx.String("admin-address")
+	}
+	// history snapshots
+	if ctx.IsSet("history-dir") {
+		c.HistoryDir = ctx.String("history-dir")
+	}
+	if ctx.IsSet("history-workers") {
+		c.HistoryWorkers = ctx.Int("history-workers")
+	}
+	// proposal offsets
+	if ctx.IsSet("proposal-offsets") {
+		c.ProposalOffsets = ctx.String("proposal-offsets")
+	}
+	// tracing
+	if ctx.IsSet("tracing") {
+		c.Tracing = ctx.String("tracing")
+	}
+	if ctx.IsSet("tracing-endpoint") {
+		c.TracingEndpoint = ctx.String("tracing-endpoint")
+	}
+	if ctx.IsSet("tracing-file") {
+		c.TracingFile = ctx.String("tracing-file")
+	}
+	if ctx.IsSet("tracing-sampling") {
+		c.TracingSampling = ctx.Float64("tracing-sampling")
+	}
+	// retention
+	if ctx.IsSet("partition-slots") {
+		c.PartitionSlots = ctx.Uint64("partition-slots")
+	}
+	if ctx.IsSet("retention-slots") {
+		c.RetentionSlots = ctx.Uint64("retention-slots")
+	}
+	if ctx.IsSet("retention-mode") {
+		c.RetentionMode = ctx.String("retention-mode")
+	}
+	if ctx.IsSet("retention-downsample") {
+		c.Downsample = ctx.Bool("retention-downsample")
+	}
+	// db write queues
+	if ctx.IsSet("db-overflow") {
+		c.DBOverflow = ctx.String("db-overflow")
+	}
+	if ctx.IsSet("db-queue-size") {
+		c.DBQueueSize = ctx.Int("db-queue-size")
+	}
+	if ctx.IsSet("db-spill-dir") {
+		c.DBSpillDir = ctx.String("db-spill-dir")
+	}
+	if ctx.IsSet("db-deadletter-file") {
+		c.DBDeadLetter = ctx.String("db-deadletter-file")
+	}
+	// reports
+	if ctx.IsSet("report-dir") {
+		c.ReportDir = ctx.String("report-dir")
+	}
+	if ctx.IsSet("report-epochs") {
+		c.ReportEpochs = ctx.Uint64("report-epochs")
+	}
+	if ctx.IsSet("report-format") {
+		c.ReportFormat = ctx.String("report-format")
+	}
+	// finality
+	if ctx.IsSet("finality-lag-epochs") {
+		c.FinalityLag = ctx.Uint64("finality-lag-epochs")
+	}
+	// heads
+	if ctx.IsSet("head-divergence-slots") {
+		c.HeadDivergence = ctx.Uint64("head-divergence-slots")
+	}
+	// validators to monitor
+	if ctx.IsSet("watchlist") {
+		c.Watchlist = ctx.String("watchlist")
+	}
+	// attestation pool with the proposals
+	if ctx.IsSet("pool-snapshot") {
+		c.PoolSnapshot = ctx.Bool("pool-snapshot")
+	}
+	// comparison of the proposals of each slot
+	if ctx.IsSet("proposal-diff") {
+		c.ProposalDiff = ctx.String("proposal-diff")
+	}
+	// recordings of the node streams
+	if ctx.IsSet("record-dir") {
+		c.RecordDir = ctx.String("record-dir")
+	}
+	// clock offset
+	if ctx.IsSet("ntp-servers") {
+		c.NtpServers = ctx.String("ntp-servers")
+	}
+	if ctx.IsSet("ntp-interval") {
+		c.NtpInterval = ctx.String("ntp-interval")
+	}
+	if ctx.IsSet("clock-correction") {
+		c.ClockCorrection = ctx.Bool("clock-correction")
+	}
+	// attestation arrival histograms
+	if ctx.IsSet("attestation-delay-from") {
+		c.ArrivalFrom = ctx.String("attestation-delay-from")
+	}
 }
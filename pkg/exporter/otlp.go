@@ -0,0 +1,203 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+var OTLPTimeout = 10 * time.Second
+
+// OTLPPusher converts the registered Prometheus metrics to OTLP and sends them to a collector over HTTP
+// Counters become cumulative sums, histograms keep their buckets, and untyped metrics become gauges
+type OTLPPusher struct {
+	endpoint  string // full url, usually ending in /v1/metrics
+	gatherer  prometheus.Gatherer
+	resource  *resourcepb.Resource
+	startTime time.Time // start of the cumulative series
+	client    *http.Client
+}
+
+func NewOTLPPusher(endpoint string, serviceName string, serviceVersion string, instance string, gatherer prometheus.Gatherer) *OTLPPusher {
+	attributes := []*commonpb.KeyValue{
+		stringAttribute("service.name", serviceName),
+		stringAttribute("service.version", serviceVersion),
+	}
+	if instance != "" {
+		attributes = append(attributes, stringAttribute("service.instance.id", instance))
+	}
+	return &OTLPPusher{
+		endpoint:  endpoint,
+		gatherer:  gatherer,
+		resource:  &resourcepb.Resource{Attributes: attributes},
+		startTime: time.Now(),
+		client:    &http.Client{Timeout: OTLPTimeout},
+	}
+}
+
+func (p *OTLPPusher) Name() string {
+	return fmt.Sprintf("otlp %s", p.endpoint)
+}
+
+func (p *OTLPPusher) Push(ctx context.Context) error {
+	families, err := p.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("could not gather metrics: %s", err)
+	}
+	request := &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: p.resource,
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope:   &commonpb.InstrumentationScope{Name: "streameth"},
+				Metrics: OTLPMetrics(families, p.startTime, time.Now()),
+			}},
+		}},
+	}
+	body, err := proto.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("could not encode metrics: %s", err)
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpRequest.Header.Set("Content-Type", "application/x-protobuf")
+	response, err := p.client.Do(httpRequest)
+	if err != nil {
+		return fmt.Errorf("could not send metrics: %s", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("collector answered %d: %s", response.StatusCode, message)
+	}
+	return nil
+}
+
+// OTLPMetrics converts the gathered metric families to OTLP metrics
+func OTLPMetrics(families []*dto.MetricFamily, startTime time.Time, now time.Time) []*metricspb.Metric {
+	start, timestamp := uint64(startTime.UnixNano()), uint64(now.UnixNano())
+	metrics := make([]*metricspb.Metric, 0, len(families))
+	for _, family := range families {
+		metric := &metricspb.Metric{
+			Name:        family.GetName(),
+			Description: family.GetHelp(),
+		}
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			points := make([]*metricspb.NumberDataPoint, 0, len(family.Metric))
+			for _, item := range family.Metric {
+				points = append(points, numberPoint(item, item.GetCounter().GetValue(), start, timestamp))
+			}
+			metric.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+				DataPoints:             points,
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				IsMonotonic:            true,
+			}}
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			points := make([]*metricspb.NumberDataPoint, 0, len(family.Metric))
+			for _, item := range family.Metric {
+				value := item.GetGauge().GetValue()
+				if family.GetType() == dto.MetricType_UNTYPED {
+					value = item.GetUntyped().GetValue()
+				}
+				points = append(points, numberPoint(item, value, 0, timestamp))
+			}
+			metric.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: points}}
+		case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+			points := make([]*metricspb.HistogramDataPoint, 0, len(family.Metric))
+			for _, item := range family.Metric {
+				points = append(points, histogramPoint(item, start, timestamp))
+			}
+			metric.Data = &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+				DataPoints:             points,
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			}}
+		case dto.MetricType_SUMMARY:
+			points := make([]*metricspb.SummaryDataPoint, 0, len(family.Metric))
+			for _, item := range family.Metric {
+				summary := item.GetSummary()
+				point := &metricspb.SummaryDataPoint{
+					Attributes:        attributes(item),
+					StartTimeUnixNano: start,
+					TimeUnixNano:      timestamp,
+					Count:             summary.GetSampleCount(),
+					Sum:               summary.GetSampleSum(),
+				}
+				for _, quantile := range summary.GetQuantile() {
+					point.QuantileValues = append(point.QuantileValues, &metricspb.SummaryDataPoint_ValueAtQuantile{
+						Quantile: quantile.GetQuantile(),
+						Value:    quantile.GetValue(),
+					})
+				}
+				points = append(points, point)
+			}
+			metric.Data = &metricspb.Metric_Summary{Summary: &metricspb.Summary{DataPoints: points}}
+		default:
+			continue
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics
+}
+
+func numberPoint(item *dto.Metric, value float64, start uint64, timestamp uint64) *metricspb.NumberDataPoint {
+	return &metricspb.NumberDataPoint{
+		Attributes:        attributes(item),
+		StartTimeUnixNano: start,
+		TimeUnixNano:      timestamp,
+		Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+	}
+}
+
+// Prometheus buckets are cumulative, OTLP ones are not and end with the +Inf bucket
+func histogramPoint(item *dto.Metric, start uint64, timestamp uint64) *metricspb.HistogramDataPoint {
+	histogram := item.GetHistogram()
+	sum := histogram.GetSampleSum()
+	point := &metricspb.HistogramDataPoint{
+		Attributes:        attributes(item),
+		StartTimeUnixNano: start,
+		TimeUnixNano:      timestamp,
+		Count:             histogram.GetSampleCount(),
+		Sum:               &sum,
+	}
+	previous := uint64(0)
+	for _, bucket := range histogram.GetBucket() {
+		if math.IsInf(bucket.GetUpperBound(), 1) {
+			continue
+		}
+		point.ExplicitBounds = append(point.ExplicitBounds, bucket.GetUpperBound())
+		point.BucketCounts = append(point.BucketCounts, bucket.GetCumulativeCount()-previous)
+		previous = bucket.GetCumulativeCount()
+	}
+	point.BucketCounts = append(point.BucketCounts, histogram.GetSampleCount()-previous)
+	return point
+}
+
+func attributes(item *dto.Metric) []*commonpb.KeyValue {
+	result := make([]*commonpb.KeyValue, 0, len(item.GetLabel()))
+	for _, label := range item.GetLabel() {
+		result = append(result, stringAttribute(label.GetName(), label.GetValue()))
+	}
+	return result
+}
+
+func stringAttribute(key string, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
@@ -0,0 +1,40 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// Pusher sends the registered metrics to a collector, for the deployments that can not be scraped
+type Pusher interface {
+	Name() string
+	Push(ctx context.Context) error
+}
+
+// PushgatewayPusher pushes the metrics to a Prometheus Pushgateway, replacing those of the same job and instance
+type PushgatewayPusher struct {
+	pusher *push.Pusher
+	url    string
+}
+
+func NewPushgatewayPusher(url string, job string, instance string, gatherer prometheus.Gatherer) *PushgatewayPusher {
+	pusher := push.New(url, job).Gatherer(gatherer)
+	if instance != "" {
+		pusher = pusher.Grouping("instance", instance)
+	}
+	return &PushgatewayPusher{
+		pusher: pusher,
+		url:    url,
+	}
+}
+
+func (p *PushgatewayPusher) Name() string {
+	return fmt.Sprintf("pushgateway %s", p.url)
+}
+
+func (p *PushgatewayPusher) Push(ctx context.Context) error {
+	return p.pusher.PushContext(ctx)
+}
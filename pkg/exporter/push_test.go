@@ -0,0 +1,99 @@
+package exporter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func testRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_gauge", Help: "gauge"}, []string{"label"})
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_counter", Help: "counter"})
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_histogram", Help: "histogram", Buckets: []float64{1, 2}})
+	registry.MustRegister(gauge, counter, histogram)
+
+	gauge.WithLabelValues("a").Set(3)
+	counter.Add(5)
+	for _, value := range []float64{0.5, 1.5, 1.5, 10} {
+		histogram.Observe(value)
+	}
+	return registry
+}
+
+func TestOTLPPusher(t *testing.T) {
+	requests := make(chan *colmetricspb.ExportMetricsServiceRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		request := &colmetricspb.ExportMetricsServiceRequest{}
+		if r.URL.Path != "/v1/metrics" || r.Header.Get("Content-Type") != "application/x-protobuf" || proto.Unmarshal(body, request) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		requests <- request
+	}))
+	defer server.Close()
+
+	pusher := NewOTLPPusher(server.URL+"/v1/metrics", "streameth", "test", "host", testRegistry())
+	if err := pusher.Push(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	request := <-requests
+	metrics := make(map[string]*metricspb.Metric)
+	for _, metric := range request.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		metrics[metric.Name] = metric
+	}
+
+	gauge := metrics["test_gauge"].GetGauge().GetDataPoints()
+	if len(gauge) != 1 || gauge[0].GetAsDouble() != 3 || gauge[0].Attributes[0].Value.GetStringValue() != "a" {
+		t.Errorf("unexpected gauge %v", gauge)
+	}
+	counter := metrics["test_counter"].GetSum()
+	if !counter.IsMonotonic || counter.DataPoints[0].GetAsDouble() != 5 {
+		t.Errorf("unexpected counter %v", counter)
+	}
+	histogram := metrics["test_histogram"].GetHistogram().GetDataPoints()[0]
+	expected := []uint64{1, 2, 1} // (..1], (1..2], (2..+Inf)
+	if histogram.Count != 4 || len(histogram.BucketCounts) != 3 || len(histogram.ExplicitBounds) != 2 {
+		t.Fatalf("unexpected histogram %v", histogram)
+	}
+	for i, count := range expected {
+		if histogram.BucketCounts[i] != count {
+			t.Errorf("expected %d in bucket %d, got %d", count, i, histogram.BucketCounts[i])
+		}
+	}
+}
+
+func TestPushgatewayPusher(t *testing.T) {
+	paths := make(chan string, 1)
+	bodies := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		paths <- r.URL.Path
+		bodies <- string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	pusher := NewPushgatewayPusher(server.URL, "streameth", "host", testRegistry())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := pusher.Push(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if path := <-paths; path != "/metrics/job/streameth/instance/host" {
+		t.Errorf("unexpected grouping path %s", path)
+	}
+	if body := <-bodies; !strings.Contains(body, "test_counter") {
+		t.Errorf("the metrics were not pushed")
+	}
+}
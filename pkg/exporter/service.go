@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,34 +15,36 @@ import (
 )
 
 var (
-	// TODO: Just hardcoded, move to config
-	EndpointUrl string = "metrics"
-
 	MetricLoopInterval time.Duration = 15 * time.Second
+	PushTimeout        time.Duration = 10 * time.Second
 )
 
 type PrometheusMetrics struct {
 	ctx context.Context
 
 	ExposedIp       string
-	ExposedPort     string
-	EndpointUrl     string
+	ExposedPort     string // 0 to not serve the metrics, when they are only pushed
+	EndpointUrl     string // path of the metrics endpoint
 	RefreshInterval time.Duration
+	PushInterval    time.Duration
 
 	Modules []*MetricsModule
+	Pushers []Pusher
 
 	wg     sync.WaitGroup
 	closeC chan struct{}
 }
 
-func NewPrometheusMetrics(ctx context.Context, ip string, port int) *PrometheusMetrics {
+func NewPrometheusMetrics(ctx context.Context, ip string, port int, path string) *PrometheusMetrics {
 	return &PrometheusMetrics{
 		ctx:             ctx,
 		ExposedIp:       ip,
 		ExposedPort:     fmt.Sprintf("%d", port),
-		EndpointUrl:     EndpointUrl,
+		EndpointUrl:     "/" + strings.TrimPrefix(path, "/"),
 		RefreshInterval: MetricLoopInterval,
+		PushInterval:    MetricLoopInterval,
 		Modules:         make([]*MetricsModule, 0),
+		Pushers:         make([]Pusher, 0),
 		closeC:          make(chan struct{}),
 	}
 }
@@ -50,12 +53,19 @@ func (p *PrometheusMetrics) AddMetricsModule(newMod *MetricsModule) {
 	p.Modules = append(p.Modules, newMod)
 }
 
+// AddPusher sends the metrics to the pusher every PushInterval
+func (p *PrometheusMetrics) AddPusher(pusher Pusher) {
+	p.Pushers = append(p.Pushers, pusher)
+}
+
 func (p *PrometheusMetrics) Start() error {
-	http.Handle("/"+p.EndpointUrl, promhttp.Handler())
-	go func() {
-		log.Fatal(http.ListenAndServe(fmt.Sprintf("%s:%s", p.ExposedIp, p.ExposedPort), nil))
-	}()
-	log.Infof("prometheus metrics listening on: %s:%s", p.ExposedIp, p.ExposedPort)
+	if p.ExposedPort != "0" {
+		http.Handle(p.EndpointUrl, promhttp.Handler())
+		go func() {
+			log.Fatal(http.ListenAndServe(fmt.Sprintf("%s:%s", p.ExposedIp, p.ExposedPort), nil))
+		}()
+		log.Infof("prometheus metrics listening on: %s:%s%s", p.ExposedIp, p.ExposedPort, p.EndpointUrl)
+	}
 	err := p.initPrometheusMetrics()
 	if err != nil {
 		return errors.Wrap(err, "unable to init prometheus metrics")
@@ -64,6 +74,11 @@ func (p *PrometheusMetrics) Start() error {
 	p.wg.Add(1)
 	go p.launchMetricsUpdater()
 
+	if len(p.Pushers) > 0 {
+		p.wg.Add(1)
+		go p.launchPushers()
+	}
+
 	return nil
 }
 
@@ -112,10 +127,44 @@ metricsUpdateLoop:
 	}
 }
 
+// push the metrics to every pusher every PushInterval, and once more when closing
+func (p *PrometheusMetrics) launchPushers() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.PushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.push(p.ctx)
+		case <-p.closeC:
+			// the context may be gone already, the last values are pushed on their own deadline
+			p.push(context.Background())
+			return
+		case <-p.ctx.Done():
+			p.push(context.Background())
+			return
+		}
+	}
+}
+
+func (p *PrometheusMetrics) push(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, PushTimeout)
+	defer cancel()
+	for _, pusher := range p.Pushers {
+		if err := pusher.Push(ctx); err != nil {
+			log.Errorf("could not push metrics to %s: %s", pusher.Name(), err)
+			continue
+		}
+		log.Tracef("pushed metrics to %s", pusher.Name())
+	}
+}
+
 func (p *PrometheusMetrics) Close() {
 	// Init loop for each of the Exporters
 	log.Infof("closing %d prometheus metrics modules", len(p.Modules))
-	p.closeC <- struct{}{}
+	close(p.closeC) // both the updater and the pushers stop
 	p.wg.Wait()
 	log.Infof("prometheus metrics exporte successfully closed")
 }
@@ -0,0 +1,121 @@
+package ntp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/migalabs/streameth/pkg/clock"
+)
+
+// Estimate is the offset of the local clock chosen from the last round of measurements
+type Estimate struct {
+	Server      string // whose measurement was chosen
+	Time        time.Time
+	Offset      time.Duration
+	Uncertainty time.Duration
+}
+
+// Valid tells if there was any successful measurement
+func (e Estimate) Valid() bool {
+	return e.Server != ""
+}
+
+// Monitor measures the offset against the servers periodically
+type Monitor struct {
+	servers  []string
+	interval time.Duration
+	timeout  time.Duration
+	clock    clock.Clock // drives the rounds
+
+	mu       sync.RWMutex
+	estimate Estimate
+}
+
+func NewMonitor(servers []string, interval time.Duration, timeout time.Duration, c clock.Clock) *Monitor {
+	return &Monitor{
+		servers:  servers,
+		interval: interval,
+		timeout:  timeout,
+		clock:    clock.OrReal(c),
+	}
+}
+
+// Estimate returns the last estimate of the offset
+func (m *Monitor) Estimate() Estimate {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.estimate
+}
+
+// Measure queries every server once, and keeps as estimate the measurement with the lowest uncertainty
+// The previous estimate is kept if no server answered
+func (m *Monitor) Measure() ([]Measurement, Estimate, error) {
+	measurements := make([]Measurement, 0, len(m.servers))
+	for _, server := range m.servers {
+		measurement, err := Query(server, m.timeout)
+		if err != nil {
+			log.Warnf("could not measure the clock offset: %s", err)
+			continue
+		}
+		measurements = append(measurements, measurement)
+	}
+	if len(measurements) == 0 {
+		return measurements, m.Estimate(), fmt.Errorf("no ntp server answered")
+	}
+
+	best := measurements[0]
+	for _, measurement := range measurements[1:] {
+		if measurement.Uncertainty < best.Uncertainty {
+			best = measurement
+		}
+	}
+	estimate := Estimate{
+		Server:      best.Server,
+		Time:        best.Time,
+		Offset:      best.Offset,
+		Uncertainty: best.Uncertainty,
+	}
+	m.mu.Lock()
+	m.estimate = estimate
+	m.mu.Unlock()
+	log.Debugf("clock offset %s (+-%s) from %s", estimate.Offset, estimate.Uncertainty, estimate.Server)
+	return measurements, estimate, nil
+}
+
+// Run measures every interval until the context is done, handing each round to onRound
+func (m *Monitor) Run(ctx context.Context, onRound func(measurements []Measurement, estimate Estimate)) {
+	ticker := m.clock.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		measurements, estimate, err := m.Measure()
+		if err != nil {
+			log.Errorf("could not measure the clock offset: %s", err)
+		} else {
+			onRound(measurements, estimate)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.Chan():
+		}
+	}
+}
+
+// Clock returns the base clock corrected by the estimated offset
+// The timers are those of the base clock, only the time is corrected
+func (m *Monitor) Clock(base clock.Clock) clock.Clock {
+	return correctedClock{Clock: clock.OrReal(base), monitor: m}
+}
+
+type correctedClock struct {
+	clock.Clock
+	monitor *Monitor
+}
+
+func (c correctedClock) Now() time.Time {
+	return c.Clock.Now().Add(c.monitor.Estimate().Offset)
+}
@@ -0,0 +1,132 @@
+package ntp
+
+/*
+
+This package measures the offset of the local clock against NTP servers (SNTP, RFC 4330),
+so the arrival timestamps of different machines can be compared
+
+*/
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	moduleName = "NTP"
+	log        = logrus.WithField(
+		"module", moduleName)
+)
+
+const (
+	DefaultPort = "123"
+	packetLen   = 48
+	ntpEpoch    = 2208988800 // seconds from 1900 to 1970
+
+	modeClient = 3
+	modeServer = 4
+	version    = 4
+	leapAlarm  = 3 // the server clock is not synchronized
+)
+
+// Measurement is the result of querying a server once
+type Measurement struct {
+	Server      string
+	Time        time.Time     // local time the response was received
+	Offset      time.Duration // to add to the local clock to get the server one
+	Delay       time.Duration // round trip, without the time the server took
+	Uncertainty time.Duration // the offset is within +-Uncertainty of the true one
+	Stratum     uint8
+}
+
+// Query measures the offset of the local clock against the server (host or host:port)
+func Query(server string, timeout time.Duration) (Measurement, error) {
+	address := server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		address = net.JoinHostPort(server, DefaultPort)
+	}
+	conn, err := net.DialTimeout("udp", address, timeout)
+	if err != nil {
+		return Measurement{}, fmt.Errorf("could not connect to %s: %s", server, err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return Measurement{}, err
+	}
+
+	request := make([]byte, packetLen)
+	request[0] = version<<3 | modeClient
+	t1 := time.Now()
+	putTimestamp(request[40:], t1)
+	if _, err := conn.Write(request); err != nil {
+		return Measurement{}, fmt.Errorf("could not send request to %s: %s", server, err)
+	}
+
+	response := make([]byte, packetLen)
+	n, err := conn.Read(response)
+	t4 := time.Now()
+	if err != nil {
+		return Measurement{}, fmt.Errorf("no response from %s: %s", server, err)
+	}
+	if n < packetLen {
+		return Measurement{}, fmt.Errorf("short response from %s: %d bytes", server, n)
+	}
+	return parseResponse(server, request, response, t1, t4)
+}
+
+func parseResponse(server string, request []byte, response []byte, t1 time.Time, t4 time.Time) (Measurement, error) {
+	leap, mode, stratum := response[0]>>6, response[0]&0x7, response[1]
+	switch {
+	case mode != modeServer:
+		return Measurement{}, fmt.Errorf("unexpected mode %d in the response of %s", mode, server)
+	case stratum == 0:
+		return Measurement{}, fmt.Errorf("%s sent a kiss-o'-death (%s)", server, string(response[12:16]))
+	case leap == leapAlarm:
+		return Measurement{}, fmt.Errorf("%s is not synchronized", server)
+	case binary.BigEndian.Uint64(response[24:32]) != binary.BigEndian.Uint64(request[40:48]):
+		// the originate timestamp must echo our transmit one
+		return Measurement{}, fmt.Errorf("response of %s does not match the request", server)
+	}
+
+	t2 := getTimestamp(response[32:])
+	t3 := getTimestamp(response[40:])
+	rootDelay := getShort(response[4:])
+	rootDispersion := getShort(response[8:])
+
+	delay := t4.Sub(t1) - t3.Sub(t2)
+	if delay < 0 {
+		delay = 0
+	}
+	return Measurement{
+		Server:      server,
+		Time:        t4,
+		Offset:      (t2.Sub(t1) + t3.Sub(t4)) / 2,
+		Delay:       delay,
+		Uncertainty: delay/2 + rootDelay/2 + rootDispersion,
+		Stratum:     stratum,
+	}, nil
+}
+
+// 64 bit NTP timestamp: seconds since 1900 and fraction of second
+func putTimestamp(b []byte, t time.Time) {
+	seconds := uint64(t.Unix()) + ntpEpoch
+	fraction := (uint64(t.Nanosecond()) << 32) / uint64(time.Second)
+	binary.BigEndian.PutUint64(b, seconds<<32|fraction)
+}
+
+func getTimestamp(b []byte) time.Time {
+	value := binary.BigEndian.Uint64(b)
+	seconds := int64(value>>32) - ntpEpoch
+	nanos := int64(((value & 0xffffffff) * uint64(time.Second)) >> 32)
+	return time.Unix(seconds, nanos)
+}
+
+// 32 bit NTP short format: 16.16 fixed point seconds
+func getShort(b []byte) time.Duration {
+	value := binary.BigEndian.Uint32(b)
+	return time.Duration(uint64(value) * uint64(time.Second) >> 16)
+}
@@ -0,0 +1,90 @@
+package ntp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/migalabs/streameth/pkg/clock"
+)
+
+// the stand-in server runs on the same host, so the offset is measured within a few milliseconds
+const tolerance = 20 * time.Millisecond
+
+func newTestServer(t *testing.T, offset time.Duration) *Server {
+	server, err := NewServer("127.0.0.1:0", offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+func within(value time.Duration, expected time.Duration) bool {
+	return value > expected-tolerance && value < expected+tolerance
+}
+
+func TestQuery(t *testing.T) {
+	server := newTestServer(t, 300*time.Millisecond)
+
+	measurement, err := Query(server.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !within(measurement.Offset, 300*time.Millisecond) {
+		t.Errorf("expected an offset of 300ms, got %s", measurement.Offset)
+	}
+	if measurement.Delay < 0 || measurement.Uncertainty < measurement.Delay/2 || measurement.Stratum != 1 {
+		t.Errorf("unexpected measurement %+v", measurement)
+	}
+
+	server.SetStratum(0)
+	if _, err := Query(server.Addr(), time.Second); err == nil {
+		t.Errorf("expected the kiss-o'-death to be rejected")
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	now := time.Unix(1700000000, 123456789)
+	b := make([]byte, 8)
+	putTimestamp(b, now)
+	if diff := getTimestamp(b).Sub(now); diff < -time.Nanosecond || diff > time.Nanosecond {
+		t.Errorf("timestamp round trip off by %s", diff)
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	ahead := newTestServer(t, 500*time.Millisecond)
+	behind := newTestServer(t, -200*time.Millisecond)
+	servers := []string{"127.0.0.1:1", ahead.Addr(), behind.Addr()} // the first one does not answer
+
+	fake := clock.NewFake(time.Now())
+	monitor := NewMonitor(servers, time.Minute, 100*time.Millisecond, fake)
+	if monitor.Estimate().Valid() {
+		t.Errorf("no estimate expected before measuring")
+	}
+
+	rounds := make(chan []Measurement, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go monitor.Run(ctx, func(measurements []Measurement, estimate Estimate) {
+		rounds <- measurements
+	})
+	if measurements := <-rounds; len(measurements) != 2 {
+		t.Fatalf("expected the measurements of two servers, got %+v", measurements)
+	}
+	estimate := monitor.Estimate()
+	if !estimate.Valid() || (!within(estimate.Offset, 500*time.Millisecond) && !within(estimate.Offset, -200*time.Millisecond)) {
+		t.Errorf("unexpected estimate %+v", estimate)
+	}
+
+	// the next round is measured once the interval passes
+	fake.BlockUntil(1)
+	fake.Advance(time.Minute)
+	<-rounds
+
+	corrected := monitor.Clock(fake)
+	if diff := corrected.Now().Sub(fake.Now()); diff != monitor.Estimate().Offset {
+		t.Errorf("expected the clock to be corrected by %s, got %s", monitor.Estimate().Offset, diff)
+	}
+}
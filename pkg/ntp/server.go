@@ -0,0 +1,78 @@
+package ntp
+
+import (
+	"encoding/binary"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Server is a minimal SNTP server whose clock is the local one plus a fixed offset,
+// it stands in for a real server in the tests
+type Server struct {
+	conn    *net.UDPConn
+	offset  time.Duration
+	stratum atomic.Uint32
+}
+
+// NewServer listens on the given udp address ("127.0.0.1:0" for any free port)
+func NewServer(address string, offset time.Duration) (*Server, error) {
+	udpAddress, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", udpAddress)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		conn:   conn,
+		offset: offset,
+	}
+	s.stratum.Store(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+// SetStratum changes the stratum of the answers, 0 sends kiss-o'-death packets
+func (s *Server) SetStratum(stratum uint8) {
+	s.stratum.Store(uint32(stratum))
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	return s.conn.Close()
+}
+
+func (s *Server) serve() {
+	request := make([]byte, packetLen)
+	for {
+		n, client, err := s.conn.ReadFromUDP(request)
+		if err != nil {
+			return // closed
+		}
+		received := time.Now().Add(s.offset)
+		if n < packetLen || request[0]&0x7 != modeClient {
+			continue
+		}
+		response := make([]byte, packetLen)
+		response[0] = version<<3 | modeServer
+		response[1] = uint8(s.stratum.Load())
+		response[3] = 0xec                    // precision of 2^-20 seconds
+		copy(response[12:16], []byte("LOCL")) // reference id
+		putTimestamp(response[16:], received)
+		copy(response[24:32], request[40:48]) // originate: the transmit time of the client
+		putTimestamp(response[32:], received)
+		putTimestamp(response[40:], time.Now().Add(s.offset))
+		binary.BigEndian.PutUint32(response[4:], 0) // root delay
+		binary.BigEndian.PutUint32(response[8:], 0) // root dispersion
+		if _, err := s.conn.WriteToUDP(response, client); err != nil {
+			log.Debugf("could not answer %s: %s", client, err)
+		}
+	}
+}
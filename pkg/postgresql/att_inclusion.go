@@ -0,0 +1,73 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the attestation inclusion table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreateAttInclusionTable = `
+		CREATE TABLE IF NOT EXISTS t_att_inclusion(
+			f_label TEXT,
+			f_slot INT,
+			f_position INT,
+			f_att_slot INT,
+			f_committee_index INT,
+			f_inclusion_delay INT,
+			f_votes INT,
+			f_new_votes INT,
+			f_correct_source BOOL,
+			f_correct_target BOOL,
+			f_correct_head BOOL,
+			CONSTRAINT PK_AttInclusion PRIMARY KEY (f_label,f_slot,f_position));`
+
+	InsertNewAttInclusion = `
+		INSERT INTO t_att_inclusion (
+			f_label,
+			f_slot,
+			f_position,
+			f_att_slot,
+			f_committee_index,
+			f_inclusion_delay,
+			f_votes,
+			f_new_votes,
+			f_correct_source,
+			f_correct_target,
+			f_correct_head)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT DO NOTHING;`
+
+	AttInclusionCopyTarget = &CopyTarget{
+		Table: "t_att_inclusion",
+		Columns: []string{
+			"f_label",
+			"f_slot",
+			"f_position",
+			"f_att_slot",
+			"f_committee_index",
+			"f_inclusion_delay",
+			"f_votes",
+			"f_new_votes",
+			"f_correct_source",
+			"f_correct_target",
+			"f_correct_head"},
+	}
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createAttInclusionTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	_, err := pool.Exec(ctx, CreateAttInclusionTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating attestation inclusion table")
+	}
+	return nil
+}
@@ -24,6 +24,7 @@ var (
 			f_target_root TEXT,
 			f_head_root TEXT,
 			f_timestamp TIMESTAMP,
+			f_clock_offset_ms REAL,
 		CONSTRAINT PK_Attestation PRIMARY KEY (f_label,f_slot,f_committee_index,f_signature));`
 
 	InsertNewAtt = `
@@ -35,17 +36,29 @@ var (
 			f_signature,
 			f_source_root,
 			f_target_root,
-			f_head_root)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			f_head_root,
+			f_clock_offset_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		ON CONFLICT DO NOTHING;`
+
+	// columns added after the first release, for tables created by older versions
+	AttMetricsMigrations = []string{
+		`ALTER TABLE t_att_metrics ADD COLUMN IF NOT EXISTS f_clock_offset_ms REAL;`,
+	}
 )
 
 // in case the table did not exist
 func (p *PostgresDBService) createAttMetricsTable(ctx context.Context, pool *pgxpool.Pool) error {
 	// create the tables
-	_, err := pool.Exec(ctx, CreateAttTable)
+	err := p.createTable(ctx, pool, CreateAttTable)
 	if err != nil {
 		return errors.Wrap(err, "error creating attestation metrics table")
 	}
+	for _, migration := range AttMetricsMigrations {
+		_, err = pool.Exec(ctx, migration)
+		if err != nil {
+			return errors.Wrap(err, "error migrating attestation metrics table")
+		}
+	}
 	return nil
 }
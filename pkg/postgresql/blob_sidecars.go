@@ -0,0 +1,49 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the blob sidecar events table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreateBlobSidecarTable = `
+		CREATE TABLE IF NOT EXISTS t_blob_sidecar_events(
+			f_label TEXT,
+			f_slot INT,
+			f_block_root TEXT,
+			f_index INT,
+			f_kzg_commitment TEXT,
+			f_versioned_hash TEXT,
+			f_timestamp TIMESTAMP,
+			CONSTRAINT PK_BlobSidecar PRIMARY KEY (f_label,f_slot,f_block_root,f_index));`
+
+	InsertNewBlobSidecar = `
+		INSERT INTO t_blob_sidecar_events (
+			f_label,
+			f_slot,
+			f_block_root,
+			f_index,
+			f_kzg_commitment,
+			f_versioned_hash,
+			f_timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT DO NOTHING;`
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createBlobSidecarTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	err := p.createTable(ctx, pool, CreateBlobSidecarTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating blob sidecar events table")
+	}
+	return nil
+}
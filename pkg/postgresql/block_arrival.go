@@ -19,22 +19,36 @@ var (
 			f_slot INT,
 			f_label TEXT,
 			f_timestamp TIME,
+			f_clock_offset_ms REAL,
 			CONSTRAINT PK_Block PRIMARY KEY (f_slot,f_label));`
 
 	InsertNewBlock = `
 		INSERT INTO t_block_metrics (	
 			f_slot, 
 			f_label, 
-			f_timestamp)
-		VALUES ($1, $2, $3);`
+			f_timestamp,
+			f_clock_offset_ms)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING;`
+
+	// columns added after the first release, for tables created by older versions
+	BlockMetricsMigrations = []string{
+		`ALTER TABLE t_block_metrics ADD COLUMN IF NOT EXISTS f_clock_offset_ms REAL;`,
+	}
 )
 
 // in case the table did not exist
 func (p *PostgresDBService) createBlockMetricsTable(ctx context.Context, pool *pgxpool.Pool) error {
 	// create the tables
-	_, err := pool.Exec(ctx, CREATE_BLOCK_ARRIVAL_TABLE)
+	err := p.createTable(ctx, pool, CREATE_BLOCK_ARRIVAL_TABLE)
 	if err != nil {
 		return errors.Wrap(err, "error creating block arrival metrics table")
 	}
+	for _, migration := range BlockMetricsMigrations {
+		_, err = pool.Exec(ctx, migration)
+		if err != nil {
+			return errors.Wrap(err, "error migrating block arrival metrics table")
+		}
+	}
 	return nil
 }
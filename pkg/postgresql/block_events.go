@@ -0,0 +1,47 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the block and block gossip events table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreateBlockEventTable = `
+		CREATE TABLE IF NOT EXISTS t_block_events(
+			f_label TEXT,
+			f_topic TEXT,
+			f_slot INT,
+			f_block_root TEXT,
+			f_execution_optimistic BOOL,
+			f_timestamp TIMESTAMP,
+			CONSTRAINT PK_BlockEvent PRIMARY KEY (f_label,f_topic,f_slot,f_block_root));`
+
+	InsertNewBlockEvent = `
+		INSERT INTO t_block_events (
+			f_label,
+			f_topic,
+			f_slot,
+			f_block_root,
+			f_execution_optimistic,
+			f_timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT DO NOTHING;`
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createBlockEventsTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	err := p.createTable(ctx, pool, CreateBlockEventTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating block events table")
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ This file together with the model, has all the needed methods to interact with t
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/pkg/errors"
@@ -34,7 +35,24 @@ var (
 			f_sync_score FLOAT,
 			f_execution_value_wei BIGINT,
 			f_consensus_value_wei BIGINT,
-			CONSTRAINT PK_Score PRIMARY KEY (f_slot,f_label));`
+			f_skip_reason TEXT,
+			f_offset_ms INT NOT NULL DEFAULT 0,
+			f_pool_size INT,
+			f_achievable_votes INT,
+			f_packing_efficiency FLOAT,
+			f_vote_age_mean FLOAT,
+			f_vote_age_median INT,
+			f_vote_age_p90 INT,
+			f_vote_age_max INT,
+			f_fresh_votes INT,
+			f_stale_votes INT,
+			f_worthless_votes INT,
+			f_vote_age_histogram JSONB,
+			f_zero_new_vote_atts INT,
+			f_subsumed_atts INT,
+			f_mergeable_att_pairs INT,
+			f_wasted_att_bytes INT,
+			CONSTRAINT PK_Score PRIMARY KEY (f_slot,f_label,f_offset_ms));`
 
 	InsertNewScore = `
 		INSERT INTO t_score_metrics (	
@@ -55,17 +73,72 @@ var (
 			f_attester_slashing_score,
 			f_sync_score,
 			f_execution_value_wei,
-			f_consensus_value_wei)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18);`
+			f_consensus_value_wei,
+			f_skip_reason,
+			f_offset_ms,
+			f_pool_size,
+			f_achievable_votes,
+			f_packing_efficiency,
+			f_vote_age_mean,
+			f_vote_age_median,
+			f_vote_age_p90,
+			f_vote_age_max,
+			f_fresh_votes,
+			f_stale_votes,
+			f_worthless_votes,
+			f_vote_age_histogram,
+			f_zero_new_vote_atts,
+			f_subsumed_atts,
+			f_mergeable_att_pairs,
+			f_wasted_att_bytes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35)
+		ON CONFLICT DO NOTHING;`
+
+	// columns added after the first release, for tables created by older versions
+	ScoreMetricsMigrations = []string{
+		`ALTER TABLE t_score_metrics ADD COLUMN IF NOT EXISTS f_skip_reason TEXT;`,
+		`ALTER TABLE t_score_metrics ADD COLUMN IF NOT EXISTS f_offset_ms INT NOT NULL DEFAULT 0;`,
+		// proposals at several offsets of the same slot, the offset is part of the key
+		`DO $$
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.key_column_usage
+				WHERE table_name = 't_score_metrics' AND constraint_name = 'pk_score' AND column_name = 'f_offset_ms') THEN
+				ALTER TABLE t_score_metrics DROP CONSTRAINT IF EXISTS pk_score;
+				ALTER TABLE t_score_metrics ADD CONSTRAINT PK_Score PRIMARY KEY (f_slot,f_label,f_offset_ms);
+			END IF;
+		END $$;`,
+		`ALTER TABLE t_score_metrics ADD COLUMN IF NOT EXISTS f_pool_size INT;`,
+		`ALTER TABLE t_score_metrics ADD COLUMN IF NOT EXISTS f_achievable_votes INT;`,
+		`ALTER TABLE t_score_metrics ADD COLUMN IF NOT EXISTS f_packing_efficiency FLOAT;`,
+		`ALTER TABLE t_score_metrics ADD COLUMN IF NOT EXISTS f_vote_age_mean FLOAT;`,
+		`ALTER TABLE t_score_metrics ADD COLUMN IF NOT EXISTS f_vote_age_median INT;`,
+		`ALTER TABLE t_score_metrics ADD COLUMN IF NOT EXISTS f_vote_age_p90 INT;`,
+		`ALTER TABLE t_score_metrics ADD COLUMN IF NOT EXISTS f_vote_age_max INT;`,
+		`ALTER TABLE t_score_metrics ADD COLUMN IF NOT EXISTS f_fresh_votes INT;`,
+		`ALTER TABLE t_score_metrics ADD COLUMN IF NOT EXISTS f_stale_votes INT;`,
+		`ALTER TABLE t_score_metrics ADD COLUMN IF NOT EXISTS f_worthless_votes INT;`,
+		`ALTER TABLE t_score_metrics ADD COLUMN IF NOT EXISTS f_vote_age_histogram JSONB;`,
+		`ALTER TABLE t_score_metrics ADD COLUMN IF NOT EXISTS f_zero_new_vote_atts INT;`,
+		`ALTER TABLE t_score_metrics ADD COLUMN IF NOT EXISTS f_subsumed_atts INT;`,
+		`ALTER TABLE t_score_metrics ADD COLUMN IF NOT EXISTS f_mergeable_att_pairs INT;`,
+		`ALTER TABLE t_score_metrics ADD COLUMN IF NOT EXISTS f_wasted_att_bytes INT;`,
+	}
 )
 
 // in case the table did not exist
 func (p *PostgresDBService) createScoreMetricsTable(ctx context.Context, pool *pgxpool.Pool) error {
 	// create the tables
-	_, err := pool.Exec(ctx, CREATE_SCORE_TABLE)
+	err := p.createTable(ctx, pool, CREATE_SCORE_TABLE)
 	if err != nil {
 		return errors.Wrap(err, "error creating score metrics table")
 	}
+	for _, migration := range ScoreMetricsMigrations {
+		_, err = pool.Exec(ctx, migration)
+		if err != nil {
+			return errors.Wrap(err, "error migrating score metrics table")
+		}
+	}
 	return nil
 }
 
@@ -88,16 +161,38 @@ type BlockMetricsModel struct {
 	SyncScore             float64
 	ExecutionValue        uint64 // wei
 	ConsensusValue        uint64 // wei
+	SkipReason            string // why the proposal was not scored, empty if it was
+	OffsetMs              int    // time relative to the slot start when the proposal was requested
+	PoolSnapshot          bool   // the attestation pool was fetched, otherwise the packing metrics are empty
+	PoolSize              int
+	AchievableVotes       int // new votes of the best packing of the pool
+	PackingEfficiency     float64
+	VoteAgeHistogram      map[uint64]int // slots between the block and the attestation -> new votes
+	VoteAgeMean           float64
+	VoteAgeMedian         uint64
+	VoteAgeP90            uint64
+	VoteAgeMax            uint64
+	FreshVotes            int // new votes of the previous slot
+	StaleVotes            int // new votes past the source reward window
+	WorthlessVotes        int // new votes earning no reward
+	ZeroNewVoteAtts       int // aggregates adding no new votes
+	SubsumedAtts          int // aggregates contained in another one of the block
+	MergeableAttPairs     int // aggregates with the same data and disjoint votes
+	WastedAttBytes        int // size of the aggregates that could be dropped
 }
 
-func (p *PostgresDBService) PersisBlockScoreMetrics(block BlockMetricsModel) {
+// BlockScoreTask builds the record of the score of a proposal
+func BlockScoreTask(block BlockMetricsModel) WriteTask {
 
-	// Store in DB
 	params := make([]interface{}, 0)
 	params = append(params, block.Slot)
 	params = append(params, block.ClientName)
 	params = append(params, block.Label)
-	params = append(params, block.Score)
+	if block.SkipReason != "" {
+		params = append(params, nil) // no score for proposals that were skipped or failed
+	} else {
+		params = append(params, block.Score)
+	}
 	params = append(params, block.Duration)
 	params = append(params, block.CorrectSource)
 	params = append(params, block.CorrectTarget)
@@ -112,11 +207,49 @@ func (p *PostgresDBService) PersisBlockScoreMetrics(block BlockMetricsModel) {
 	params = append(params, block.SyncScore)
 	params = append(params, block.ExecutionValue)
 	params = append(params, block.ConsensusValue)
+	if block.SkipReason != "" {
+		params = append(params, block.SkipReason)
+	} else {
+		params = append(params, nil)
+	}
+	params = append(params, block.OffsetMs)
+	if block.PoolSnapshot && block.SkipReason == "" {
+		params = append(params, block.PoolSize)
+		params = append(params, block.AchievableVotes)
+		params = append(params, block.PackingEfficiency)
+	} else {
+		params = append(params, nil, nil, nil)
+	}
+	if block.SkipReason == "" {
+		params = append(params, block.VoteAgeMean)
+		params = append(params, block.VoteAgeMedian)
+		params = append(params, block.VoteAgeP90)
+		params = append(params, block.VoteAgeMax)
+		params = append(params, block.FreshVotes)
+		params = append(params, block.StaleVotes)
+		params = append(params, block.WorthlessVotes)
+		histogram, err := json.Marshal(block.VoteAgeHistogram)
+		if err != nil {
+			log.Errorf("could not encode vote age histogram: %s", err)
+			params = append(params, nil)
+		} else {
+			params = append(params, string(histogram))
+		}
+	} else {
+		params = append(params, nil, nil, nil, nil, nil, nil, nil, nil)
+	}
+	if block.SkipReason == "" {
+		params = append(params, block.ZeroNewVoteAtts)
+		params = append(params, block.SubsumedAtts)
+		params = append(params, block.MergeableAttPairs)
+		params = append(params, block.WastedAttBytes)
+	} else {
+		params = append(params, nil, nil, nil, nil)
+	}
 
-	writeTask := WriteTask{
+	return WriteTask{
 		QueryString: InsertNewScore,
 		Params:      params,
+		Type:        RecordScore,
 	}
-
-	p.WriteChan <- writeTask
 }
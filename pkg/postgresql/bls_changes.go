@@ -0,0 +1,45 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the bls to execution change events table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreateBLSChangeTable = `
+		CREATE TABLE IF NOT EXISTS t_bls_change_events(
+			f_label TEXT,
+			f_validator_index BIGINT,
+			f_from_bls_pubkey TEXT,
+			f_to_execution_address TEXT,
+			f_timestamp TIMESTAMP,
+			CONSTRAINT PK_BLSChange PRIMARY KEY (f_label,f_validator_index));`
+
+	InsertNewBLSChange = `
+		INSERT INTO t_bls_change_events (
+			f_label,
+			f_validator_index,
+			f_from_bls_pubkey,
+			f_to_execution_address,
+			f_timestamp)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO NOTHING;`
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createBLSChangeTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	_, err := pool.Exec(ctx, CreateBLSChangeTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating bls to execution change events table")
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package postgresql
+
+/*
+
+This file has the methods to bulk load append only records with COPY, through a staging table
+
+*/
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	pgx_v4 "github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+)
+
+var (
+	COPY_BATCH_LEN = 1000 // rows per COPY, pending rows are also flushed periodically
+)
+
+// CopyTarget describes an append only table that can be bulk loaded with COPY
+// Columns follow the order of the params of the tasks
+type CopyTarget struct {
+	Table   string
+	Columns []string
+}
+
+var (
+	AttCopyTarget = &CopyTarget{
+		Table: "t_att_metrics",
+		Columns: []string{
+			"f_label",
+			"f_slot",
+			"f_committee_index",
+			"f_timestamp",
+			"f_signature",
+			"f_source_root",
+			"f_target_root",
+			"f_head_root",
+			"f_clock_offset_ms"},
+	}
+
+	BlockCopyTarget = &CopyTarget{
+		Table:   "t_block_metrics",
+		Columns: []string{"f_slot", "f_label", "f_timestamp", "f_clock_offset_ms"},
+	}
+
+	MissedBlockCopyTarget = &CopyTarget{
+		Table:   "t_missed_blocks",
+		Columns: []string{"f_slot", "f_label"},
+	}
+
+	ReorgCopyTarget = &CopyTarget{
+		Table: "t_reorg_metrics",
+		Columns: []string{
+			"f_label",
+			"f_slot",
+			"f_old_head",
+			"f_new_head",
+			"f_depth",
+			"f_timestamp"},
+	}
+)
+
+// ExecuteCopy loads the rows into a temporary staging table with COPY and merges them
+// into the target, so duplicated rows are skipped as with ON CONFLICT DO NOTHING
+func (p *PostgresDBService) ExecuteCopy(ctx context.Context, target *CopyTarget, rows [][]interface{}) error {
+	snapshot := time.Now()
+	tx, err := p.psqlPool.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not begin copy transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	staging := fmt.Sprintf("tmp_%s", target.Table)
+	_, err = tx.Exec(ctx, fmt.Sprintf(
+		`CREATE TEMP TABLE %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP;`, staging, target.Table))
+	if err != nil {
+		return errors.Wrapf(err, "could not create staging table for %s", target.Table)
+	}
+
+	copied, err := tx.CopyFrom(ctx, pgx_v4.Identifier{staging}, target.Columns, pgx_v4.CopyFromRows(rows))
+	if err != nil {
+		return errors.Wrapf(err, "could not copy rows into %s", staging)
+	}
+
+	columns := strings.Join(target.Columns, ", ")
+	_, err = tx.Exec(ctx, fmt.Sprintf(
+		`INSERT INTO %s (%s) SELECT %s FROM %s ON CONFLICT DO NOTHING;`, target.Table, columns, columns, staging))
+	if err != nil {
+		return errors.Wrapf(err, "could not merge staging rows into %s", target.Table)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "could not commit copy into %s", target.Table)
+	}
+	log.Tracef("Copy process time: %f, rows: %d, table: %s", time.Since(snapshot).Seconds(), copied, target.Table)
+	return nil
+}
@@ -0,0 +1,92 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the canonical score metrics table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreateCanonicalScoreTable = `
+		CREATE TABLE IF NOT EXISTS t_canonical_score_metrics(
+			f_slot INT,
+			f_label TEXT,
+			f_proposer_index INT,
+			f_score FLOAT,
+			f_correct_source INT,
+			f_correct_target INT,
+			f_correct_head INT,
+			f_sync_bits INT,
+			f_att_num INT,
+			f_new_votes INT,
+			f_attester_slashings INT,
+			f_proposer_slashings INT,
+			f_proposer_slashing_score FLOAT,
+			f_attester_slashing_score FLOAT,
+			f_sync_score FLOAT,
+			CONSTRAINT PK_CanonicalScore PRIMARY KEY (f_slot,f_label));`
+
+	InsertNewCanonicalScore = `
+		INSERT INTO t_canonical_score_metrics (
+			f_slot,
+			f_label,
+			f_proposer_index,
+			f_score,
+			f_correct_source,
+			f_correct_target,
+			f_correct_head,
+			f_sync_bits,
+			f_att_num,
+			f_new_votes,
+			f_attester_slashings,
+			f_proposer_slashings,
+			f_proposer_slashing_score,
+			f_attester_slashing_score,
+			f_sync_score)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		ON CONFLICT DO NOTHING;`
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createCanonicalScoreTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	_, err := pool.Exec(ctx, CreateCanonicalScoreTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating canonical score metrics table")
+	}
+	return nil
+}
+
+// CanonicalScoreTask builds the record of the score of a block of the chain
+func CanonicalScoreTask(block BlockMetricsModel, proposerIndex uint64) WriteTask {
+
+	params := make([]interface{}, 0)
+	params = append(params, block.Slot)
+	params = append(params, block.Label)
+	params = append(params, proposerIndex)
+	params = append(params, block.Score)
+	params = append(params, block.CorrectSource)
+	params = append(params, block.CorrectTarget)
+	params = append(params, block.CorrectHead)
+	params = append(params, block.Sync1Bits)
+	params = append(params, block.AttNum)
+	params = append(params, block.NewVotes)
+	params = append(params, block.AttesterSlashings)
+	params = append(params, block.ProposerSlashings)
+	params = append(params, block.ProposerSlashingScore)
+	params = append(params, block.AttesterSlashingScore)
+	params = append(params, block.SyncScore)
+
+	return WriteTask{
+		QueryString: InsertNewCanonicalScore,
+		Params:      params,
+		Type:        RecordScore,
+	}
+}
@@ -0,0 +1,53 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the clock offsets table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreateClockOffsetsTable = `
+		CREATE TABLE IF NOT EXISTS t_clock_offsets(
+			f_timestamp TIMESTAMP,
+			f_server TEXT,
+			f_offset_ms REAL,
+			f_delay_ms REAL,
+			f_uncertainty_ms REAL,
+			f_stratum INT,
+			f_estimate_ms REAL,
+			f_estimate_uncertainty_ms REAL,
+			f_corrected BOOL,
+			CONSTRAINT PK_ClockOffset PRIMARY KEY (f_timestamp,f_server));`
+
+	InsertNewClockOffset = `
+		INSERT INTO t_clock_offsets (
+			f_timestamp,
+			f_server,
+			f_offset_ms,
+			f_delay_ms,
+			f_uncertainty_ms,
+			f_stratum,
+			f_estimate_ms,
+			f_estimate_uncertainty_ms,
+			f_corrected)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT DO NOTHING;`
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createClockOffsetsTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	_, err := pool.Exec(ctx, CreateClockOffsetsTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating clock offsets table")
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package postgresql
+
+/*
+
+This file has the methods to retry failed batches, isolate the statements that fail and keep them in a dead-letter file
+
+*/
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/pkg/errors"
+)
+
+var (
+	BATCH_RETRIES       = 3
+	BATCH_RETRY_BACKOFF = 2 * time.Second // doubled on each retry
+)
+
+// DeadLetter is a record the database rejected, one json per line in the dead-letter file
+type DeadLetter struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Error     string          `json:"error"`
+	Task      json.RawMessage `json:"task"`
+}
+
+// WriteTasks writes the tasks retrying transient errors. If the batch keeps failing it is bisected
+// until the failing statements are isolated, which are written to the dead-letter file.
+// Returns the number of rejected tasks
+func (p *PostgresDBService) WriteTasks(ctx context.Context, tasks []WriteTask) int {
+	if len(tasks) == 0 {
+		return 0
+	}
+	err := p.executeWithRetries(ctx, tasks)
+	if err == nil {
+		return 0
+	}
+
+	if len(tasks) == 1 || isTransientError(err) {
+		// nothing else to split, or the database is not available
+		for _, task := range tasks {
+			p.deadLetter(task, err)
+		}
+		return len(tasks)
+	}
+
+	log.Warnf("batch of %d tasks failed, bisecting: %s", len(tasks), err)
+	half := len(tasks) / 2
+	return p.WriteTasks(ctx, tasks[:half]) + p.WriteTasks(ctx, tasks[half:])
+}
+
+func (p *PostgresDBService) executeWithRetries(ctx context.Context, tasks []WriteTask) error {
+	backoff := BATCH_RETRY_BACKOFF
+	for attempt := 0; ; attempt++ {
+		err := p.executeBatch(ctx, tasks)
+		if err == nil || !isTransientError(err) || attempt >= BATCH_RETRIES {
+			return err
+		}
+		log.Warnf("transient error writing batch (attempt %d/%d), retrying in %s: %s", attempt+1, BATCH_RETRIES, backoff, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-p.clock.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isTransientError tells if the error comes from the connection or the server state,
+// not from the statement itself
+func isTransientError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false // shutting down
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case strings.HasPrefix(pgErr.Code, "08"), // connection exception
+			strings.HasPrefix(pgErr.Code, "53"),  // insufficient resources
+			strings.HasPrefix(pgErr.Code, "57P"), // operator intervention (shutdown)
+			pgErr.Code == "40001",                // serialization failure
+			pgErr.Code == "40P01":                // deadlock detected
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return pgconn.SafeToRetry(err) || pgconn.Timeout(err)
+}
+
+func (p *PostgresDBService) deadLetter(task WriteTask, taskErr error) {
+	atomic.AddUint64(&p.deadLettered, 1)
+	log.Errorf("rejected %s record: %s", task.Type, taskErr)
+
+	if p.deadLetterPath == "" {
+		return
+	}
+	encoded, err := EncodeTask(task)
+	if err != nil {
+		log.Errorf("could not encode rejected record, it is lost: %s", err)
+		return
+	}
+	line, err := json.Marshal(DeadLetter{
+		Timestamp: p.clock.Now(),
+		Error:     taskErr.Error(),
+		Task:      encoded,
+	})
+	if err != nil {
+		log.Errorf("could not encode rejected record, it is lost: %s", err)
+		return
+	}
+
+	p.deadLetterMu.Lock()
+	defer p.deadLetterMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(p.deadLetterPath), os.ModePerm); err != nil {
+		log.Errorf("could not create dead-letter dir, record lost: %s", err)
+		return
+	}
+	f, err := os.OpenFile(p.deadLetterPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		log.Errorf("could not open dead-letter file, record lost: %s", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Errorf("could not write dead-letter file, record lost: %s", err)
+	}
+}
+
+// DeadLettered returns the number of records rejected by the database
+func (p *PostgresDBService) DeadLettered() uint64 {
+	return atomic.LoadUint64(&p.deadLettered)
+}
+
+// ReplayDeadLetter writes again the records of the given dead-letter file, in batches of batchSize.
+// Records rejected again go to the dead-letter file of the service, which must be a different one
+func (p *PostgresDBService) ReplayDeadLetter(ctx context.Context, path string, batchSize int) (int, int, error) {
+	if p.deadLetterPath != "" && sameFile(path, p.deadLetterPath) {
+		return 0, 0, fmt.Errorf("the replayed file can not be the dead-letter file of the service")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not open dead-letter file %s: %s", path, err)
+	}
+	defer f.Close()
+
+	total, rejected := 0, 0
+	batch := make([]WriteTask, 0, batchSize)
+	flush := func() {
+		rejected += p.WriteTasks(ctx, batch)
+		batch = make([]WriteTask, 0, batchSize)
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		item := DeadLetter{}
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			return total, rejected, fmt.Errorf("could not decode line %d: %s", line, err)
+		}
+		task, err := DecodeTask(item.Task)
+		if err != nil {
+			return total, rejected, fmt.Errorf("could not decode task at line %d: %s", line, err)
+		}
+		batch = append(batch, task)
+		total++
+		if len(batch) >= batchSize {
+			flush()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return total, rejected, fmt.Errorf("could not read dead-letter file %s: %s", path, err)
+	}
+	flush()
+	return total, rejected, nil
+}
+
+// sameFile tells if both paths point to the same file, however they are written
+func sameFile(a string, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA == nil && errB == nil && absA == absB {
+		return true
+	}
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB) // links
+}
@@ -0,0 +1,160 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/migalabs/streameth/pkg/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeExecutor rejects the batches with a bad record, and fails the first calls with a transient error
+type fakeExecutor struct {
+	mu        sync.Mutex
+	transient int // calls left failing with a connection error
+	calls     int
+	written   []WriteTask
+}
+
+func (e *fakeExecutor) execute(ctx context.Context, tasks []WriteTask) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls++
+	if e.transient > 0 {
+		e.transient--
+		return &pgconn.PgError{Code: "08006", Message: "connection failure"}
+	}
+	for i, task := range tasks {
+		if task.Params[0] == "bad" {
+			return fmt.Errorf("statement %d of the batch failed: %w", i, &pgconn.PgError{Code: "23502", Message: "null value"})
+		}
+	}
+	e.written = append(e.written, tasks...)
+	return nil
+}
+
+func testDeadLetterService(t *testing.T, executor *fakeExecutor, c clock.Clock) *PostgresDBService {
+	return &PostgresDBService{
+		ctx:            context.Background(),
+		executeBatch:   executor.execute,
+		deadLetterPath: filepath.Join(t.TempDir(), "deadletter.jsonl"),
+		clock:          c,
+	}
+}
+
+func testTasks(labels ...string) []WriteTask {
+	tasks := make([]WriteTask, 0, len(labels))
+	for i, label := range labels {
+		tasks = append(tasks, WriteTask{QueryString: InsertNewMissedBlock, Params: []interface{}{label, int64(i)}, Type: RecordHead})
+	}
+	return tasks
+}
+
+func TestIsTransientError(t *testing.T) {
+	assert.True(t, isTransientError(&pgconn.PgError{Code: "08006"}))
+	assert.True(t, isTransientError(&pgconn.PgError{Code: "40P01"}))
+	assert.True(t, isTransientError(&pgconn.PgError{Code: "57P01"}))
+	assert.True(t, isTransientError(fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: "53300"})))
+	assert.False(t, isTransientError(&pgconn.PgError{Code: "23505"})) // unique violation
+	assert.False(t, isTransientError(&pgconn.PgError{Code: "42P01"})) // undefined table
+	assert.False(t, isTransientError(context.Canceled))
+	assert.False(t, isTransientError(fmt.Errorf("could not begin transaction: %w", context.DeadlineExceeded)))
+}
+
+func TestWriteTasksBisect(t *testing.T) {
+	executor := &fakeExecutor{}
+	p := testDeadLetterService(t, executor, clock.Real{})
+
+	tasks := testTasks("a", "b", "bad", "c", "d", "bad", "e")
+	assert.Equal(t, 2, p.WriteTasks(context.Background(), tasks))
+	assert.Equal(t, uint64(2), p.DeadLettered())
+	assert.Len(t, executor.written, 5)
+	for _, task := range executor.written {
+		assert.NotEqual(t, "bad", task.Params[0])
+	}
+
+	content, err := os.ReadFile(p.deadLetterPath)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "null value")
+}
+
+func TestWriteTasksTransient(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	executor := &fakeExecutor{transient: 2}
+	p := testDeadLetterService(t, executor, fake)
+
+	done := make(chan int)
+	go func() { done <- p.WriteTasks(context.Background(), testTasks("a", "b")) }()
+	backoff := BATCH_RETRY_BACKOFF
+	for i := 0; i < 2; i++ {
+		fake.BlockUntil(1)
+		fake.Advance(backoff)
+		backoff *= 2
+	}
+	assert.Equal(t, 0, <-done)
+	assert.Equal(t, 3, executor.calls)
+	assert.Len(t, executor.written, 2)
+
+	// the database stays down, the batch is kept as is instead of bisected
+	executor = &fakeExecutor{transient: BATCH_RETRIES + 1}
+	p = testDeadLetterService(t, executor, fake)
+	go func() { done <- p.WriteTasks(context.Background(), testTasks("a", "b")) }()
+	backoff = BATCH_RETRY_BACKOFF
+	for i := 0; i < BATCH_RETRIES; i++ {
+		fake.BlockUntil(1)
+		fake.Advance(backoff)
+		backoff *= 2
+	}
+	assert.Equal(t, 2, <-done)
+	assert.Equal(t, BATCH_RETRIES+1, executor.calls)
+}
+
+func TestReplayDeadLetter(t *testing.T) {
+	rejecting := &fakeExecutor{}
+	p := testDeadLetterService(t, rejecting, clock.Real{})
+	assert.Equal(t, 3, p.WriteTasks(context.Background(), testTasks("bad", "bad", "bad")))
+
+	// once fixed, the records are written again with their params
+	replaying := &fakeExecutor{}
+	replay := testDeadLetterService(t, replaying, clock.Real{})
+	total, rejected, err := replay.ReplayDeadLetter(context.Background(), p.deadLetterPath, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, 3, rejected) // still bad
+	assert.Equal(t, uint64(3), replay.DeadLettered())
+
+	content, err := os.ReadFile(replay.deadLetterPath)
+	assert.Nil(t, err)
+	replayed := filepath.Join(t.TempDir(), "replayed.jsonl")
+	assert.Nil(t, os.WriteFile(replayed, []byte(strings.ReplaceAll(string(content), `"bad"`, `"fixed"`)), 0644))
+	total, rejected, err = testDeadLetterService(t, replaying, clock.Real{}).ReplayDeadLetter(context.Background(), replayed, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, 0, rejected)
+	assert.Len(t, replaying.written, 3)
+	assert.Equal(t, InsertNewMissedBlock, replaying.written[0].QueryString)
+	assert.Equal(t, []interface{}{"fixed", int64(1)}, replaying.written[1].Params)
+}
+
+func TestReplayDeadLetterSameFile(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	assert.Nil(t, os.WriteFile("dl.jsonl", []byte{}, 0644))
+	assert.Nil(t, os.Symlink("dl.jsonl", "link.jsonl"))
+	p := &PostgresDBService{deadLetterPath: "dl.jsonl"}
+	for _, path := range []string{"dl.jsonl", "./dl.jsonl", filepath.Join(dir, "dl.jsonl"), "link.jsonl"} {
+		_, _, err := p.ReplayDeadLetter(context.Background(), path, 1)
+		assert.NotNil(t, err, path)
+	}
+}
@@ -0,0 +1,52 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the finality checkpoints table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreateFinalityTable = `
+		CREATE TABLE IF NOT EXISTS t_finality_checkpoints(
+			f_label TEXT,
+			f_client_name TEXT,
+			f_justified_epoch INT,
+			f_justified_root TEXT,
+			f_finalized_epoch INT,
+			f_finalized_root TEXT,
+			f_source TEXT,
+			f_timestamp TIMESTAMP,
+			CONSTRAINT PK_Finality PRIMARY KEY (f_label,f_justified_epoch,f_finalized_epoch));`
+
+	// only the first time a node reports the checkpoints is kept
+	InsertNewFinality = `
+		INSERT INTO t_finality_checkpoints (
+			f_label,
+			f_client_name,
+			f_justified_epoch,
+			f_justified_root,
+			f_finalized_epoch,
+			f_finalized_root,
+			f_source,
+			f_timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT DO NOTHING;`
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createFinalityTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	_, err := pool.Exec(ctx, CreateFinalityTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating finality checkpoints table")
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the finalized checkpoint events table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreateFinalizedCheckpointTable = `
+		CREATE TABLE IF NOT EXISTS t_finalized_checkpoint_events(
+			f_label TEXT,
+			f_epoch INT,
+			f_block_root TEXT,
+			f_state_root TEXT,
+			f_timestamp TIMESTAMP,
+			CONSTRAINT PK_FinalizedCheckpoint PRIMARY KEY (f_label,f_epoch));`
+
+	InsertNewFinalizedCheckpoint = `
+		INSERT INTO t_finalized_checkpoint_events (
+			f_label,
+			f_epoch,
+			f_block_root,
+			f_state_root,
+			f_timestamp)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO NOTHING;`
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createFinalizedCheckpointTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	_, err := pool.Exec(ctx, CreateFinalizedCheckpointTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating finalized checkpoint events table")
+	}
+	return nil
+}
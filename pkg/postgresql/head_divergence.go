@@ -0,0 +1,54 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the head divergence table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreateHeadDivergenceTable = `
+		CREATE TABLE IF NOT EXISTS t_head_divergence(
+			f_label TEXT,
+			f_client_name TEXT,
+			f_start_slot INT,
+			f_end_slot INT,
+			f_start TIMESTAMP,
+			f_end TIMESTAMP,
+			f_head_root TEXT,
+			f_majority_root TEXT,
+			CONSTRAINT PK_HeadDivergence PRIMARY KEY (f_label,f_start_slot));`
+
+	// windows are stored with no end while they last, the end is filled once they are over
+	InsertNewHeadDivergence = `
+		INSERT INTO t_head_divergence (
+			f_label,
+			f_client_name,
+			f_start_slot,
+			f_end_slot,
+			f_start,
+			f_end,
+			f_head_root,
+			f_majority_root)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (f_label, f_start_slot) DO UPDATE SET
+			f_end_slot = COALESCE(t_head_divergence.f_end_slot, EXCLUDED.f_end_slot),
+			f_end = COALESCE(t_head_divergence.f_end, EXCLUDED.f_end);`
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createHeadDivergenceTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	_, err := pool.Exec(ctx, CreateHeadDivergenceTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating head divergence table")
+	}
+	return nil
+}
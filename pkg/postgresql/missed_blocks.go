@@ -24,7 +24,8 @@ var (
 		INSERT INTO t_missed_blocks (	
 			f_slot, 
 			f_label)
-		VALUES ($1, $2);`
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING;`
 )
 
 // in case the table did not exist
@@ -0,0 +1,57 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the node_status table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreateNodeStatusTable = `
+		CREATE TABLE IF NOT EXISTS t_node_status(
+			f_label TEXT,
+			f_client_name TEXT,
+			f_timestamp TIMESTAMP,
+			f_head_slot INT,
+			f_sync_distance INT,
+			f_is_syncing BOOLEAN,
+			f_is_optimistic BOOLEAN,
+			f_el_offline BOOLEAN,
+			f_health_code INT,
+			f_peers_connected INT,
+			f_version TEXT,
+		CONSTRAINT PK_NodeStatus PRIMARY KEY (f_label,f_timestamp));`
+
+	InsertNewNodeStatus = `
+		INSERT INTO t_node_status (
+			f_label,
+			f_client_name,
+			f_timestamp,
+			f_head_slot,
+			f_sync_distance,
+			f_is_syncing,
+			f_is_optimistic,
+			f_el_offline,
+			f_health_code,
+			f_peers_connected,
+			f_version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT DO NOTHING;`
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createNodeStatusTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	_, err := pool.Exec(ctx, CreateNodeStatusTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating node status table")
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the node_versions table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreateNodeVersionsTable = `
+		CREATE TABLE IF NOT EXISTS t_node_versions(
+			f_label TEXT,
+			f_client_name TEXT,
+			f_client_version TEXT,
+			f_version TEXT,
+			f_timestamp TIMESTAMP,
+		CONSTRAINT PK_NodeVersion PRIMARY KEY (f_label,f_timestamp));`
+
+	InsertNewNodeVersion = `
+		INSERT INTO t_node_versions (
+			f_label,
+			f_client_name,
+			f_client_version,
+			f_version,
+			f_timestamp)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO NOTHING;`
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createNodeVersionsTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	_, err := pool.Exec(ctx, CreateNodeVersionsTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating node versions table")
+	}
+	return nil
+}
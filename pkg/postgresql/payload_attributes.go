@@ -0,0 +1,55 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the payload attributes events table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreatePayloadAttributesTable = `
+		CREATE TABLE IF NOT EXISTS t_payload_attributes_events(
+			f_label TEXT,
+			f_proposal_slot INT,
+			f_proposer_index BIGINT,
+			f_parent_block_root TEXT,
+			f_parent_block_hash TEXT,
+			f_parent_block_number BIGINT,
+			f_fee_recipient TEXT,
+			f_payload_timestamp BIGINT,
+			f_withdrawals INT,
+			f_timestamp TIMESTAMP,
+			CONSTRAINT PK_PayloadAttributes PRIMARY KEY (f_label,f_proposal_slot,f_parent_block_root));`
+
+	InsertNewPayloadAttributes = `
+		INSERT INTO t_payload_attributes_events (
+			f_label,
+			f_proposal_slot,
+			f_proposer_index,
+			f_parent_block_root,
+			f_parent_block_hash,
+			f_parent_block_number,
+			f_fee_recipient,
+			f_payload_timestamp,
+			f_withdrawals,
+			f_timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT DO NOTHING;`
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createPayloadAttributesTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	_, err := pool.Exec(ctx, CreatePayloadAttributesTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating payload attributes events table")
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the payload metrics table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreatePayloadMetricsTable = `
+		CREATE TABLE IF NOT EXISTS t_payload_metrics(
+			f_slot INT,
+			f_label TEXT,
+			f_block_number BIGINT,
+			f_block_hash TEXT,
+			f_tx_count INT,
+			f_gas_used BIGINT,
+			f_gas_limit BIGINT,
+			f_base_fee_wei BIGINT,
+			f_withdrawals INT,
+			f_blob_commitments INT,
+			CONSTRAINT PK_PayloadMetrics PRIMARY KEY (f_slot,f_label));`
+
+	InsertNewPayloadMetrics = `
+		INSERT INTO t_payload_metrics (
+			f_slot,
+			f_label,
+			f_block_number,
+			f_block_hash,
+			f_tx_count,
+			f_gas_used,
+			f_gas_limit,
+			f_base_fee_wei,
+			f_withdrawals,
+			f_blob_commitments)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT DO NOTHING;`
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createPayloadMetricsTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	_, err := pool.Exec(ctx, CreatePayloadMetricsTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating payload metrics table")
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package postgresql
+
+import (
+	"github.com/migalabs/streameth/pkg/exporter"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	modName    = "db"
+	modDetails = "metrics about the database write queues"
+
+	QueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "db",
+		Name:      "queue_depth",
+		Help:      "Records waiting in the write queue of each record type",
+	},
+		[]string{"type", "policy"},
+	)
+
+	QueueCapacity = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "db",
+		Name:      "queue_capacity",
+		Help:      "Capacity of the write queue of each record type",
+	},
+		[]string{"type", "policy"},
+	)
+
+	QueueReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "db",
+		Name:      "queue_received_total",
+		Help:      "Records received by the write queue of each record type",
+	},
+		[]string{"type", "policy"},
+	)
+
+	QueueDrops = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "db",
+		Name:      "queue_drops_total",
+		Help:      "Records discarded by the write queue of each record type, by reason",
+	},
+		[]string{"type", "policy", "reason"},
+	)
+
+	QueueSpilled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "db",
+		Name:      "queue_spilled_total",
+		Help:      "Records spilled to disk by the write queue of each record type",
+	},
+		[]string{"type", "policy"},
+	)
+
+	DeadLetterRecords = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "db",
+		Name:      "deadletter_records_total",
+		Help:      "Records rejected by the database and written to the dead-letter file",
+	})
+)
+
+func (p *PostgresDBService) GetPrometheusMetrics() *exporter.MetricsModule {
+	metricsMod := exporter.NewMetricsModule(
+		modName,
+		modDetails,
+	)
+	// compose all the metrics
+
+	metricsMod.AddIndvMetric(p.getQueueStats())
+
+	return metricsMod
+}
+
+func (p *PostgresDBService) getQueueStats() *exporter.IndvMetrics {
+
+	initFn := func() error {
+		prometheus.MustRegister(QueueDepth)
+		prometheus.MustRegister(QueueCapacity)
+		prometheus.MustRegister(QueueReceived)
+		prometheus.MustRegister(QueueDrops)
+		prometheus.MustRegister(QueueSpilled)
+		prometheus.MustRegister(DeadLetterRecords)
+		return nil
+	}
+
+	// the queues keep running totals, the counters are increased by the difference with the last update
+	previous := make(map[string]QueueStats)
+	var previousDeadLettered uint64
+	updateFn := func() (interface{}, error) {
+		pending := 0
+
+		for _, item := range p.QueueStats() {
+			last := previous[item.Type]
+			previous[item.Type] = item
+			labels := prometheus.Labels{
+				"type":   item.Type,
+				"policy": item.Policy,
+			}
+			QueueDepth.With(labels).Set(float64(item.Depth))
+			QueueCapacity.With(labels).Set(float64(item.Capacity))
+			QueueReceived.With(labels).Add(float64(item.Received - last.Received))
+			QueueSpilled.With(labels).Add(float64(item.Spilled - last.Spilled))
+			labels["reason"] = "overflow"
+			QueueDrops.With(labels).Add(float64(item.Dropped - last.Dropped))
+			labels["reason"] = "sampled"
+			QueueDrops.With(labels).Add(float64(item.Sampled - last.Sampled))
+			labels["reason"] = "spill_error"
+			QueueDrops.With(labels).Add(float64(item.Lost - last.Lost))
+
+			pending += item.Depth
+		}
+		deadLettered := p.DeadLettered()
+		DeadLetterRecords.Add(float64(deadLettered - previousDeadLettered))
+		previousDeadLettered = deadLettered
+		return pending, nil
+	}
+
+	indvMetr, err := exporter.NewIndvMetrics(
+		"queue_stats",
+		initFn,
+		updateFn,
+	)
+	if err != nil {
+		log.Error(errors.Wrap(err, "unable to init queue_stats"))
+		return nil
+	}
+
+	return indvMetr
+}
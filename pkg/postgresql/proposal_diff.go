@@ -0,0 +1,67 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the proposal diff table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreateProposalDiffTable = `
+		CREATE TABLE IF NOT EXISTS t_proposal_diff(
+			f_slot INT,
+			f_offset_ms INT,
+			f_label_a TEXT,
+			f_label_b TEXT,
+			f_client_a TEXT,
+			f_client_b TEXT,
+			f_common_atts INT,
+			f_unique_atts_a INT,
+			f_unique_atts_b INT,
+			f_overlapping_atts INT,
+			f_sync_bits_diff INT,
+			f_payload_hash_a TEXT,
+			f_payload_hash_b TEXT,
+			f_unique_slashings_a INT,
+			f_unique_slashings_b INT,
+			f_details JSONB,
+			CONSTRAINT PK_ProposalDiff PRIMARY KEY (f_slot,f_offset_ms,f_label_a,f_label_b));`
+
+	InsertNewProposalDiff = `
+		INSERT INTO t_proposal_diff (
+			f_slot,
+			f_offset_ms,
+			f_label_a,
+			f_label_b,
+			f_client_a,
+			f_client_b,
+			f_common_atts,
+			f_unique_atts_a,
+			f_unique_atts_b,
+			f_overlapping_atts,
+			f_sync_bits_diff,
+			f_payload_hash_a,
+			f_payload_hash_b,
+			f_unique_slashings_a,
+			f_unique_slashings_b,
+			f_details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		ON CONFLICT DO NOTHING;`
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createProposalDiffTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	_, err := pool.Exec(ctx, CreateProposalDiffTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating proposal diff table")
+	}
+	return nil
+}
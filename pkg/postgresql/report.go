@@ -0,0 +1,155 @@
+package postgresql
+
+/*
+
+This file has the read queries that aggregate the metrics tables for the client reports
+
+*/
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	SelectLastScoredSlot = `
+		SELECT COALESCE(MAX(f_slot), 0) FROM t_score_metrics;`
+
+	// win: best score among the nodes that scored a proposal for the same slot and offset
+	SelectScoreSummary = `
+		WITH ranked AS (
+			SELECT
+				f_label,
+				f_client_name,
+				f_score,
+				f_duration,
+				f_skip_reason,
+				CASE WHEN f_score IS NULL THEN NULL
+					ELSE RANK() OVER (PARTITION BY f_slot, f_offset_ms ORDER BY f_score DESC NULLS LAST) END AS f_rank,
+				COUNT(f_score) OVER (PARTITION BY f_slot, f_offset_ms) AS f_contenders
+			FROM t_score_metrics
+			WHERE f_slot >= $1 AND f_slot <= $2)
+		SELECT
+			f_label,
+			f_client_name,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE f_skip_reason IS NOT NULL),
+			COALESCE(AVG(f_score), 0),
+			COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY f_score), 0),
+			COALESCE(percentile_cont(0.9) WITHIN GROUP (ORDER BY f_score), 0),
+			COALESCE(AVG(f_duration) FILTER (WHERE f_skip_reason IS NULL), 0),
+			COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY f_duration) FILTER (WHERE f_skip_reason IS NULL), 0),
+			COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY f_duration) FILTER (WHERE f_skip_reason IS NULL), 0),
+			COUNT(*) FILTER (WHERE f_rank = 1 AND f_contenders > 1),
+			COUNT(*) FILTER (WHERE f_score IS NOT NULL AND f_contenders > 1)
+		FROM ranked
+		GROUP BY f_label, f_client_name
+		ORDER BY f_label, f_client_name;`
+
+	// the arrival is stored as the local time of the day
+	SelectBlockArrivals = `
+		SELECT f_slot, f_label, EXTRACT(EPOCH FROM f_timestamp)::FLOAT
+		FROM t_block_metrics
+		WHERE f_slot >= $1 AND f_slot <= $2;`
+
+	SelectMissedBlockCount = `
+		SELECT f_label, COUNT(*)
+		FROM t_missed_blocks
+		WHERE f_slot >= $1 AND f_slot <= $2
+		GROUP BY f_label;`
+)
+
+// ScoreSummary aggregates the proposals of a node in a slot range
+type ScoreSummary struct {
+	Label        string
+	ClientName   string
+	Proposals    int // scored or failed
+	Failures     int
+	MeanScore    float64
+	P50Score     float64
+	P90Score     float64
+	MeanDuration float64 // seconds to produce the proposal
+	P50Duration  float64
+	P95Duration  float64
+	Wins         int // best score at the slot
+	Contested    int // scored slots where other nodes scored too
+}
+
+type BlockArrival struct {
+	Slot      uint64
+	Label     string
+	DaySecond float64 // seconds since the local midnight
+}
+
+// LastScoredSlot returns the most recent slot in the score metrics, 0 if there is none
+func (p *PostgresDBService) LastScoredSlot(ctx context.Context) (uint64, error) {
+	var slot int64
+	err := p.psqlPool.QueryRow(ctx, SelectLastScoredSlot).Scan(&slot)
+	if err != nil {
+		return 0, errors.Wrap(err, "error reading last scored slot")
+	}
+	return uint64(slot), nil
+}
+
+func (p *PostgresDBService) ScoreSummaries(ctx context.Context, fromSlot uint64, toSlot uint64) ([]ScoreSummary, error) {
+	rows, err := p.psqlPool.Query(ctx, SelectScoreSummary, fromSlot, toSlot)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading score summary")
+	}
+	defer rows.Close()
+
+	summaries := make([]ScoreSummary, 0)
+	for rows.Next() {
+		item := ScoreSummary{}
+		err := rows.Scan(&item.Label, &item.ClientName, &item.Proposals, &item.Failures,
+			&item.MeanScore, &item.P50Score, &item.P90Score,
+			&item.MeanDuration, &item.P50Duration, &item.P95Duration,
+			&item.Wins, &item.Contested)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading score summary row")
+		}
+		summaries = append(summaries, item)
+	}
+	return summaries, errors.Wrap(rows.Err(), "error reading score summary")
+}
+
+func (p *PostgresDBService) BlockArrivals(ctx context.Context, fromSlot uint64, toSlot uint64) ([]BlockArrival, error) {
+	rows, err := p.psqlPool.Query(ctx, SelectBlockArrivals, fromSlot, toSlot)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading block arrivals")
+	}
+	defer rows.Close()
+
+	arrivals := make([]BlockArrival, 0)
+	for rows.Next() {
+		item := BlockArrival{}
+		var slot int64
+		if err := rows.Scan(&slot, &item.Label, &item.DaySecond); err != nil {
+			return nil, errors.Wrap(err, "error reading block arrival row")
+		}
+		item.Slot = uint64(slot)
+		arrivals = append(arrivals, item)
+	}
+	return arrivals, errors.Wrap(rows.Err(), "error reading block arrivals")
+}
+
+// MissedBlockCounts returns the missed slots seen by each label
+func (p *PostgresDBService) MissedBlockCounts(ctx context.Context, fromSlot uint64, toSlot uint64) (map[string]int, error) {
+	rows, err := p.psqlPool.Query(ctx, SelectMissedBlockCount, fromSlot, toSlot)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading missed blocks")
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var label string
+		var count int
+		if err := rows.Scan(&label, &count); err != nil {
+			return nil, errors.Wrap(err, "error reading missed blocks row")
+		}
+		counts[label] = count
+	}
+	return counts, errors.Wrap(rows.Err(), "error reading missed blocks")
+}
@@ -0,0 +1,340 @@
+package postgresql
+
+/*
+
+This file has the methods to partition the metrics tables by slot range and to drop or archive old data
+
+*/
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/migalabs/streameth/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+const (
+	RetentionDrop   = "drop"   // old partitions (or rows) are removed
+	RetentionDetach = "detach" // old partitions are detached and kept as standalone tables
+
+	RetentionInterval = 1 * time.Hour
+	PartitionsAhead   = 2 // partitions created in advance of the current slot
+)
+
+var (
+	CreateEpochAttSummaryTable = `
+		CREATE TABLE IF NOT EXISTS t_epoch_att_summary(
+			f_label TEXT,
+			f_epoch INT,
+			f_attestations INT,
+			f_committees INT,
+			f_first_timestamp TIMESTAMP,
+			f_last_timestamp TIMESTAMP,
+		CONSTRAINT PK_EpochAttSummary PRIMARY KEY (f_label,f_epoch));`
+
+	CreateEpochBlockSummaryTable = `
+		CREATE TABLE IF NOT EXISTS t_epoch_block_summary(
+			f_label TEXT,
+			f_epoch INT,
+			f_blocks INT,
+		CONSTRAINT PK_EpochBlockSummary PRIMARY KEY (f_label,f_epoch));`
+
+	CreateEpochScoreSummaryTable = `
+		CREATE TABLE IF NOT EXISTS t_epoch_score_summary(
+			f_label TEXT,
+			f_client_name TEXT,
+			f_epoch INT,
+			f_proposals INT,
+			f_skipped INT,
+			f_avg_score FLOAT,
+			f_max_score FLOAT,
+			f_avg_duration FLOAT,
+			f_avg_new_votes FLOAT,
+		CONSTRAINT PK_EpochScoreSummary PRIMARY KEY (f_label,f_client_name,f_epoch));`
+
+	// $1 and $2 are the slot range [from, to) to aggregate
+	DownsampleAttMetrics = `
+		INSERT INTO t_epoch_att_summary
+		SELECT
+			f_label,
+			f_slot / 32 AS f_epoch,
+			COUNT(*),
+			COUNT(DISTINCT (f_slot, f_committee_index)),
+			MIN(f_timestamp),
+			MAX(f_timestamp)
+		FROM t_att_metrics
+		WHERE f_slot >= $1 AND f_slot < $2
+		GROUP BY f_label, f_slot / 32
+		ON CONFLICT DO NOTHING;`
+
+	DownsampleBlockMetrics = `
+		INSERT INTO t_epoch_block_summary
+		SELECT
+			f_label,
+			f_slot / 32 AS f_epoch,
+			COUNT(*)
+		FROM t_block_metrics
+		WHERE f_slot >= $1 AND f_slot < $2
+		GROUP BY f_label, f_slot / 32
+		ON CONFLICT DO NOTHING;`
+
+	DownsampleScoreMetrics = `
+		INSERT INTO t_epoch_score_summary
+		SELECT
+			f_label,
+			COALESCE(f_client_name, ''),
+			f_slot / 32 AS f_epoch,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE f_skip_reason IS NOT NULL),
+			AVG(f_score),
+			MAX(f_score),
+			AVG(f_duration),
+			AVG(f_new_votes)
+		FROM t_score_metrics
+		WHERE f_slot >= $1 AND f_slot < $2
+		GROUP BY f_label, COALESCE(f_client_name, ''), f_slot / 32
+		ON CONFLICT DO NOTHING;`
+)
+
+// tables that are partitioned by slot and subject to retention
+type retentionTable struct {
+	name            string
+	downsampleQuery string // empty if the table is not downsampled
+}
+
+var retentionTables = []retentionTable{
+	{name: "t_att_metrics", downsampleQuery: DownsampleAttMetrics},
+	{name: "t_block_metrics", downsampleQuery: DownsampleBlockMetrics},
+	{name: "t_score_metrics", downsampleQuery: DownsampleScoreMetrics},
+	// events stored per node and slot
+	{name: "t_block_events"},
+	{name: "t_blob_sidecar_events"},
+}
+
+// in case the table did not exist
+func (p *PostgresDBService) createEpochSummaryTables(ctx context.Context, pool *pgxpool.Pool) error {
+	for _, query := range []string{CreateEpochAttSummaryTable, CreateEpochBlockSummaryTable, CreateEpochScoreSummaryTable} {
+		_, err := pool.Exec(ctx, query)
+		if err != nil {
+			return errors.Wrap(err, "error creating epoch summary table")
+		}
+	}
+	return nil
+}
+
+// create the table partitioned by slot range if partitioning is enabled
+// existing tables are not converted
+func (p *PostgresDBService) createTable(ctx context.Context, pool *pgxpool.Pool, createQuery string) error {
+	if p.partitionSlots > 0 {
+		createQuery = strings.TrimSuffix(strings.TrimSpace(createQuery), ";") + " PARTITION BY RANGE (f_slot);"
+	}
+	_, err := pool.Exec(ctx, createQuery)
+	return err
+}
+
+func (p *PostgresDBService) isPartitioned(table string) (bool, error) {
+	var relkind string
+	err := p.psqlPool.QueryRow(p.ctx, `SELECT relkind::TEXT FROM pg_class WHERE relname = $1;`, table).Scan(&relkind)
+	if err != nil {
+		return false, errors.Wrapf(err, "could not check table %s", table)
+	}
+	return relkind == "p", nil
+}
+
+type partition struct {
+	name     string
+	fromSlot uint64
+	toSlot   uint64
+}
+
+func (p *PostgresDBService) listPartitions(table string) ([]partition, error) {
+	rows, err := p.psqlPool.Query(p.ctx, `
+		SELECT c.relname, pg_get_expr(c.relpartbound, c.oid)
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class t ON t.oid = i.inhparent
+		WHERE t.relname = $1;`, table)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list partitions of %s", table)
+	}
+	defer rows.Close()
+
+	partitions := make([]partition, 0)
+	for rows.Next() {
+		var name, bound string
+		if err := rows.Scan(&name, &bound); err != nil {
+			return nil, err
+		}
+		item := partition{name: name}
+		_, err := fmt.Sscanf(bound, "FOR VALUES FROM (%d) TO (%d)", &item.fromSlot, &item.toSlot)
+		if err != nil {
+			log.Warnf("skipping partition %s with unknown bounds: %s", name, bound)
+			continue
+		}
+		partitions = append(partitions, item)
+	}
+	return partitions, rows.Err()
+}
+
+// CheckPartitionSlots rejects partition sizes that split epochs, as epochs are downsampled one partition at a time
+func CheckPartitionSlots(partitionSlots uint64) error {
+	if partitionSlots%utils.SlotsPerEpoch != 0 {
+		return fmt.Errorf("partition-slots must be a multiple of %d (slots per epoch): %d", utils.SlotsPerEpoch, partitionSlots)
+	}
+	return nil
+}
+
+// partitionStarts returns the first slot of the partitions holding the slot range [fromSlot, toSlot]
+func partitionStarts(fromSlot uint64, toSlot uint64, partitionSlots uint64) []uint64 {
+	starts := make([]uint64, 0)
+	if partitionSlots == 0 {
+		return starts
+	}
+	for start := fromSlot - fromSlot%partitionSlots; start <= toSlot; start += partitionSlots {
+		starts = append(starts, start)
+	}
+	return starts
+}
+
+// retentionCutoff returns the first slot kept, false if nothing is old enough to be removed.
+// The cutoff is rounded down to an epoch, so only full epochs are downsampled
+func retentionCutoff(currentSlot uint64, retentionSlots uint64) (uint64, bool) {
+	if retentionSlots == 0 || currentSlot <= retentionSlots {
+		return 0, false
+	}
+	cutoff := currentSlot - retentionSlots
+	cutoff -= cutoff % utils.SlotsPerEpoch
+	return cutoff, cutoff > 0
+}
+
+// expiredPartitions returns the partitions with every slot before the cutoff
+func expiredPartitions(partitions []partition, cutoff uint64) []partition {
+	expired := make([]partition, 0)
+	for _, item := range partitions {
+		if item.toSlot <= cutoff {
+			expired = append(expired, item)
+		}
+	}
+	return expired
+}
+
+// EnsurePartitions creates the partitions needed to store the given slot range
+func (p *PostgresDBService) EnsurePartitions(fromSlot uint64, toSlot uint64) error {
+	if p.partitionSlots == 0 {
+		return nil
+	}
+	for _, table := range retentionTables {
+		partitioned, err := p.isPartitioned(table.name)
+		if err != nil {
+			return err
+		}
+		if !partitioned {
+			continue
+		}
+		for _, start := range partitionStarts(fromSlot, toSlot, p.partitionSlots) {
+			_, err := p.psqlPool.Exec(p.ctx, fmt.Sprintf(
+				`CREATE TABLE IF NOT EXISTS %s_p%d PARTITION OF %s FOR VALUES FROM (%d) TO (%d);`,
+				table.name, start, table.name, start, start+p.partitionSlots))
+			if err != nil {
+				return errors.Wrapf(err, "could not create partition of %s at slot %d", table.name, start)
+			}
+		}
+	}
+	return nil
+}
+
+// downsampleAndRemove runs the downsample (if any) and the removal in a single transaction,
+// so the aggregates are only kept if the data is removed, and the other way around
+func (p *PostgresDBService) downsampleAndRemove(downsampleQuery string, fromSlot uint64, toSlot uint64, removeQuery string, removeParams ...interface{}) (int64, error) {
+	tx, err := p.psqlPool.Begin(p.ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not begin retention transaction")
+	}
+	defer tx.Rollback(p.ctx)
+
+	if downsampleQuery != "" {
+		if _, err := tx.Exec(p.ctx, downsampleQuery, fromSlot, toSlot); err != nil {
+			return 0, errors.Wrap(err, "could not downsample")
+		}
+	}
+	tag, err := tx.Exec(p.ctx, removeQuery, removeParams...)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), tx.Commit(p.ctx)
+}
+
+// ApplyRetention removes (or detaches) the data older than the retention window,
+// downsampling it to per epoch aggregates first if enabled
+func (p *PostgresDBService) ApplyRetention(currentSlot uint64, retentionSlots uint64, mode string, downsample bool) error {
+	cutoff, ok := retentionCutoff(currentSlot, retentionSlots)
+	if !ok {
+		return nil
+	}
+
+	for _, table := range retentionTables {
+		partitioned, err := p.isPartitioned(table.name)
+		if err != nil {
+			return err
+		}
+		downsampleQuery := ""
+		if downsample {
+			downsampleQuery = table.downsampleQuery
+		}
+
+		if !partitioned {
+			// rows are deleted, there is nothing to archive
+			deleted, err := p.downsampleAndRemove(downsampleQuery, 0, cutoff,
+				fmt.Sprintf(`DELETE FROM %s WHERE f_slot < $1;`, table.name), cutoff)
+			if err != nil {
+				return errors.Wrapf(err, "could not apply retention to %s", table.name)
+			}
+			log.Infof("retention: deleted %d rows older than slot %d from %s", deleted, cutoff, table.name)
+			continue
+		}
+
+		partitions, err := p.listPartitions(table.name)
+		if err != nil {
+			return err
+		}
+		for _, item := range expiredPartitions(partitions, cutoff) {
+			query := fmt.Sprintf(`DROP TABLE %s;`, item.name)
+			if mode == RetentionDetach {
+				query = fmt.Sprintf(`ALTER TABLE %s DETACH PARTITION %s;`, table.name, item.name)
+			}
+			if _, err := p.downsampleAndRemove(downsampleQuery, item.fromSlot, item.toSlot, query); err != nil {
+				return errors.Wrapf(err, "could not %s partition %s", mode, item.name)
+			}
+			log.Infof("retention: %s partition %s (slots %d-%d)", mode, item.name, item.fromSlot, item.toSlot)
+		}
+	}
+	return nil
+}
+
+// RunRetention keeps partitions ahead of the current slot and applies the retention periodically
+func (p *PostgresDBService) RunRetention(retentionSlots uint64, mode string, downsample bool, currentSlotFn func() uint64) {
+	ticker := p.clock.NewTicker(RetentionInterval)
+	defer ticker.Stop()
+
+	for {
+		currentSlot := currentSlotFn()
+		err := p.EnsurePartitions(currentSlot, currentSlot+PartitionsAhead*p.partitionSlots)
+		if err != nil {
+			log.Errorf("could not create partitions: %s", err)
+		}
+		err = p.ApplyRetention(currentSlot, retentionSlots, mode, downsample)
+		if err != nil {
+			log.Errorf("could not apply retention: %s", err)
+		}
+
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.Chan():
+		}
+	}
+}
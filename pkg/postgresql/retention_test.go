@@ -0,0 +1,45 @@
+package postgresql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPartitionSlots(t *testing.T) {
+	assert.Nil(t, CheckPartitionSlots(0))
+	assert.Nil(t, CheckPartitionSlots(7200))
+	assert.Nil(t, CheckPartitionSlots(32))
+	assert.NotNil(t, CheckPartitionSlots(100)) // epoch 3 would span two partitions
+	assert.NotNil(t, CheckPartitionSlots(16))
+}
+
+func TestPartitionStarts(t *testing.T) {
+	// the partition of the first slot is included, even if it starts before it
+	assert.Equal(t, []uint64{6400, 9600, 12800}, partitionStarts(7000, 7000+2*3200, 3200))
+	assert.Equal(t, []uint64{0}, partitionStarts(0, 3199, 3200))
+	assert.Equal(t, []uint64{0, 3200}, partitionStarts(0, 3200, 3200))
+	assert.Empty(t, partitionStarts(0, 3200, 0))
+}
+
+func TestRetentionCutoff(t *testing.T) {
+	_, ok := retentionCutoff(1000, 0) // retention disabled
+	assert.False(t, ok)
+	_, ok = retentionCutoff(1000, 1000)
+	assert.False(t, ok)
+	_, ok = retentionCutoff(1020, 1000) // less than an epoch to remove
+	assert.False(t, ok)
+
+	cutoff, ok := retentionCutoff(10000, 1000)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(8992), cutoff) // 9000 rounded down to the start of its epoch
+
+	partitions := []partition{
+		{name: "t_att_metrics_p0", fromSlot: 0, toSlot: 3200},
+		{name: "t_att_metrics_p3200", fromSlot: 3200, toSlot: 6400},
+		{name: "t_att_metrics_p6400", fromSlot: 6400, toSlot: 9600},
+	}
+	assert.Equal(t, partitions[:2], expiredPartitions(partitions, cutoff))
+	assert.Equal(t, partitions, expiredPartitions(partitions, 9600))
+	assert.Empty(t, expiredPartitions(partitions, 3199))
+}
@@ -9,8 +9,13 @@ import (
 
 	pgx_v4 "github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/migalabs/streameth/pkg/clock"
+	"github.com/migalabs/streameth/pkg/tracing"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Static postgres queries, for each modification in the tables, the table needs to be reseted
@@ -21,7 +26,7 @@ var (
 		"module", PsqlType,
 	)
 	MAX_BATCH_QUEUE   = 100
-	WRITE_CHAN_LENGTH = 400000
+	WRITE_CHAN_LENGTH = 1000 // per writer, records wait in the queue of their type
 )
 
 type PostgresDBService struct {
@@ -31,17 +36,28 @@ type PostgresDBService struct {
 	connectionUrl    string // the url might not be necessary (better to remove it?¿)
 	psqlPool         *pgxpool.Pool
 	WriteChan        chan WriteTask
+	queues           map[string]*writeQueue // record type -> queue in front of the WriteChan
+	deadLetterPath   string                 // where to keep the records the database rejects
+	deadLetterMu     sync.Mutex
+	deadLettered     uint64
 	doneTasks        chan interface{}
 	endProcess       int32
 	FinishSignalChan chan struct{}
 	workerNum        int
 	maxBatchQueue    int
+	partitionSlots   uint64                                             // slots per partition of the metrics tables, 0 to disable partitioning
+	executeBatch     func(ctx context.Context, tasks []WriteTask) error // ExecuteBatch, replaced in the tests
+	readOnly         bool                                               // connected to read the metrics, there are no writers
+	clock            clock.Clock
 	WgDBWriter       sync.WaitGroup
 }
 
 // Connect to the PostgreSQL Database and get the multithread-proof connection
 // from the given url-composed credentials
-func ConnectToDB(ctx context.Context, url string, workerNum int, batchLen int) (*PostgresDBService, error) {
+func ConnectToDB(ctx context.Context, url string, workerNum int, batchLen int, partitionSlots uint64, queueConf QueueConfig) (*PostgresDBService, error) {
+	if err := CheckPartitionSlots(partitionSlots); err != nil {
+		return nil, err
+	}
 	mainCtx, cancel := context.WithCancel(ctx)
 	// spliting the url to don't share any confidential information on logs
 
@@ -50,6 +66,7 @@ func ConnectToDB(ctx context.Context, url string, workerNum int, batchLen int) (
 	}
 	psqlPool, err := pgxpool.Connect(mainCtx, url)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 	if strings.Contains(url, "@") {
@@ -68,17 +85,55 @@ func ConnectToDB(ctx context.Context, url string, workerNum int, batchLen int) (
 		FinishSignalChan: make(chan struct{}, 1),
 		workerNum:        workerNum,
 		maxBatchQueue:    batchLen,
+		partitionSlots:   partitionSlots,
 		WgDBWriter:       sync.WaitGroup{},
+		queues:           make(map[string]*writeQueue),
+		deadLetterPath:   queueConf.DeadLetterFile,
+		clock:            clock.OrReal(queueConf.Clock),
+	}
+	psqlDB.executeBatch = psqlDB.ExecuteBatch
+	for _, recordType := range RecordTypes {
+		psqlDB.queues[recordType] = newWriteQueue(recordType, queueConf.Policies[recordType], queueConf.Size, queueConf.SpillDir)
 	}
 	// init the psql db
 	err = psqlDB.init(ctx, psqlDB.psqlPool)
 	if err != nil {
 		return psqlDB, errors.Wrap(err, "error initializing the tables of the psqldb")
 	}
+	for _, q := range psqlDB.queues {
+		go psqlDB.forward(q)
+		go q.drainSpill(mainCtx, psqlDB.clock)
+	}
 	go psqlDB.runWriters()
 	return psqlDB, err
 }
 
+// ConnectReadOnly opens a connection to read the metrics, for the reports. The tables are not created
+// or migrated, no writer is started, and the sessions are read only so nothing can be changed by mistake
+func ConnectReadOnly(ctx context.Context, url string) (*PostgresDBService, error) {
+	poolConfig, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, err
+	}
+	poolConfig.ConnConfig.RuntimeParams["default_transaction_read_only"] = "on"
+
+	mainCtx, cancel := context.WithCancel(ctx)
+	psqlPool, err := pgxpool.ConnectConfig(mainCtx, poolConfig)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &PostgresDBService{
+		ctx:           mainCtx,
+		cancel:        cancel,
+		connectionUrl: url,
+		psqlPool:      psqlPool,
+		queues:        make(map[string]*writeQueue),
+		readOnly:      true,
+		clock:         clock.Real{},
+	}, nil
+}
+
 func (p *PostgresDBService) init(ctx context.Context, pool *pgxpool.Pool) error {
 	// create the tables
 	err := p.createScoreMetricsTable(ctx, pool)
@@ -106,6 +161,111 @@ func (p *PostgresDBService) init(ctx context.Context, pool *pgxpool.Pool) error
 		return err
 	}
 
+	err = p.createNodeStatusTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createNodeVersionsTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createEpochSummaryTables(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createCanonicalScoreTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createAttInclusionTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createPayloadMetricsTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createBlockEventsTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createBlobSidecarTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createFinalizedCheckpointTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createVoluntaryExitTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createBLSChangeTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createSyncContributionTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createPayloadAttributesTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createSlashingEventsTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createFinalityTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createHeadDivergenceTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createWatchAttArrivalTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createWatchAttestationsTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createWatchProposerDutiesTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createProposalDiffTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	err = p.createClockOffsetsTable(ctx, pool)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -122,13 +282,41 @@ func (p *PostgresDBService) runWriters() {
 		go func(dbWriterID int) {
 			defer p.WgDBWriter.Done()
 			wlogWriter := log.WithField("DBWriter", dbWriterID)
-			writeBatch := pgx_v4.Batch{}
+			writeBatch := make([]WriteTask, 0, p.maxBatchQueue)
+			batchLinks := make([]trace.Link, 0) // spans of the tasks in the batch
+			copyTasks := make(map[*CopyTarget][]WriteTask)
+			copyLinks := make([]trace.Link, 0)
+			pendingCopyRows := 0
+			flushCopies := func() {
+				ctx, span := tracing.Tracer().Start(p.ctx, "ExecuteCopy",
+					trace.WithLinks(copyLinks...),
+					trace.WithAttributes(attribute.Int("rows", pendingCopyRows)))
+				for target, tasks := range copyTasks {
+					rows := make([][]interface{}, 0, len(tasks))
+					for _, task := range tasks {
+						rows = append(rows, task.Params)
+					}
+					err := p.ExecuteCopy(ctx, target, rows)
+					if err != nil {
+						// a single bad row aborts the copy, isolate it with the statements
+						wlogWriter.Warnf("Error copying rows, falling back to inserts: %s", err.Error())
+						span.RecordError(err)
+						if rejected := p.WriteTasks(ctx, tasks); rejected > 0 {
+							span.SetStatus(codes.Error, err.Error())
+						}
+					}
+				}
+				span.End()
+				copyTasks = make(map[*CopyTarget][]WriteTask)
+				copyLinks = make([]trace.Link, 0)
+				pendingCopyRows = 0
+			}
 			// tick every slot start (12 seconds)
-			ticker := time.NewTicker(15 * time.Second)
+			ticker := p.clock.NewTicker(15 * time.Second)
 		loop:
 			for {
 
-				if p.endProcess >= 1 && len(p.WriteChan) == 0 {
+				if p.endProcess >= 1 && p.pendingTasks() == 0 {
 					wlogWriter.Warnf("finish detected, closing persister")
 					break loop
 				}
@@ -136,30 +324,62 @@ func (p *PostgresDBService) runWriters() {
 				select {
 				case task := <-p.WriteChan:
 					wlogWriter.Tracef("Received new write task")
-					writeBatch.Queue(task.QueryString, task.Params...)
+					if task.CopyTarget != nil {
+						// append only records are bulk loaded
+						copyTasks[task.CopyTarget] = append(copyTasks[task.CopyTarget], task)
+						if task.spanCtx.IsValid() {
+							copyLinks = append(copyLinks, trace.Link{SpanContext: task.spanCtx})
+						}
+						pendingCopyRows++
+						if pendingCopyRows >= COPY_BATCH_LEN {
+							flushCopies()
+						}
+						continue
+					}
+					writeBatch = append(writeBatch, task)
+					if task.spanCtx.IsValid() {
+						batchLinks = append(batchLinks, trace.Link{SpanContext: task.spanCtx})
+					}
 
-					if writeBatch.Len() >= p.maxBatchQueue {
+					if len(writeBatch) >= p.maxBatchQueue {
 						wlogWriter.Tracef("Writing batch to database")
-						err := p.ExecuteBatch(writeBatch)
-						if err != nil {
-							wlogWriter.Errorf("Error processing batch", err.Error())
+						ctx, span := tracing.Tracer().Start(p.ctx, "WriteTasks",
+							trace.WithLinks(batchLinks...),
+							trace.WithAttributes(attribute.Int("batch_size", len(writeBatch))))
+						if rejected := p.WriteTasks(ctx, writeBatch); rejected > 0 {
+							wlogWriter.Errorf("%d records of the batch were rejected", rejected)
+							span.SetStatus(codes.Error, "rejected records")
 						}
-						writeBatch = pgx_v4.Batch{}
+						span.End()
+						writeBatch = make([]WriteTask, 0, p.maxBatchQueue)
+						batchLinks = make([]trace.Link, 0)
 					} else {
-						log.Tracef("%d pending tasks to persist", p.maxBatchQueue-writeBatch.Len())
+						log.Tracef("%d pending tasks to persist", p.maxBatchQueue-len(writeBatch))
 					}
 
 				case <-p.ctx.Done():
 					wlogWriter.Info("shutdown detected, closing persister")
 					break loop
 
-				case <-ticker.C:
-					if p.endProcess >= 1 && len(p.WriteChan) == 0 {
+				case <-ticker.Chan():
+					if pendingCopyRows > 0 {
+						flushCopies()
+					}
+					if p.endProcess >= 1 && p.pendingTasks() == 0 {
 						wlogWriter.Warnf("finish detected, closing persister")
 						break loop
 					}
 				}
 			}
+			if p.ctx.Err() == nil {
+				// finishing, write what is left
+				if pendingCopyRows > 0 {
+					flushCopies()
+				}
+				if len(writeBatch) > 0 {
+					p.WriteTasks(p.ctx, writeBatch)
+				}
+			}
 			wlogWriter.Debugf("DB Writer finished...")
 
 		}(i)
@@ -170,37 +390,75 @@ func (p *PostgresDBService) runWriters() {
 
 }
 
-func (p PostgresDBService) Close() {
+func (p *PostgresDBService) Close() {
 	p.psqlPool.Close()
+	if p.readOnly {
+		p.cancel()
+	}
 }
 
 type WriteTask struct {
 	QueryString string
 	Params      []interface{}
+	CopyTarget  *CopyTarget       // if set, Params is a row bulk loaded into the target instead of running the query
+	Type        string            // record type, selects the queue and its overflow policy
+	spanCtx     trace.SpanContext // span of the enqueue, linked from the batch that writes it
 }
 
-func (p PostgresDBService) ExecuteBatch(batch pgx_v4.Batch) error {
+// Persist queues the task to be written by the DB writers,
+// applying the overflow policy of its record type if the queue is full
+func (p *PostgresDBService) Persist(ctx context.Context, task WriteTask) {
+	if p.readOnly {
+		log.Errorf("read only database connection, %s record not written", task.Type)
+		return
+	}
+	if task.Type == "" {
+		task.Type = RecordDefault
+	}
+	q, ok := p.queues[task.Type]
+	if !ok {
+		log.Warnf("unknown record type %s, using the default queue", task.Type)
+		q = p.queues[RecordDefault]
+	}
+
+	_, span := tracing.Tracer().Start(ctx, "WriteChan enqueue", trace.WithAttributes(
+		attribute.String("record_type", q.recordType)))
+	defer span.End()
+
+	task.spanCtx = span.SpanContext()
+	q.push(ctx, task)
+}
+
+// ExecuteBatch runs the statements of the tasks in a single transaction
+func (p *PostgresDBService) ExecuteBatch(ctx context.Context, tasks []WriteTask) error {
 
 	snapshot := time.Now()
-	tx, err := p.psqlPool.Begin(p.ctx)
+	tx, err := p.psqlPool.Begin(ctx)
 	if err != nil {
-		panic(err)
+		return errors.Wrap(err, "could not begin transaction")
 	}
+	defer tx.Rollback(ctx)
 
-	batchResults := tx.SendBatch(p.ctx, &batch)
+	batch := pgx_v4.Batch{}
+	for _, task := range tasks {
+		batch.Queue(task.QueryString, task.Params...)
+	}
+	batchResults := tx.SendBatch(ctx, &batch)
 
-	var qerr error
-	var rows pgx_v4.Rows
-	for qerr == nil {
-		rows, qerr = batchResults.Query()
-		rows.Close()
+	for i := range tasks {
+		if _, err := batchResults.Exec(); err != nil {
+			batchResults.Close()
+			return errors.Wrapf(err, "statement %d of the batch failed", i)
+		}
 	}
-	if qerr.Error() != "no result" {
-		log.Errorf(qerr.Error())
+	if err := batchResults.Close(); err != nil {
+		return errors.Wrap(err, "could not close batch results")
 	}
 
-	log.Tracef("Batch process time: %f, batch size: %d", time.Since(snapshot).Seconds(), batch.Len())
+	log.Tracef("Batch process time: %f, batch size: %d", time.Since(snapshot).Seconds(), len(tasks))
 
-	return tx.Commit(p.ctx)
+	ctx, span := tracing.Tracer().Start(ctx, "Commit")
+	defer span.End()
+	return tx.Commit(ctx)
 
 }
@@ -0,0 +1,45 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the attester and proposer slashing events table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreateSlashingEventTable = `
+		CREATE TABLE IF NOT EXISTS t_slashing_events(
+			f_label TEXT,
+			f_type TEXT,
+			f_slot INT,
+			f_validator_indices TEXT,
+			f_timestamp TIMESTAMP,
+			CONSTRAINT PK_SlashingEvent PRIMARY KEY (f_label,f_type,f_slot,f_validator_indices));`
+
+	InsertNewSlashingEvent = `
+		INSERT INTO t_slashing_events (
+			f_label,
+			f_type,
+			f_slot,
+			f_validator_indices,
+			f_timestamp)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO NOTHING;`
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createSlashingEventsTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	_, err := pool.Exec(ctx, CreateSlashingEventTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating slashing events table")
+	}
+	return nil
+}
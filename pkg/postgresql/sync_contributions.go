@@ -0,0 +1,49 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the sync committee contribution events table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreateSyncContributionTable = `
+		CREATE TABLE IF NOT EXISTS t_sync_contribution_events(
+			f_label TEXT,
+			f_slot INT,
+			f_subcommittee_index INT,
+			f_aggregator_index BIGINT,
+			f_block_root TEXT,
+			f_participants INT,
+			f_timestamp TIMESTAMP,
+			CONSTRAINT PK_SyncContribution PRIMARY KEY (f_label,f_slot,f_subcommittee_index,f_aggregator_index));`
+
+	InsertNewSyncContribution = `
+		INSERT INTO t_sync_contribution_events (
+			f_label,
+			f_slot,
+			f_subcommittee_index,
+			f_aggregator_index,
+			f_block_root,
+			f_participants,
+			f_timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT DO NOTHING;`
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createSyncContributionTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	_, err := pool.Exec(ctx, CreateSyncContributionTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating sync contribution events table")
+	}
+	return nil
+}
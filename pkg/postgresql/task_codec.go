@@ -0,0 +1,175 @@
+package postgresql
+
+/*
+
+This file has the methods to encode write tasks as json lines, so they can be stored on disk and written later
+
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// types of the params that can be encoded
+const (
+	paramNil    = "nil"
+	paramBool   = "bool"
+	paramInt    = "int"
+	paramUint   = "uint"
+	paramFloat  = "float"
+	paramString = "string"
+	paramTime   = "time"
+	paramBytes  = "bytes"
+)
+
+type encodedTask struct {
+	Type      string         `json:"type"`
+	Query     string         `json:"query"`
+	CopyTable string         `json:"copy_table,omitempty"`
+	Params    []encodedParam `json:"params"`
+}
+
+type encodedParam struct {
+	T string          `json:"t"`
+	V json.RawMessage `json:"v,omitempty"`
+}
+
+// copy targets by table, to restore them from disk
+var copyTargets = map[string]*CopyTarget{
+	AttCopyTarget.Table:          AttCopyTarget,
+	BlockCopyTarget.Table:        BlockCopyTarget,
+	MissedBlockCopyTarget.Table:  MissedBlockCopyTarget,
+	ReorgCopyTarget.Table:        ReorgCopyTarget,
+	AttInclusionCopyTarget.Table: AttInclusionCopyTarget,
+}
+
+// EncodeTask serializes the task keeping the type of each param
+func EncodeTask(task WriteTask) ([]byte, error) {
+	encoded := encodedTask{
+		Type:   task.Type,
+		Query:  task.QueryString,
+		Params: make([]encodedParam, 0, len(task.Params)),
+	}
+	if task.CopyTarget != nil {
+		encoded.CopyTable = task.CopyTarget.Table
+	}
+
+	for i, param := range task.Params {
+		item, err := encodeParam(param)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode param %d: %s", i, err)
+		}
+		encoded.Params = append(encoded.Params, item)
+	}
+	return json.Marshal(encoded)
+}
+
+func encodeParam(param interface{}) (encodedParam, error) {
+	if param == nil {
+		return encodedParam{T: paramNil}, nil
+	}
+
+	var t string
+	var v interface{}
+	switch value := param.(type) {
+	case time.Time:
+		t, v = paramTime, value.Format(time.RFC3339Nano)
+	case []byte:
+		t, v = paramBytes, value // base64 by encoding/json
+	default:
+		rv := reflect.ValueOf(param)
+		switch rv.Kind() {
+		case reflect.Bool:
+			t, v = paramBool, rv.Bool()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			t, v = paramInt, rv.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			t, v = paramUint, rv.Uint()
+		case reflect.Float32, reflect.Float64:
+			t, v = paramFloat, rv.Float()
+		case reflect.String:
+			t, v = paramString, rv.String()
+		default:
+			return encodedParam{}, fmt.Errorf("unsupported type %T", param)
+		}
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return encodedParam{}, err
+	}
+	return encodedParam{T: t, V: raw}, nil
+}
+
+// DecodeTask restores a task serialized with EncodeTask
+func DecodeTask(content []byte) (WriteTask, error) {
+	encoded := encodedTask{}
+	if err := json.Unmarshal(content, &encoded); err != nil {
+		return WriteTask{}, fmt.Errorf("could not decode task: %s", err)
+	}
+
+	task := WriteTask{
+		Type:        encoded.Type,
+		QueryString: encoded.Query,
+		Params:      make([]interface{}, 0, len(encoded.Params)),
+	}
+	if encoded.CopyTable != "" {
+		target, ok := copyTargets[encoded.CopyTable]
+		if !ok {
+			return WriteTask{}, fmt.Errorf("unknown copy table: %s", encoded.CopyTable)
+		}
+		task.CopyTarget = target
+	}
+
+	for i, item := range encoded.Params {
+		param, err := decodeParam(item)
+		if err != nil {
+			return WriteTask{}, fmt.Errorf("could not decode param %d: %s", i, err)
+		}
+		task.Params = append(task.Params, param)
+	}
+	return task, nil
+}
+
+func decodeParam(item encodedParam) (interface{}, error) {
+	var err error
+	switch item.T {
+	case paramNil:
+		return nil, nil
+	case paramBool:
+		var v bool
+		err = json.Unmarshal(item.V, &v)
+		return v, err
+	case paramInt:
+		var v int64
+		err = json.Unmarshal(item.V, &v)
+		return v, err
+	case paramUint:
+		var v uint64
+		err = json.Unmarshal(item.V, &v)
+		return v, err
+	case paramFloat:
+		var v float64
+		err = json.Unmarshal(item.V, &v)
+		return v, err
+	case paramString:
+		var v string
+		err = json.Unmarshal(item.V, &v)
+		return v, err
+	case paramTime:
+		var v string
+		if err = json.Unmarshal(item.V, &v); err != nil {
+			return nil, err
+		}
+		return time.Parse(time.RFC3339Nano, v)
+	case paramBytes:
+		var v []byte
+		err = json.Unmarshal(item.V, &v)
+		return v, err
+	default:
+		return nil, fmt.Errorf("unknown param type: %s", item.T)
+	}
+}
@@ -0,0 +1,43 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the voluntary exit events table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreateVoluntaryExitTable = `
+		CREATE TABLE IF NOT EXISTS t_voluntary_exit_events(
+			f_label TEXT,
+			f_epoch INT,
+			f_validator_index BIGINT,
+			f_timestamp TIMESTAMP,
+			CONSTRAINT PK_VoluntaryExit PRIMARY KEY (f_label,f_validator_index));`
+
+	InsertNewVoluntaryExit = `
+		INSERT INTO t_voluntary_exit_events (
+			f_label,
+			f_epoch,
+			f_validator_index,
+			f_timestamp)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING;`
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createVoluntaryExitTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	_, err := pool.Exec(ctx, CreateVoluntaryExitTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating voluntary exit events table")
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the watched attestation arrival table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreateWatchAttArrivalTable = `
+		CREATE TABLE IF NOT EXISTS t_watch_att_arrival(
+			f_label TEXT,
+			f_validator_index BIGINT,
+			f_slot INT,
+			f_committee_index INT,
+			f_timestamp TIMESTAMP,
+			CONSTRAINT PK_WatchAttArrival PRIMARY KEY (f_label,f_validator_index,f_slot));`
+
+	// only the first time each node receives the vote is kept
+	InsertNewWatchAttArrival = `
+		INSERT INTO t_watch_att_arrival (
+			f_label,
+			f_validator_index,
+			f_slot,
+			f_committee_index,
+			f_timestamp)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO NOTHING;`
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createWatchAttArrivalTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	_, err := pool.Exec(ctx, CreateWatchAttArrivalTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating watched attestation arrival table")
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the watched attestations table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreateWatchAttestationTable = `
+		CREATE TABLE IF NOT EXISTS t_watch_attestations(
+			f_validator_index BIGINT,
+			f_epoch INT,
+			f_slot INT,
+			f_included BOOL,
+			f_inclusion_slot INT,
+			f_inclusion_delay INT,
+			f_correct_source BOOL,
+			f_correct_target BOOL,
+			f_correct_head BOOL,
+			CONSTRAINT PK_WatchAttestation PRIMARY KEY (f_validator_index,f_epoch));`
+
+	InsertNewWatchAttestation = `
+		INSERT INTO t_watch_attestations (
+			f_validator_index,
+			f_epoch,
+			f_slot,
+			f_included,
+			f_inclusion_slot,
+			f_inclusion_delay,
+			f_correct_source,
+			f_correct_target,
+			f_correct_head)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT DO NOTHING;`
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createWatchAttestationsTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	_, err := pool.Exec(ctx, CreateWatchAttestationTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating watched attestations table")
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package postgresql
+
+/*
+
+This file together with the model, has all the needed methods to interact with the watched proposer duties table of the database
+
+*/
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+var (
+	CreateWatchProposerDutyTable = `
+		CREATE TABLE IF NOT EXISTS t_watch_proposer_duties(
+			f_validator_index BIGINT,
+			f_epoch INT,
+			f_slot INT,
+			f_timestamp TIMESTAMP,
+			CONSTRAINT PK_WatchProposerDuty PRIMARY KEY (f_slot));`
+
+	InsertNewWatchProposerDuty = `
+		INSERT INTO t_watch_proposer_duties (
+			f_validator_index,
+			f_epoch,
+			f_slot,
+			f_timestamp)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING;`
+)
+
+// in case the table did not exist
+func (p *PostgresDBService) createWatchProposerDutiesTable(ctx context.Context, pool *pgxpool.Pool) error {
+	// create the tables
+	_, err := pool.Exec(ctx, CreateWatchProposerDutyTable)
+	if err != nil {
+		return errors.Wrap(err, "error creating watched proposer duties table")
+	}
+	return nil
+}
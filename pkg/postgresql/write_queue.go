@@ -0,0 +1,343 @@
+package postgresql
+
+/*
+
+This file has the per record type queues in front of the DB writers, and what to do when they are full
+
+*/
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/migalabs/streameth/pkg/clock"
+	"github.com/pkg/errors"
+)
+
+// record types, each one has its own queue and overflow policy
+const (
+	RecordDefault     = "default"
+	RecordAttestation = "attestation"
+	RecordHead        = "head" // new heads and missed blocks
+	RecordReorg       = "reorg"
+	RecordScore       = "score"
+	RecordNodeStatus  = "node_status" // node status and versions
+	RecordEvent       = "event"       // the other beacon node events
+	RecordWatchlist   = "watchlist"   // watched validators, always blocks so they are never lost
+)
+
+// what to do with a new record when its queue is full
+const (
+	OverflowBlock      = "block"       // wait until there is room, the caller stalls
+	OverflowDropOldest = "drop-oldest" // discard the oldest queued record
+	OverflowSample     = "sample"      // above half the capacity keep 1 in SAMPLE_RATIO records
+	OverflowSpill      = "spill"       // write the record to disk, it is queued again once there is room
+)
+
+var (
+	RecordTypes       = []string{RecordDefault, RecordAttestation, RecordHead, RecordReorg, RecordScore, RecordNodeStatus, RecordEvent, RecordWatchlist}
+	SAMPLE_RATIO      = uint64(10)
+	SPILL_DRAIN_CHECK = 1 * time.Second
+)
+
+// QueueConfig defines the queues in front of the DB writers
+type QueueConfig struct {
+	Policies map[string]string // record type -> overflow policy, block if not present
+	Size     int               // capacity of each queue
+	SpillDir string            // where to spill records of queues with the spill policy
+	// where to keep the records the database rejects, empty to discard them
+	DeadLetterFile string
+	Clock          clock.Clock // drives the flush, spill drain and retry timers, the system clock if nil
+}
+
+// ParseOverflowPolicies reads policies as type=policy pairs separated by commas
+func ParseOverflowPolicies(input string) (map[string]string, error) {
+	policies := make(map[string]string)
+	for _, item := range strings.Split(input, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid overflow policy %q, expected type=policy", item)
+		}
+		recordType, policy := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if !isRecordType(recordType) {
+			return nil, fmt.Errorf("unknown record type %q, try one of: %s", recordType, strings.Join(RecordTypes, ","))
+		}
+		switch policy {
+		case OverflowBlock, OverflowDropOldest, OverflowSample, OverflowSpill:
+		default:
+			return nil, fmt.Errorf("unknown overflow policy %q, try one of: block,drop-oldest,sample,spill", policy)
+		}
+		if recordType == RecordWatchlist && policy != OverflowBlock {
+			return nil, fmt.Errorf("%s records are never dropped, their overflow policy can only be %s", RecordWatchlist, OverflowBlock)
+		}
+		policies[recordType] = policy
+	}
+	return policies, nil
+}
+
+func isRecordType(recordType string) bool {
+	for _, item := range RecordTypes {
+		if item == recordType {
+			return true
+		}
+	}
+	return false
+}
+
+type writeQueue struct {
+	recordType string
+	policy     string
+	tasks      chan WriteTask
+	spillMu    sync.Mutex
+	spillPath  string
+	spillFile  *os.File
+	received   uint64
+	dropped    uint64 // discarded because the queue was full
+	sampled    uint64 // discarded by the sampling
+	spilled    uint64 // written to disk
+	spillErrs  uint64 // could not be written to disk, discarded
+}
+
+// QueueStats is a snapshot of the counters of a queue
+type QueueStats struct {
+	Type     string
+	Policy   string
+	Depth    int
+	Capacity int
+	Received uint64
+	Dropped  uint64
+	Sampled  uint64
+	Spilled  uint64
+	Lost     uint64 // spill failures
+}
+
+func newWriteQueue(recordType string, policy string, size int, spillDir string) *writeQueue {
+	if policy == "" {
+		policy = OverflowBlock
+	}
+	return &writeQueue{
+		recordType: recordType,
+		policy:     policy,
+		tasks:      make(chan WriteTask, size),
+		spillPath:  filepath.Join(spillDir, fmt.Sprintf("%s.jsonl", recordType)),
+	}
+}
+
+// push applies the overflow policy of the queue to the new task
+func (q *writeQueue) push(ctx context.Context, task WriteTask) {
+	atomic.AddUint64(&q.received, 1)
+
+	switch q.policy {
+	case OverflowDropOldest:
+		for {
+			select {
+			case q.tasks <- task:
+				return
+			default:
+			}
+			select {
+			case <-q.tasks:
+				atomic.AddUint64(&q.dropped, 1)
+			default:
+			}
+		}
+
+	case OverflowSample:
+		if len(q.tasks) >= cap(q.tasks)/2 &&
+			atomic.LoadUint64(&q.received)%SAMPLE_RATIO != 0 {
+			atomic.AddUint64(&q.sampled, 1)
+			return
+		}
+		select {
+		case q.tasks <- task:
+		default:
+			atomic.AddUint64(&q.dropped, 1)
+		}
+
+	case OverflowSpill:
+		select {
+		case q.tasks <- task:
+		default:
+			if err := q.spill(task); err != nil {
+				log.Errorf("could not spill %s record: %s", q.recordType, err)
+				atomic.AddUint64(&q.spillErrs, 1)
+				return
+			}
+			atomic.AddUint64(&q.spilled, 1)
+		}
+
+	default: // block
+		select {
+		case q.tasks <- task:
+		case <-ctx.Done():
+		}
+	}
+}
+
+func (q *writeQueue) spill(task WriteTask) error {
+	line, err := EncodeTask(task)
+	if err != nil {
+		return err
+	}
+
+	q.spillMu.Lock()
+	defer q.spillMu.Unlock()
+	if q.spillFile == nil {
+		if err := os.MkdirAll(filepath.Dir(q.spillPath), os.ModePerm); err != nil {
+			return err
+		}
+		q.spillFile, err = os.OpenFile(q.spillPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = q.spillFile.Write(append(line, '\n'))
+	return err
+}
+
+// takeSpill moves the spilled records aside to be drained, new records go to a new file
+func (q *writeQueue) takeSpill() (string, error) {
+	q.spillMu.Lock()
+	defer q.spillMu.Unlock()
+
+	if q.spillFile != nil {
+		q.spillFile.Close()
+		q.spillFile = nil
+	}
+	drainPath := q.spillPath + ".draining"
+	if _, err := os.Stat(drainPath); err == nil {
+		// left by a previous run, part of it may be written already,
+		// the inserts skip the rows that are already stored
+		log.Warnf("draining %s records left by a previous run", q.recordType)
+		return drainPath, nil
+	}
+	err := os.Rename(q.spillPath, drainPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	return drainPath, err
+}
+
+// drainSpill queues again the spilled records once the queue has room
+func (q *writeQueue) drainSpill(ctx context.Context, clk clock.Clock) {
+	ticker := clk.NewTicker(SPILL_DRAIN_CHECK)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			q.spillMu.Lock()
+			if q.spillFile != nil {
+				q.spillFile.Close()
+			}
+			q.spillMu.Unlock()
+			return
+		case <-ticker.Chan():
+		}
+
+		if len(q.tasks) >= cap(q.tasks)/2 {
+			continue
+		}
+		drainPath, err := q.takeSpill()
+		if err != nil {
+			log.Errorf("could not take %s spill file: %s", q.recordType, err)
+			continue
+		}
+		if drainPath == "" {
+			continue
+		}
+		if err := q.readSpill(ctx, drainPath); err != nil {
+			log.Errorf("could not drain %s spill file: %s", q.recordType, err)
+		}
+	}
+}
+
+func (q *writeQueue) readSpill(ctx context.Context, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		task, err := DecodeTask(scanner.Bytes())
+		if err != nil {
+			log.Errorf("discarding spilled %s record: %s", q.recordType, err)
+			continue
+		}
+		select {
+		case q.tasks <- task:
+			count++
+		case <-ctx.Done():
+			return nil // the rest of the file is drained on the next run
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	log.Infof("re-queued %d spilled %s records", count, q.recordType)
+	return os.Remove(path)
+}
+
+func (q *writeQueue) stats() QueueStats {
+	return QueueStats{
+		Type:     q.recordType,
+		Policy:   q.policy,
+		Depth:    len(q.tasks),
+		Capacity: cap(q.tasks),
+		Received: atomic.LoadUint64(&q.received),
+		Dropped:  atomic.LoadUint64(&q.dropped),
+		Sampled:  atomic.LoadUint64(&q.sampled),
+		Spilled:  atomic.LoadUint64(&q.spilled),
+		Lost:     atomic.LoadUint64(&q.spillErrs),
+	}
+}
+
+// forward moves the queued tasks to the writers, every queue competes for the write channel
+// so a busy record type cannot starve the others
+func (p *PostgresDBService) forward(q *writeQueue) {
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case task := <-q.tasks:
+			select {
+			case p.WriteChan <- task:
+			case <-p.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// QueueStats returns the counters of every record queue
+func (p *PostgresDBService) QueueStats() []QueueStats {
+	stats := make([]QueueStats, 0, len(p.queues))
+	for _, recordType := range RecordTypes {
+		stats = append(stats, p.queues[recordType].stats())
+	}
+	return stats
+}
+
+// tasks not written yet
+func (p *PostgresDBService) pendingTasks() int {
+	pending := len(p.WriteChan)
+	for _, q := range p.queues {
+		pending += len(q.tasks)
+	}
+	return pending
+}
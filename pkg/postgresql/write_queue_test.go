@@ -0,0 +1,56 @@
+package postgresql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOverflowPolicies(t *testing.T) {
+	policies, err := ParseOverflowPolicies("attestation=drop-oldest, head=spill")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{RecordAttestation: OverflowDropOldest, RecordHead: OverflowSpill}, policies)
+
+	_, err = ParseOverflowPolicies("blocks=spill")
+	assert.NotNil(t, err)
+	_, err = ParseOverflowPolicies("head=discard")
+	assert.NotNil(t, err)
+	_, err = ParseOverflowPolicies("watchlist=drop-oldest")
+	assert.NotNil(t, err)
+}
+
+func TestWriteQueueOverflow(t *testing.T) {
+	ctx := context.Background()
+
+	q := newWriteQueue(RecordAttestation, OverflowDropOldest, 2, "")
+	for i := 0; i < 5; i++ {
+		q.push(ctx, WriteTask{Params: []interface{}{i}})
+	}
+	assert.Equal(t, uint64(3), q.stats().Dropped)
+	assert.Equal(t, 3, (<-q.tasks).Params[0]) // the newest records are kept
+	assert.Equal(t, 4, (<-q.tasks).Params[0])
+
+	q = newWriteQueue(RecordAttestation, OverflowSample, 4, "")
+	for i := 0; i < 40; i++ {
+		q.push(ctx, WriteTask{Params: []interface{}{i}})
+	}
+	stats := q.stats()
+	assert.Equal(t, 4, stats.Depth)
+	assert.Equal(t, uint64(40), stats.Dropped+stats.Sampled+uint64(stats.Depth))
+
+	q = newWriteQueue(RecordHead, OverflowSpill, 1, t.TempDir())
+	now := time.Now().UTC()
+	q.push(ctx, WriteTask{QueryString: InsertNewBlock, Params: []interface{}{1, "label", now}, CopyTarget: BlockCopyTarget, Type: RecordHead})
+	q.push(ctx, WriteTask{QueryString: InsertNewBlock, Params: []interface{}{2, "label", now}, CopyTarget: BlockCopyTarget, Type: RecordHead})
+	assert.Equal(t, uint64(1), q.stats().Spilled)
+
+	<-q.tasks // make room, the spilled record is queued again
+	drainPath, err := q.takeSpill()
+	assert.Nil(t, err)
+	assert.Nil(t, q.readSpill(ctx, drainPath))
+	task := <-q.tasks
+	assert.Equal(t, []interface{}{int64(2), "label", now}, task.Params)
+	assert.Equal(t, BlockCopyTarget, task.CopyTarget)
+}
@@ -0,0 +1,230 @@
+package recording
+
+/*
+
+This package writes what is received from a beacon node (events and request responses)
+to compressed NDJSON files, and reads them back to replay them
+
+*/
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	moduleName = "Recording"
+	log        = logrus.WithField(
+		"module", moduleName)
+	FlushInterval = 1 * time.Second
+	fileSuffix    = ".ndjson.gz"
+)
+
+// kinds of record
+const (
+	KindNode     = "node"     // first record of every file, what node was recorded
+	KindEvent    = "event"    // event received from the node
+	KindBlock    = "block"    // response of a block request
+	KindProposal = "proposal" // response of a block proposal request
+)
+
+// Record is a line of a recording
+type Record struct {
+	Time     time.Time       `json:"time"`
+	Kind     string          `json:"kind"`
+	Topic    string          `json:"topic,omitempty"`     // event topic
+	Request  string          `json:"request,omitempty"`   // block id of the block requests
+	Slot     uint64          `json:"slot,omitempty"`      // slot of the proposals
+	OffsetMs int64           `json:"offset_ms,omitempty"` // offset of the proposals
+	Duration time.Duration   `json:"duration,omitempty"`  // time the node took to answer the proposal
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// NodeInfo describes the recorded node, so it can be replayed with the same label and client
+type NodeInfo struct {
+	Client   string    `json:"client"`
+	Label    string    `json:"label"`
+	Endpoint string    `json:"endpoint"`
+	Genesis  time.Time `json:"genesis"`
+}
+
+// Recorder writes the records of a node to a file named after the time the recording started
+type Recorder struct {
+	mu        sync.Mutex
+	file      *os.File
+	gzip      *gzip.Writer
+	lastFlush time.Time
+}
+
+// NewRecorder starts a new recording file in dir/name
+func NewRecorder(dir string, name string, node NodeInfo) (*Recorder, error) {
+	nodeDir := filepath.Join(dir, SafeName(name))
+	if err := os.MkdirAll(nodeDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("could not create recording folder %s: %s", nodeDir, err)
+	}
+	path := filepath.Join(nodeDir, time.Now().UTC().Format("20060102T150405.000")+fileSuffix)
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not create recording file %s: %s", path, err)
+	}
+	r := &Recorder{
+		file:      file,
+		gzip:      gzip.NewWriter(file),
+		lastFlush: time.Now(),
+	}
+	log.Infof("recording %s to %s", name, path)
+
+	data, err := json.Marshal(node)
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("could not encode node info: %s", err)
+	}
+	if err := r.Write(Record{Time: time.Now(), Kind: KindNode, Data: data}); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write appends the record to the file, the compressed stream is flushed every FlushInterval
+func (r *Recorder) Write(record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("could not encode record: %s", err)
+	}
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.gzip.Write(line); err != nil {
+		return fmt.Errorf("could not write record: %s", err)
+	}
+	if time.Since(r.lastFlush) > FlushInterval {
+		r.lastFlush = time.Now()
+		return r.gzip.Flush()
+	}
+	return nil
+}
+
+// Close flushes the pending records and closes the file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.gzip.Close(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// SafeName keeps names with endpoints usable as folder names
+func SafeName(name string) string {
+	return strings.NewReplacer("/", "_", ":", "_").Replace(name)
+}
+
+// NodeDirs returns the folders with the recordings of each node
+func NodeDirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read recordings folder %s: %s", dir, err)
+	}
+	dirs := make([]string, 0)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return dirs, nil
+}
+
+// Reader reads the recordings of a node in order, file after file
+type Reader struct {
+	files   []string
+	file    *os.File
+	gzip    *gzip.Reader
+	scanner *bufio.Scanner
+}
+
+// NewReader opens the recordings of the node in the folder, sorted by start time
+func NewReader(nodeDir string) (*Reader, error) {
+	files, err := filepath.Glob(filepath.Join(nodeDir, "*"+fileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no recordings in %s", nodeDir)
+	}
+	sort.Strings(files)
+	return &Reader{files: files}, nil
+}
+
+// Next returns the next record, io.EOF once every file was read
+func (r *Reader) Next() (Record, error) {
+	for {
+		if r.scanner == nil {
+			if len(r.files) == 0 {
+				return Record{}, io.EOF
+			}
+			if err := r.open(r.files[0]); err != nil {
+				return Record{}, err
+			}
+			r.files = r.files[1:]
+		}
+		if r.scanner.Scan() {
+			record := Record{}
+			if err := json.Unmarshal(r.scanner.Bytes(), &record); err != nil {
+				return Record{}, fmt.Errorf("could not decode record: %s", err)
+			}
+			return record, nil
+		}
+		err := r.scanner.Err()
+		r.closeFile()
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return Record{}, err
+		}
+		// files of runs that did not finish cleanly end abruptly, keep what could be read
+	}
+}
+
+func (r *Reader) open(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open recording %s: %s", path, err)
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("could not read recording %s: %s", path, err)
+	}
+	r.file = file
+	r.gzip = reader
+	r.scanner = bufio.NewScanner(reader)
+	r.scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	return nil
+}
+
+func (r *Reader) closeFile() {
+	if r.gzip != nil {
+		r.gzip.Close()
+	}
+	if r.file != nil {
+		r.file.Close()
+	}
+	r.file, r.gzip, r.scanner = nil, nil, nil
+}
+
+// Close releases the open file
+func (r *Reader) Close() {
+	r.closeFile()
+}
@@ -0,0 +1,63 @@
+package recording
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRecordingRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	node := NodeInfo{Client: "lighthouse", Label: "node-1", Endpoint: "http://localhost:5052"}
+	recorder, err := NewRecorder(dir, "node-1/http://localhost:5052", node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		record := Record{
+			Time:    start.Add(time.Duration(i) * time.Second),
+			Kind:    KindBlock,
+			Request: "head",
+			Data:    json.RawMessage(`{"slot":"1"}`),
+		}
+		if err := recorder.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dirs, err := NodeDirs(dir)
+	if err != nil || len(dirs) != 1 {
+		t.Fatalf("expected one node folder, got %v (%v)", dirs, err)
+	}
+	reader, err := NewReader(dirs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	first, err := reader.Next()
+	if err != nil || first.Kind != KindNode {
+		t.Fatalf("expected the node record first, got %+v (%v)", first, err)
+	}
+	read := NodeInfo{}
+	if err := json.Unmarshal(first.Data, &read); err != nil || read != node {
+		t.Errorf("unexpected node info %+v (%v)", read, err)
+	}
+	for i := 0; i < 3; i++ {
+		record, err := reader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.Kind != KindBlock || !record.Time.Equal(start.Add(time.Duration(i)*time.Second)) {
+			t.Errorf("unexpected record %d: %+v", i, record)
+		}
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("expected the end of the recording, got %v", err)
+	}
+}
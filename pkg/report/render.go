@@ -0,0 +1,101 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+var columns = []string{
+	"Label", "Client", "Proposals", "Failure rate",
+	"Mean score", "p50 score", "p90 score",
+	"Mean latency (s)", "p50 latency (s)", "p95 latency (s)",
+	"Win rate",
+	"Arrival p50 (s)", "Arrival p90 (s)", "Arrival p99 (s)",
+	"Missed blocks",
+}
+
+func (c ClientSummary) cells() []string {
+	return []string{
+		c.Label,
+		c.Client,
+		fmt.Sprintf("%d", c.Proposals),
+		fmt.Sprintf("%.1f%%", c.FailureRate*100),
+		fmt.Sprintf("%.2f", c.MeanScore),
+		fmt.Sprintf("%.2f", c.P50Score),
+		fmt.Sprintf("%.2f", c.P90Score),
+		fmt.Sprintf("%.3f", c.MeanLatency),
+		fmt.Sprintf("%.3f", c.P50Latency),
+		fmt.Sprintf("%.3f", c.P95Latency),
+		fmt.Sprintf("%.1f%%", c.WinRate*100),
+		fmt.Sprintf("%.3f", c.ArrivalP50),
+		fmt.Sprintf("%.3f", c.ArrivalP90),
+		fmt.Sprintf("%.3f", c.ArrivalP99),
+		fmt.Sprintf("%d", c.MissedBlocks),
+	}
+}
+
+// Write renders the report in the given format
+func (r Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatMarkdown:
+		return r.writeMarkdown(w)
+	case FormatHTML:
+		return r.writeHTML(w)
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	default:
+		return fmt.Errorf("unknown report format: %s", format)
+	}
+}
+
+func (r Report) writeMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Client report: slots %d to %d\n\n", r.FromSlot, r.ToSlot)
+	fmt.Fprintf(&b, "Generated at %s\n\n", r.Generated.Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(&b, "| %s |\n", strings.Join(columns, " | "))
+	fmt.Fprintf(&b, "|%s\n", strings.Repeat(" --- |", len(columns)))
+	for _, item := range r.Clients {
+		fmt.Fprintf(&b, "| %s |\n", strings.Join(item.cells(), " | "))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Client report: slots {{.FromSlot}} to {{.ToSlot}}</title>
+<style>
+table { border-collapse: collapse; font-family: sans-serif; font-size: 14px; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+th { background: #eee; }
+</style>
+</head>
+<body>
+<h1>Client report: slots {{.FromSlot}} to {{.ToSlot}}</h1>
+<p>Generated at {{.Generated.Format "2006-01-02 15:04:05 MST"}}</p>
+<table>
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func (r Report) writeHTML(w io.Writer) error {
+	rows := make([][]string, 0, len(r.Clients))
+	for _, item := range r.Clients {
+		rows = append(rows, item.cells())
+	}
+	return htmlTemplate.Execute(w, struct {
+		Report
+		Columns []string
+		Rows    [][]string
+	}{r, columns, rows})
+}
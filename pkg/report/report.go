@@ -0,0 +1,156 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/migalabs/streameth/pkg/chain_stats"
+	"github.com/migalabs/streameth/pkg/postgresql"
+	"github.com/migalabs/streameth/pkg/utils"
+)
+
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatJSON     = "json"
+
+	daySeconds = 24 * 60 * 60
+)
+
+// Report compares the nodes over a slot range
+type Report struct {
+	Generated time.Time       `json:"generated"`
+	FromSlot  uint64          `json:"from_slot"`
+	ToSlot    uint64          `json:"to_slot"`
+	Clients   []ClientSummary `json:"clients"`
+}
+
+// ClientSummary has the figures of one node (label) and its client
+type ClientSummary struct {
+	Label        string  `json:"label"`
+	Client       string  `json:"client"`
+	Proposals    int     `json:"proposals"`
+	FailureRate  float64 `json:"failure_rate"`
+	MeanScore    float64 `json:"mean_score"`
+	P50Score     float64 `json:"p50_score"`
+	P90Score     float64 `json:"p90_score"`
+	MeanLatency  float64 `json:"mean_latency_s"` // time to produce a proposal
+	P50Latency   float64 `json:"p50_latency_s"`
+	P95Latency   float64 `json:"p95_latency_s"`
+	WinRate      float64 `json:"win_rate"` // best score among the nodes at the same slot
+	ArrivalP50   float64 `json:"arrival_p50_s"`
+	ArrivalP90   float64 `json:"arrival_p90_s"`
+	ArrivalP99   float64 `json:"arrival_p99_s"`
+	MissedBlocks int     `json:"missed_blocks"`
+}
+
+func ValidFormat(format string) bool {
+	return format == FormatMarkdown || format == FormatHTML || format == FormatJSON
+}
+
+// Build aggregates the last epochs stored in the database, up to the last scored slot
+// The arrival delays need the genesis time, they are left at 0 if chainTime is nil
+func Build(ctx context.Context, db *postgresql.PostgresDBService, epochs uint64, chainTime *chain_stats.ChainTime) (Report, error) {
+	toSlot, err := db.LastScoredSlot(ctx)
+	if err != nil {
+		return Report{}, err
+	}
+	fromSlot := uint64(0)
+	if toSlot+1 > epochs*utils.SlotsPerEpoch {
+		fromSlot = toSlot + 1 - epochs*utils.SlotsPerEpoch
+	}
+
+	report := Report{
+		Generated: time.Now().UTC(),
+		FromSlot:  fromSlot,
+		ToSlot:    toSlot,
+		Clients:   make([]ClientSummary, 0),
+	}
+
+	scores, err := db.ScoreSummaries(ctx, fromSlot, toSlot)
+	if err != nil {
+		return Report{}, err
+	}
+	missed, err := db.MissedBlockCounts(ctx, fromSlot, toSlot)
+	if err != nil {
+		return Report{}, err
+	}
+	delays := make(map[string][]float64)
+	if chainTime != nil {
+		arrivals, err := db.BlockArrivals(ctx, fromSlot, toSlot)
+		if err != nil {
+			return Report{}, err
+		}
+		for _, item := range arrivals {
+			delays[item.Label] = append(delays[item.Label], arrivalDelay(*chainTime, item))
+		}
+	}
+
+	for _, item := range scores {
+		summary := ClientSummary{
+			Label:        item.Label,
+			Client:       item.ClientName,
+			Proposals:    item.Proposals,
+			MeanScore:    item.MeanScore,
+			P50Score:     item.P50Score,
+			P90Score:     item.P90Score,
+			MeanLatency:  item.MeanDuration,
+			P50Latency:   item.P50Duration,
+			P95Latency:   item.P95Duration,
+			MissedBlocks: missed[item.Label],
+		}
+		if item.Proposals > 0 {
+			summary.FailureRate = float64(item.Failures) / float64(item.Proposals)
+		}
+		if item.Contested > 0 {
+			summary.WinRate = float64(item.Wins) / float64(item.Contested)
+		}
+		labelDelays := delays[item.Label]
+		sort.Float64s(labelDelays)
+		summary.ArrivalP50 = percentile(labelDelays, 0.5)
+		summary.ArrivalP90 = percentile(labelDelays, 0.9)
+		summary.ArrivalP99 = percentile(labelDelays, 0.99)
+		report.Clients = append(report.Clients, summary)
+	}
+	return report, nil
+}
+
+// arrivalDelay returns the seconds from the slot start to the arrival.
+// The arrival only has the local time of the day, so it is matched with the closest day
+func arrivalDelay(chainTime chain_stats.ChainTime, arrival postgresql.BlockArrival) float64 {
+	slotStart := chainTime.SlotTime(phase0.Slot(arrival.Slot)).Local()
+	midnight := time.Date(slotStart.Year(), slotStart.Month(), slotStart.Day(), 0, 0, 0, 0, slotStart.Location())
+	delay := arrival.DaySecond - slotStart.Sub(midnight).Seconds()
+	if delay < -daySeconds/2 {
+		delay += daySeconds
+	} else if delay >= daySeconds/2 {
+		delay -= daySeconds
+	}
+	return delay
+}
+
+// percentile interpolates between the closest ranks, as percentile_cont, values must be sorted
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	rank := p * float64(len(values)-1)
+	lower := int(rank)
+	if lower+1 >= len(values) {
+		return values[len(values)-1]
+	}
+	return values[lower] + (rank-float64(lower))*(values[lower+1]-values[lower])
+}
+
+// FileName is the name of the report file, by the last slot
+func (r Report) FileName(format string) string {
+	extension := map[string]string{
+		FormatMarkdown: "md",
+		FormatHTML:     "html",
+		FormatJSON:     "json",
+	}[format]
+	return fmt.Sprintf("report_%d_%d.%s", r.FromSlot, r.ToSlot, extension)
+}
@@ -0,0 +1,58 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/migalabs/streameth/pkg/chain_stats"
+	"github.com/migalabs/streameth/pkg/postgresql"
+)
+
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4}
+	if got := percentile(values, 0.5); got != 2.5 {
+		t.Errorf("p50: expected 2.5, got %f", got)
+	}
+	if got := percentile(values, 1); got != 4 {
+		t.Errorf("p100: expected 4, got %f", got)
+	}
+	if got := percentile(nil, 0.5); got != 0 {
+		t.Errorf("empty: expected 0, got %f", got)
+	}
+}
+
+func TestArrivalDelay(t *testing.T) {
+	// slot 1 starts 12s before local midnight
+	midnight := time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)
+	chainTime := chain_stats.ChainTime{GenesisTime: midnight.Add(-24 * time.Second)}
+
+	delay := arrivalDelay(chainTime, postgresql.BlockArrival{Slot: 1, DaySecond: 1.5})
+	if delay != 13.5 {
+		t.Errorf("expected 13.5s, got %f", delay)
+	}
+	delay = arrivalDelay(chainTime, postgresql.BlockArrival{Slot: 2, DaySecond: 2})
+	if delay != 2 {
+		t.Errorf("expected 2s, got %f", delay)
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	r := Report{
+		FromSlot: 32,
+		ToSlot:   63,
+		Clients: []ClientSummary{
+			{Label: "lh-1", Client: "lighthouse", Proposals: 32, FailureRate: 0.5, WinRate: 0.25},
+		},
+	}
+	var b strings.Builder
+	if err := r.Write(&b, FormatMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "| lh-1 | lighthouse | 32 | 50.0% |") {
+		t.Errorf("unexpected table:\n%s", b.String())
+	}
+	if err := r.Write(&b, "pdf"); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/migalabs/streameth/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	moduleName = "tracing"
+	log        = logrus.WithField(
+		"module", moduleName)
+)
+
+const (
+	NoExporter   = "none"
+	OtlpExporter = "otlp"
+	FileExporter = "file"
+
+	tracerName = "github.com/migalabs/streameth"
+)
+
+// common span attributes
+var (
+	SlotKey   = attribute.Key("slot")
+	LabelKey  = attribute.Key("label")
+	ClientKey = attribute.Key("client")
+	OffsetKey = attribute.Key("offset")
+)
+
+// Tracer returns the tracer of the tool, a no-op one until Init is called
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Init configures the global tracer provider with the given exporter:
+// otlp sends the spans over OTLP/HTTP to the collector at endpoint,
+// file writes them as json lines to the given path for offline use.
+// The returned function flushes and stops the exporter
+func Init(ctx context.Context, exporter string, endpoint string, filePath string, sampleRatio float64) (func(context.Context) error, error) {
+	var spanExporter sdktrace.SpanExporter
+	var err error
+
+	closeFn := func(context.Context) error { return nil }
+
+	switch exporter {
+	case NoExporter, "":
+		return closeFn, nil
+
+	case OtlpExporter:
+		spanExporter, err = otlptracehttp.New(ctx,
+			otlptracehttp.WithEndpoint(endpoint),
+			otlptracehttp.WithInsecure(),
+		)
+		if err != nil {
+			return closeFn, fmt.Errorf("could not create otlp exporter: %s", err)
+		}
+
+	case FileExporter:
+		f, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return closeFn, fmt.Errorf("could not open traces file %s: %s", filePath, err)
+		}
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return closeFn, fmt.Errorf("could not create file exporter: %s", err)
+		}
+		closeFn = func(context.Context) error { return f.Close() }
+
+	default:
+		return closeFn, fmt.Errorf("unknown tracing exporter: %s", exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(utils.CliName),
+		semconv.ServiceVersion(utils.Version),
+	))
+	if err != nil {
+		return closeFn, fmt.Errorf("could not create tracing resource: %s", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	log.Infof("tracing enabled, exporting spans to %s", exporter)
+
+	fileCloseFn := closeFn
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closeErr := fileCloseFn(ctx); err == nil {
+			err = closeErr
+		}
+		return err
+	}, nil
+}
@@ -3,16 +3,11 @@ package utils
 import (
 	"bytes"
 
-	"github.com/attestantio/go-eth2-client/api"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 )
 
-func IsCorrectSource(attestation phase0.Attestation, block api.VersionedProposal) bool {
+func IsCorrectSource(attestation phase0.Attestation, slot phase0.Slot) bool {
 
-	slot, err := block.Slot()
-	if err != nil {
-		return false
-	}
 	// TODO: check source target
 	return slot-attestation.Data.Slot <= 5
 }
@@ -31,17 +26,8 @@ func IsCorrectTarget(attestation phase0.Attestation, rootHistory map[phase0.Slot
 
 }
 
-func IsCorrectHead(attestation phase0.Attestation, block api.VersionedProposal) bool {
-
-	slot, err := block.Slot()
-	if err != nil {
-		return false
-	}
-
-	parentRoot, err := block.ParentRoot()
-	if err != nil {
-		return false
-	}
+// slot and parent root of the block including the attestation
+func IsCorrectHead(attestation phase0.Attestation, slot phase0.Slot, parentRoot phase0.Root) bool {
 
 	if bytes.Equal(parentRoot[:], attestation.Data.BeaconBlockRoot[:]) {
 		if slot-attestation.Data.Slot == 1 {
@@ -1,5 +1,7 @@
 package utils
 
+import "strings"
+
 const (
 	PrysmClient      = "Prysm"
 	LighthouseClient = "Lighthouse"
@@ -27,3 +29,26 @@ func CheckValidClientName(name string) bool {
 		return false
 	}
 }
+
+// ParseClientVersion identifies the client and its version from the string
+// reported by /eth/v1/node/version (e.g. Lighthouse/v4.5.0-441fc16/x86_64-linux)
+// An empty client is returned when it could not be identified
+func ParseClientVersion(nodeVersion string) (string, string) {
+	parts := strings.Split(strings.TrimSpace(nodeVersion), "/")
+
+	client := ""
+	name := strings.ToLower(parts[0])
+	for _, item := range []string{PrysmClient, LighthouseClient, TekuClient, NimbusClient, LodestarClient, GrandineClient} {
+		if strings.HasPrefix(name, strings.ToLower(item)) {
+			client = item
+			break
+		}
+	}
+
+	version := ""
+	if len(parts) > 1 {
+		version = parts[1]
+	}
+
+	return client, version
+}
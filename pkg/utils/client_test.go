@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseClientVersion(t *testing.T) {
+	tests := []struct {
+		nodeVersion string
+		client      string
+		version     string
+	}{
+		{"Lighthouse/v4.5.0-441fc16/x86_64-linux", LighthouseClient, "v4.5.0-441fc16"},
+		{"Prysm/v4.2.1/59b310a221a42ebdbd2c1e5eb7e4b3e5aae0e2a2. Built at: 2024-01-10 19:43:42+00:00", PrysmClient, "v4.2.1"},
+		{"teku/v24.1.0/linux-x86_64/-eclipseadoptium-openjdk64bitservervm-java-17", TekuClient, "v24.1.0"},
+		{"Nimbus/v24.1.2-c6e4ab-stateofus", NimbusClient, "v24.1.2-c6e4ab-stateofus"},
+		{"Lodestar/v1.15.0/56a7c6a", LodestarClient, "v1.15.0"},
+		{"Grandine/0.4.0-5a3f4c2/x86_64-linux", GrandineClient, "0.4.0-5a3f4c2"},
+		{" Lighthouse/v5.0.0\n", LighthouseClient, "v5.0.0"},
+		{"Lighthouse", LighthouseClient, ""},
+		// not identified
+		{"Erigon/v2.56.1/linux-amd64", "", "v2.56.1"},
+		{"unknown", "", ""},
+		{"", "", ""},
+	}
+	for _, test := range tests {
+		client, version := ParseClientVersion(test.nodeVersion)
+		assert.Equal(t, test.client, client, test.nodeVersion)
+		assert.Equal(t, test.version, version, test.nodeVersion)
+	}
+}
@@ -9,8 +9,35 @@ const (
 	AttestationMetric = "attestations"
 	ProposalMetric    = "proposals"
 	ReorgMetric       = "reorgs"
+	FinalityMetric    = "finality"
+	HeadsMetric       = "heads"
+
+	// metrics named after the beacon node event topic they store
+	BlockEventMetric          = "block"
+	BlockGossipMetric         = "block_gossip"
+	BlobSidecarMetric         = "blob_sidecar"
+	FinalizedCheckpointMetric = "finalized_checkpoint"
+	VoluntaryExitMetric       = "voluntary_exit"
+	BLSChangeMetric           = "bls_to_execution_change"
+	ContributionMetric        = "contribution_and_proof"
+	PayloadAttributesMetric   = "payload_attributes"
+	AttesterSlashingMetric    = "attester_slashing"
+	ProposerSlashingMetric    = "proposer_slashing"
 )
 
+var EventMetrics = []string{
+	BlockEventMetric,
+	BlockGossipMetric,
+	BlobSidecarMetric,
+	FinalizedCheckpointMetric,
+	VoluntaryExitMetric,
+	BLSChangeMetric,
+	ContributionMetric,
+	PayloadAttributesMetric,
+	AttesterSlashingMetric,
+	ProposerSlashingMetric,
+}
+
 func ParseMetrics(metricsInput string) ([]string, error) {
 
 	metrics := make([]string, 0)
@@ -24,6 +51,26 @@ func ParseMetrics(metricsInput string) ([]string, error) {
 	return metrics, nil
 }
 
+// ContainsMetric returns true if the metric is in the list
+func ContainsMetric(metrics []string, metric string) bool {
+	for _, item := range metrics {
+		if item == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEventMetric returns true if the metric is one of the event topics
+func IsEventMetric(metricInput string) bool {
+	for _, item := range EventMetrics {
+		if item == metricInput {
+			return true
+		}
+	}
+	return false
+}
+
 func checkValidMetric(metricInput string) bool {
 	switch metricInput {
 	case AttestationMetric:
@@ -32,7 +79,11 @@ func checkValidMetric(metricInput string) bool {
 		return true
 	case ReorgMetric:
 		return true
+	case FinalityMetric:
+		return true
+	case HeadsMetric:
+		return true
 	default:
-		return false
+		return IsEventMetric(metricInput)
 	}
 }
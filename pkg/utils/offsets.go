@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	SlotDuration = 12 * time.Second
+)
+
+// ParseProposalOffsets reads the offsets relative to the slot start at which proposals are requested
+// e.g. -1s,0s,2s,4s. Offsets must be within one slot
+func ParseProposalOffsets(offsetsInput string) ([]time.Duration, error) {
+
+	offsets := make([]time.Duration, 0)
+	seen := make(map[time.Duration]struct{})
+	for _, item := range strings.Split(offsetsInput, ",") {
+		offset, err := time.ParseDuration(strings.TrimSpace(item))
+		if err != nil {
+			return make([]time.Duration, 0), fmt.Errorf("proposal offset is not valid: %s", item)
+		}
+		if offset <= -SlotDuration || offset >= SlotDuration {
+			return make([]time.Duration, 0), fmt.Errorf("proposal offset must be within one slot: %s", item)
+		}
+		if _, ok := seen[offset]; ok {
+			continue
+		}
+		seen[offset] = struct{}{}
+		offsets = append(offsets, offset)
+	}
+	return offsets, nil
+}
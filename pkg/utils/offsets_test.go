@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProposalOffsets(t *testing.T) {
+	offsets, err := ParseProposalOffsets("-1s, 0s,2s,4s")
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{-time.Second, 0, 2 * time.Second, 4 * time.Second}, offsets)
+
+	// duplicates are requested once, in the order they were first given
+	offsets, err = ParseProposalOffsets("4s,0s,4000ms,0s")
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{4 * time.Second, 0}, offsets)
+
+	// negative offsets request the proposal before the slot starts, up to a slot earlier
+	offsets, err = ParseProposalOffsets("-11.5s,-500ms")
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{-11500 * time.Millisecond, -500 * time.Millisecond}, offsets)
+
+	for _, input := range []string{"12s", "-12s", "0s,13s", "1m", "abc", "2", ""} {
+		offsets, err = ParseProposalOffsets(input)
+		assert.Error(t, err, input)
+		assert.Empty(t, offsets, input)
+	}
+}
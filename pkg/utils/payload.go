@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+)
+
+// PayloadSummary has the execution payload fields tracked per block
+type PayloadSummary struct {
+	BlockNumber     uint64
+	BlockHash       phase0.Hash32
+	TxCount         int
+	GasUsed         uint64
+	GasLimit        uint64
+	BaseFeeWei      uint64
+	Withdrawals     int
+	BlobCommitments int
+}
+
+// PayloadSummaryFromBlock reads the execution payload of the block, false if there is none (before the merge)
+// An error is returned for the forks it can not read, so they are not mistaken for blocks with no payload
+func PayloadSummaryFromBlock(block spec.VersionedSignedBeaconBlock) (PayloadSummary, bool, error) {
+	switch block.Version {
+	case spec.DataVersionPhase0, spec.DataVersionAltair:
+		return PayloadSummary{}, false, nil
+
+	case spec.DataVersionBellatrix:
+		payload := block.Bellatrix.Message.Body.ExecutionPayload
+		return PayloadSummary{
+			BlockNumber: payload.BlockNumber,
+			BlockHash:   payload.BlockHash,
+			TxCount:     len(payload.Transactions),
+			GasUsed:     payload.GasUsed,
+			GasLimit:    payload.GasLimit,
+			BaseFeeWei:  baseFeeFromLE(payload.BaseFeePerGas),
+		}, payload.BlockNumber != 0, nil // empty payloads before the merge
+
+	case spec.DataVersionCapella:
+		payload := block.Capella.Message.Body.ExecutionPayload
+		return PayloadSummary{
+			BlockNumber: payload.BlockNumber,
+			BlockHash:   payload.BlockHash,
+			TxCount:     len(payload.Transactions),
+			GasUsed:     payload.GasUsed,
+			GasLimit:    payload.GasLimit,
+			BaseFeeWei:  baseFeeFromLE(payload.BaseFeePerGas),
+			Withdrawals: len(payload.Withdrawals),
+		}, true, nil
+
+	case spec.DataVersionDeneb:
+		payload := block.Deneb.Message.Body.ExecutionPayload
+		return PayloadSummary{
+			BlockNumber:     payload.BlockNumber,
+			BlockHash:       payload.BlockHash,
+			TxCount:         len(payload.Transactions),
+			GasUsed:         payload.GasUsed,
+			GasLimit:        payload.GasLimit,
+			BaseFeeWei:      payload.BaseFeePerGas.Uint64(),
+			Withdrawals:     len(payload.Withdrawals),
+			BlobCommitments: len(block.Deneb.Message.Body.BlobKZGCommitments),
+		}, true, nil
+	}
+	return PayloadSummary{}, false, fmt.Errorf("unsupported block version %s", block.Version)
+}
+
+// base fees are little endian uint256, they always fit in 64 bits
+func baseFeeFromLE(baseFee [32]byte) uint64 {
+	return binary.LittleEndian.Uint64(baseFee[:8])
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+)
+
+// ParseWatchlist reads validator indices and 0x prefixed pubkeys separated by commas or new lines.
+// If the input starts with @ the list is read from the file named after it, e.g. @fleet.txt
+func ParseWatchlist(input string) ([]phase0.ValidatorIndex, []phase0.BLSPubKey, error) {
+	indices := make([]phase0.ValidatorIndex, 0)
+	pubkeys := make([]phase0.BLSPubKey, 0)
+
+	if strings.HasPrefix(input, "@") {
+		content, err := os.ReadFile(strings.TrimPrefix(input, "@"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not read watchlist file: %s", err)
+		}
+		input = string(content)
+	}
+
+	items := strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' ' || r == '\t'
+	})
+	for _, item := range items {
+		if strings.HasPrefix(item, "0x") {
+			content, err := hex.DecodeString(strings.TrimPrefix(item, "0x"))
+			if err != nil || len(content) != len(phase0.BLSPubKey{}) {
+				return nil, nil, fmt.Errorf("watchlist pubkey is not valid: %s", item)
+			}
+			var pubkey phase0.BLSPubKey
+			copy(pubkey[:], content)
+			pubkeys = append(pubkeys, pubkey)
+			continue
+		}
+		index, err := strconv.ParseUint(item, 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("watchlist validator index is not valid: %s", item)
+		}
+		indices = append(indices, phase0.ValidatorIndex(index))
+	}
+	return indices, pubkeys, nil
+}
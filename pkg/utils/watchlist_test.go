@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWatchlist(t *testing.T) {
+	pubkey := "0x" + strings.Repeat("ab", 48)
+	var expectedPubkey phase0.BLSPubKey
+	for i := range expectedPubkey {
+		expectedPubkey[i] = 0xab
+	}
+
+	indices, pubkeys, err := ParseWatchlist("1, 2,\t" + pubkey + "\n3")
+	assert.Nil(t, err)
+	assert.Equal(t, []phase0.ValidatorIndex{1, 2, 3}, indices)
+	assert.Equal(t, []phase0.BLSPubKey{expectedPubkey}, pubkeys)
+
+	// one per line in a file
+	path := filepath.Join(t.TempDir(), "fleet.txt")
+	assert.Nil(t, os.WriteFile(path, []byte("10\r\n"+pubkey+"\r\n\r\n11\n"), 0644))
+	indices, pubkeys, err = ParseWatchlist("@" + path)
+	assert.Nil(t, err)
+	assert.Equal(t, []phase0.ValidatorIndex{10, 11}, indices)
+	assert.Equal(t, []phase0.BLSPubKey{expectedPubkey}, pubkeys)
+
+	for _, input := range []string{
+		"-1",
+		"validator",
+		"0x1234",                        // too short
+		"0x" + strings.Repeat("zz", 48), // not hex
+		"@" + filepath.Join(t.TempDir(), "missing.txt"),
+	} {
+		_, _, err := ParseWatchlist(input)
+		assert.NotNil(t, err, input)
+	}
+}